@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"errors"
+	"sync"
+)
+
+// WithAck returns the records followed by an acknowledgement marker,
+// an inlet passes it to the InletNextFunc to be notified when the records are handled.
+//
+// The marker follows the records through the flows and fn is called once
+// after every outlet has handled the records those were sent before the marker.
+// err is the error of an outlet that failed to handle the records since the previous marker.
+// A buffered flow holds the marker until it holds none of the records
+// those came before the marker, or until it is flushed.
+// If the pipeline stops before the marker reaches the outlets,
+// fn is called with the error of the failed outlet or ErrAckStopped.
+// fn is called exactly once.
+func WithAck(recs []Record, fn func(err error)) []Record {
+	return append(recs, &ackRecord{Record: NewRecord(), fn: fn})
+}
+
+// ErrAckStopped is the error of the acknowledgement
+// when the pipeline stops before the records are handled.
+var ErrAckStopped = errors.New("pipeline stopped before the records are handled")
+
+// ackRecord is the marker that is passed through the pipeline alone as a batch
+type ackRecord struct {
+	Record
+	fn      func(error)
+	mutex   sync.Mutex
+	pending int
+	err     error
+	once    sync.Once
+	// untrack removes the marker from the pipeline when it fires
+	untrack func(*ackRecord)
+}
+
+// fire calls fn only once
+func (ar *ackRecord) fire(err error) {
+	ar.once.Do(func() {
+		if ar.untrack != nil {
+			ar.untrack(ar)
+		}
+		ar.fn(err)
+	})
+}
+
+// splitAck separates the acknowledgement markers from the records
+func splitAck(recs []Record) ([]Record, []*ackRecord) {
+	var acks []*ackRecord
+	ret := recs[:0:0]
+	for _, r := range recs {
+		if ar, ok := r.(*ackRecord); ok {
+			acks = append(acks, ar)
+		} else {
+			ret = append(ret, r)
+		}
+	}
+	if acks == nil {
+		return recs, nil
+	}
+	return ret, acks
+}
+
+// ackOf returns the marker if the batch is the acknowledgement marker
+func ackOf(recs []Record) *ackRecord {
+	if len(recs) == 1 {
+		if ar, ok := recs[0].(*ackRecord); ok {
+			return ar
+		}
+	}
+	return nil
+}
+
+// expect sets the number of outlets those should handle the marker
+func (ar *ackRecord) expect(n int) {
+	ar.mutex.Lock()
+	ar.pending = n
+	ar.mutex.Unlock()
+	if n == 0 {
+		ar.fire(nil)
+	}
+}
+
+// done is called by an outlet with the error of the handling
+func (ar *ackRecord) done(err error) {
+	ar.mutex.Lock()
+	if err != nil && ar.err == nil {
+		ar.err = err
+	}
+	ar.pending--
+	fire := ar.pending == 0
+	ar.mutex.Unlock()
+	if fire {
+		ar.fire(ar.err)
+	}
+}
+
+// ackTracker keeps the markers those are not fired yet,
+// they are failed when the pipeline stops.
+type ackTracker struct {
+	mutex sync.Mutex
+	acks  map[*ackRecord]struct{}
+	// the first error of the outlets
+	err error
+}
+
+func (at *ackTracker) track(ar *ackRecord) {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+	if at.acks == nil {
+		at.acks = map[*ackRecord]struct{}{}
+	}
+	at.acks[ar] = struct{}{}
+	ar.untrack = at.untrack
+}
+
+func (at *ackTracker) untrack(ar *ackRecord) {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+	delete(at.acks, ar)
+}
+
+// fail records the error of an outlet
+func (at *ackTracker) fail(err error) {
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+	if at.err == nil {
+		at.err = err
+	}
+}
+
+// stop fires the markers those are left with the error
+func (at *ackTracker) stop() {
+	at.mutex.Lock()
+	err := at.err
+	if err == nil {
+		err = ErrAckStopped
+	}
+	left := make([]*ackRecord, 0, len(at.acks))
+	for ar := range at.acks {
+		left = append(left, ar)
+	}
+	at.mutex.Unlock()
+	for _, ar := range left {
+		ar.fire(err)
+	}
+}
@@ -0,0 +1,117 @@
+package engine_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/stretchr/testify/require"
+)
+
+// ackEvents records the order of the handled records and the acknowledgements
+type ackEvents struct {
+	sync.Mutex
+	events []string
+}
+
+func (ae *ackEvents) add(ev string) {
+	ae.Lock()
+	defer ae.Unlock()
+	ae.events = append(ae.events, ev)
+}
+
+func TestWithAck(t *testing.T) {
+	events := &ackEvents{}
+	var outletErr error
+	defaultRecs := func() []engine.Record {
+		return []engine.Record{
+			engine.NewRecord(engine.NewField("name", "a")),
+			engine.NewRecord(engine.NewField("name", "b")),
+		}
+	}
+	inletRecs := defaultRecs
+	engine.RegisterInlet(&engine.InletReg{
+		Name: "test-ack",
+		Factory: func(ctx *engine.Context) engine.Inlet {
+			return engine.InletWithFunc(func() ([]engine.Record, error) {
+				return engine.WithAck(inletRecs(), func(err error) {
+					if err != nil {
+						events.add("nack " + err.Error())
+						return
+					}
+					events.add("ack")
+				}), nil
+			})
+		},
+	})
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name: "test-ack",
+		Factory: func(ctx *engine.Context) engine.Outlet {
+			return engine.OutletWithFunc(func(recs []engine.Record) error {
+				events.add(fmt.Sprintf("handled %d", len(recs)))
+				return outletErr
+			})
+		},
+	})
+
+	timedRecs := func() []engine.Record {
+		t0 := time.Unix(1721954790, 0)
+		return []engine.Record{
+			engine.NewRecord(engine.NewField("name", "a"), engine.NewField("time", t0)),
+			engine.NewRecord(engine.NewField("name", "b"), engine.NewField("time", t0.Add(10*time.Second))),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		recs   func() []engine.Record
+		flow   string
+		err    error
+		expect []string
+	}{
+		{name: "flow", flow: "[[flows.select]]\nincludes = [\"name\"]", expect: []string{"handled 2", "ack"}},
+		// the merge flow holds the marker until it emits the merged record
+		{name: "buffered_flow", flow: "[[flows.merge]]\nwait_limit = \"1s\"", expect: []string{"handled 1", "ack"}},
+		// the reorder flow emits "a" that passes the watermark and holds "b",
+		// the marker waits until "b" is emitted
+		{
+			name:   "partial_flow",
+			recs:   timedRecs,
+			flow:   "[[flows.reorder]]\nlateness = \"5s\"\ntime_field = \"time\"\nidle_timeout = \"0s\"",
+			expect: []string{"handled 1", "handled 1", "ack"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events = &ackEvents{}
+			outletErr = tt.err
+			inletRecs = defaultRecs
+			if tt.recs != nil {
+				inletRecs = tt.recs
+			}
+			dsl := "[[inlets.test-ack]]\n" + tt.flow + "\n[[outlets.test-ack]]\n"
+			pipeline, err := engine.New(engine.WithConfig(dsl))
+			require.NoError(t, err)
+			require.NoError(t, pipeline.Run())
+			require.Equal(t, tt.expect, events.events)
+		})
+	}
+
+	// the failure of the outlet stops the pipeline,
+	// the marker is acknowledged once with the error of the outlet
+	// whether it reaches the outlet or not.
+	for i := 0; i < 10; i++ {
+		events = &ackEvents{}
+		outletErr = errors.New("fail")
+		inletRecs = defaultRecs
+		pipeline, err := engine.New(engine.WithConfig("[[inlets.test-ack]]\n[[outlets.test-ack]]\n"))
+		require.NoError(t, err)
+		pipeline.Run()
+		events.Lock()
+		require.Equal(t, []string{"handled 2", "nack fail"}, events.events)
+		events.Unlock()
+	}
+}
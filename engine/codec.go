@@ -2,7 +2,9 @@ package engine
 
 import (
 	"io"
+	"mime"
 	"slices"
+	"strings"
 	"sync"
 )
 
@@ -60,6 +62,10 @@ type Encoder interface {
 type DecoderReg struct {
 	Name    string
 	Factory func(DecoderConfig) Decoder
+	// ContentTypes are the MIME types that the decoder can handle,
+	// the parameters of a content type, e.g. "text/plain; version=0.0.4",
+	// should be present in the content type to match.
+	ContentTypes []string
 }
 
 type DecoderConfig struct {
@@ -93,6 +99,37 @@ func GetDecoder(name string) *DecoderReg {
 	return nil
 }
 
+// GetDecoderByContentType returns the decoder that handles the given MIME type,
+// the parameters of the content type (e.g. "; charset=utf-8") are ignored
+// unless the registered content type of the decoder has them.
+func GetDecoderByContentType(contentType string) *DecoderReg {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = contentType
+	}
+	decodersLock.RLock()
+	defer decodersLock.RUnlock()
+	for _, reg := range decoders {
+		for _, ct := range reg.ContentTypes {
+			regMt, regParams, err := mime.ParseMediaType(ct)
+			if err != nil || !strings.EqualFold(regMt, mt) {
+				continue
+			}
+			matched := true
+			for k, v := range regParams {
+				if params[k] != v {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return reg
+			}
+		}
+	}
+	return nil
+}
+
 func DecoderNames() []string {
 	decodersLock.RLock()
 	defer decodersLock.RUnlock()
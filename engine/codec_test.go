@@ -54,6 +54,15 @@ func TestCodecs(t *testing.T) {
 		t.Fatal("json encoder not found")
 	}
 
+	dec = engine.GetDecoderByContentType("application/json; charset=utf-8")
+	require.NotNil(t, dec)
+	require.Equal(t, "json", dec.Name)
+	dec = engine.GetDecoderByContentType("text/csv")
+	require.NotNil(t, dec)
+	require.Equal(t, "csv", dec.Name)
+	dec = engine.GetDecoderByContentType("application/octet-stream")
+	require.Nil(t, dec)
+
 	names := engine.EncoderNames()
 	require.Equal(t, []string{"csv", "json", "test-csv", "test-json"}, names)
 
@@ -3,6 +3,7 @@ package engine
 import (
 	"io"
 	"slices"
+	"strings"
 	"sync"
 )
 
@@ -48,8 +49,9 @@ func CompressorNames() []string {
 }
 
 type Decompressor struct {
-	Name    string
-	Factory func(io.Reader) io.ReadCloser
+	Name            string
+	Factory         func(io.Reader) io.ReadCloser
+	ContentEncoding string
 }
 
 var decompressors = make(map[string]*Decompressor)
@@ -76,6 +78,18 @@ func GetDecompressor(name string) *Decompressor {
 	return nil
 }
 
+// GetDecompressorByContentEncoding returns the decompressor for the given Content-Encoding
+func GetDecompressorByContentEncoding(contentEncoding string) *Decompressor {
+	decompressorsLock.RLock()
+	defer decompressorsLock.RUnlock()
+	for _, reg := range decompressors {
+		if reg.ContentEncoding != "" && strings.EqualFold(reg.ContentEncoding, contentEncoding) {
+			return reg
+		}
+	}
+	return nil
+}
+
 func DecompressorNames() []string {
 	decompressorsLock.RLock()
 	defer decompressorsLock.RUnlock()
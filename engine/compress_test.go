@@ -1,6 +1,8 @@
 package engine_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"compress/zlib"
 	"io"
 	"testing"
@@ -39,6 +41,12 @@ func TestCompressor(t *testing.T) {
 	require.NotNil(t, dec)
 	require.Equal(t, "test-zlib", dec.Name)
 
+	dec = engine.GetDecompressorByContentEncoding("deflate")
+	require.NotNil(t, dec)
+	require.Equal(t, "inflate", dec.Name)
+	dec = engine.GetDecompressorByContentEncoding("identity")
+	require.Nil(t, dec)
+
 	engine.UnregisterCompressor("test-zlib")
 	engine.UnregisterDecompressor("test-zlib")
 
@@ -48,3 +56,33 @@ func TestCompressor(t *testing.T) {
 	names = engine.DecompressorNames()
 	require.Equal(t, []string{"flate", "gzip", "inflate", "lzw", "zlib"}, names)
 }
+
+func TestReaderDecompress(t *testing.T) {
+	data := &bytes.Buffer{}
+	zw := gzip.NewWriter(data)
+	zw.Write([]byte("a,1\nb,2\n"))
+	zw.Close()
+
+	conf := engine.NewConfig().
+		Set("format", "csv").
+		Set("compress", "gzip").
+		Set("fields", []string{"name", "value"}).
+		Set("types", []string{"string", "int"})
+	reader, err := engine.NewReader(data, conf)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	// the decoder reads the decompressed stream
+	recs := []engine.Record{}
+	for {
+		rs, err := reader.Read()
+		recs = append(recs, rs...)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.Len(t, recs, 2)
+	require.Equal(t, "a", recs[0].Field("name").Value.Raw())
+	require.Equal(t, int64(2), recs[1].Field("value").Value.Raw())
+}
@@ -18,6 +18,26 @@ type BufferedFlow interface {
 	Flush(FlowNextFunc)
 }
 
+// PartialFlow is a BufferedFlow that emits a part of the buffered records.
+// Released returns the number of the leading records received by Process
+// those are emitted or dropped already, it is the sequence of the oldest record it holds,
+// or the number of all the records received if it holds none.
+// The acknowledgement marker is held until Released passes the records those came before it,
+// the other BufferedFlow holds the markers until it is flushed.
+// Released is called from the goroutine of the FlowHandler, not from FlowNextFunc,
+// it should wait for the emitting of the flow's own goroutines, e.g. by the lock of the flow.
+type PartialFlow interface {
+	BufferedFlow
+	Released() uint64
+}
+
+// DroppingFlow is a Flow that may drop records,
+// it reports the number of dropped records to FlowHandler.Stats().
+type DroppingFlow interface {
+	Flow
+	Dropped() uint64
+}
+
 var flowRegistry = make(map[string]*FlowReg)
 var flowsLock sync.RWMutex
 
@@ -67,6 +87,11 @@ type FlowHandler struct {
 	parallelism chan struct{}
 	closeWg     sync.WaitGroup
 
+	// acknowledgement markers held by the buffered flow
+	ackHeld []heldAck
+	// signaled when the buffered flow emits the records
+	emitCh chan struct{}
+
 	recv uint64
 	sent uint64
 }
@@ -82,10 +107,11 @@ func NewFlowHandler(ctx *Context, name string, flow Flow) *FlowHandler {
 		}
 	}
 	ret := &FlowHandler{
-		ctx:  ctx,
-		name: name,
-		inCh: make(chan []Record),
-		flow: flow,
+		ctx:    ctx,
+		name:   name,
+		inCh:   make(chan []Record),
+		flow:   flow,
+		emitCh: make(chan struct{}, 1),
 	}
 	if parallelism > 1 {
 		ret.parallelism = make(chan struct{}, parallelism)
@@ -113,11 +139,27 @@ func (fh *FlowHandler) Start() error {
 			fh.outCh <- r
 			atomic.AddUint64(&fh.sent, uint64(len(r)))
 		}
+		// the flow may emit from its own goroutine, e.g. by a timer
+		select {
+		case fh.emitCh <- struct{}{}:
+		default:
+		}
 	}
 
 	if fh.parallelism != nil {
 		go func() {
 			for records := range fh.inCh {
+				if ar := ackOf(records); ar != nil {
+					// wait for the records those are being processed
+					for i := 0; i < cap(fh.parallelism); i++ {
+						fh.parallelism <- struct{}{}
+					}
+					for i := 0; i < cap(fh.parallelism); i++ {
+						<-fh.parallelism
+					}
+					fh.forwardAck(ar)
+					continue
+				}
 				atomic.AddUint64(&fh.recv, uint64(len(records)))
 				fh.closeWg.Add(1)
 				fh.parallelism <- struct{}{}
@@ -136,15 +178,30 @@ func (fh *FlowHandler) Start() error {
 		}()
 	} else {
 		go func() {
-			for records := range fh.inCh {
-				atomic.AddUint64(&fh.recv, uint64(len(records)))
-				fh.flow.Process(records, flowCallback)
-				if _, ok := fh.flow.(*fanOutFlow); ok {
-					atomic.AddUint64(&fh.sent, uint64(len(records)))
+		loop:
+			for {
+				select {
+				case records, ok := <-fh.inCh:
+					if !ok {
+						break loop
+					}
+					if ar := ackOf(records); ar != nil {
+						fh.forwardAck(ar)
+						continue
+					}
+					atomic.AddUint64(&fh.recv, uint64(len(records)))
+					fh.flow.Process(records, flowCallback)
+					if _, ok := fh.flow.(*fanOutFlow); ok {
+						atomic.AddUint64(&fh.sent, uint64(len(records)))
+					}
+					fh.releaseAcks(false)
+				case <-fh.emitCh:
+					fh.releaseAcks(false)
 				}
 			}
 			if buffered, ok := fh.flow.(BufferedFlow); ok {
 				buffered.Flush(flowCallback)
+				fh.releaseAcks(true)
 			}
 			fh.closeWg.Done()
 		}()
@@ -153,6 +210,54 @@ func (fh *FlowHandler) Start() error {
 	return nil
 }
 
+// forwardAck passes the acknowledgement marker to the next step,
+// the buffered flow holds it until the flow emits the records those came before it.
+func (fh *FlowHandler) forwardAck(ar *ackRecord) {
+	if fanOut, ok := fh.flow.(*fanOutFlow); ok {
+		fanOut.ack(ar)
+		return
+	}
+	if _, ok := fh.flow.(BufferedFlow); ok {
+		fh.ackHeld = append(fh.ackHeld, heldAck{ar: ar, seq: atomic.LoadUint64(&fh.recv)})
+		fh.releaseAcks(false)
+		return
+	}
+	fh.outCh <- []Record{ar}
+}
+
+// heldAck is the marker held by the buffered flow,
+// seq is the number of the records those came before the marker.
+type heldAck struct {
+	ar  *ackRecord
+	seq uint64
+}
+
+// releaseAcks passes the held markers to the next step,
+// whose records those came before are released by the buffered flow,
+// or all markers if force is true after the flow is flushed.
+// It is called only by the goroutine of the FlowHandler.
+func (fh *FlowHandler) releaseAcks(force bool) {
+	if len(fh.ackHeld) == 0 {
+		return
+	}
+	n := len(fh.ackHeld)
+	if !force {
+		partial, ok := fh.flow.(PartialFlow)
+		if !ok {
+			return
+		}
+		released := partial.Released()
+		n = 0
+		for n < len(fh.ackHeld) && fh.ackHeld[n].seq <= released {
+			n++
+		}
+	}
+	for _, h := range fh.ackHeld[:n] {
+		fh.outCh <- []Record{h.ar}
+	}
+	fh.ackHeld = fh.ackHeld[n:]
+}
+
 func (fh *FlowHandler) Stop() error {
 	close(fh.inCh)
 	fh.closeWg.Wait()
@@ -162,10 +267,31 @@ func (fh *FlowHandler) Stop() error {
 	if err := fh.flow.Close(); err != nil {
 		return err
 	}
-	fh.ctx.LogDebug("flow stopped", "name", fh.name, "recv", fh.recv, "sent", fh.sent)
+	stats := fh.Stats()
+	fh.ctx.LogDebug("flow stopped", "name", fh.name, "recv", stats.Recv, "sent", stats.Sent, "dropped", stats.Dropped)
 	return nil
 }
 
+// FlowStats is the number of records those a flow has received, sent and dropped
+type FlowStats struct {
+	Recv    uint64
+	Sent    uint64
+	Dropped uint64
+}
+
+// Stats returns the counters of the flow,
+// Dropped is always 0 if the flow is not a DroppingFlow.
+func (fh *FlowHandler) Stats() FlowStats {
+	ret := FlowStats{
+		Recv: atomic.LoadUint64(&fh.recv),
+		Sent: atomic.LoadUint64(&fh.sent),
+	}
+	if dropping, ok := fh.flow.(DroppingFlow); ok {
+		ret.Dropped = dropping.Dropped()
+	}
+	return ret
+}
+
 func init() {
 	RegisterFlow(&FlowReg{Name: "fan-in", Factory: FanInFlow})
 	RegisterFlow(&FlowReg{Name: "fan-out", Factory: FanOutFlow})
@@ -203,6 +329,14 @@ func (ff *fanOutFlow) LinkOutlets(outs ...*OutletHandler) {
 	}
 }
 
+// ack passes the acknowledgement marker to all outlets
+func (ff *fanOutFlow) ack(ar *ackRecord) {
+	ar.expect(len(ff.outs))
+	for _, o := range ff.outs {
+		o <- []Record{ar}
+	}
+}
+
 func (ff *fanOutFlow) Process(r []Record, cb FlowNextFunc) {
 	for _, o := range ff.outs {
 		o <- r
@@ -253,10 +253,7 @@ func (in *InletHandler) runPull() error {
 	for range in.trigger {
 		doBreak := false
 		in.inlet.Process(func(recs []Record, err error) {
-			if len(recs) > 0 {
-				in.outCh <- prependInletNameTimestamp(recs, in.name)
-				atomic.AddUint64(&in.sent, uint64(len(recs)))
-			}
+			in.send(recs)
 			if err != nil {
 				if err == io.EOF {
 					in.ctx.LogDebug("input eof")
@@ -290,10 +287,7 @@ func (in *InletHandler) runPush() error {
 	}()
 
 	in.inlet.Process(func(recs []Record, err error) {
-		if len(recs) > 0 {
-			in.outCh <- prependInletNameTimestamp(recs, in.name)
-			atomic.AddUint64(&in.sent, uint64(len(recs)))
-		}
+		in.send(recs)
 		if err != nil {
 			if err == io.EOF {
 				in.ctx.LogDebug("input eof")
@@ -309,6 +303,22 @@ func (in *InletHandler) runPush() error {
 	return nil
 }
 
+// send passes the records to the next step,
+// the acknowledgement markers follow the records as separate batches.
+func (in *InletHandler) send(recs []Record) {
+	recs, acks := splitAck(recs)
+	if len(recs) > 0 {
+		in.outCh <- prependInletNameTimestamp(recs, in.name)
+		atomic.AddUint64(&in.sent, uint64(len(recs)))
+	}
+	for _, ar := range acks {
+		if in.ctx.pipeline != nil {
+			in.ctx.pipeline.acks.track(ar)
+		}
+		in.outCh <- []Record{ar}
+	}
+}
+
 const TAG_INLET = "_in"
 const TAG_TIMESTAMP = "_ts"
 
@@ -77,6 +77,8 @@ type OutletHandler struct {
 	buffer  []Record
 	recvCnt uint64
 	doneCnt uint64
+	// the error since the last acknowledgement marker
+	ackErr error
 }
 
 func NewOutletHandler(ctx *Context, name string, outlet Outlet) (*OutletHandler, error) {
@@ -107,6 +109,12 @@ func (out *OutletHandler) Start() error {
 		for {
 			select {
 			case r := <-out.inCh:
+				if ar := ackOf(r); ar != nil {
+					// the records before the marker are handled already
+					ar.done(out.ackErr)
+					out.ackErr = nil
+					continue
+				}
 				out.buffer = append(out.buffer, r...)
 				atomic.AddUint64(&out.recvCnt, uint64(len(r)))
 				out.flush(false)
@@ -127,6 +135,10 @@ func (out *OutletHandler) flush(_ /*force*/ bool) {
 	}
 	if err := out.outlet.Handle(out.buffer); err != nil {
 		out.ctx.LogError("failed to output flush", "error", err.Error())
+		out.ackErr = err
+		if out.ctx.pipeline != nil {
+			out.ctx.pipeline.acks.fail(err)
+		}
 		out.ctx.CircuitBreak()
 	} else {
 		atomic.AddUint64(&out.doneCnt, uint64(len(out.buffer)))
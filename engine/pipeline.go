@@ -34,6 +34,7 @@ type Pipeline struct {
 	startOnce  sync.Once
 	stopOnce   sync.Once
 	rawWriter  io.Writer
+	acks       ackTracker
 
 	setContentTypeFunc     SetContentTypeCallback
 	setContentEncodingFunc SetContentEncodingCallback
@@ -437,6 +438,7 @@ func (p *Pipeline) Stop() error {
 		for _, out := range p.outputs {
 			out.Stop()
 		}
+		p.acks.stop()
 	})
 	return nil
 }
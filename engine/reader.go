@@ -84,6 +84,11 @@ func NewReader(r io.Reader, cfg Config) (*Reader, error) {
 			}
 		}
 	}
+	compress := GetDecompressor(ret.Compress)
+	if compress != nil {
+		ret.raw = compress.Factory(ret.raw)
+	}
+
 	ret.decoder = reg.Factory(DecoderConfig{
 		Reader:       ret.raw,
 		Fields:       ret.Fields,
@@ -91,11 +96,6 @@ func NewReader(r io.Reader, cfg Config) (*Reader, error) {
 		FormatOption: ValueFormat{Timeformat: timeformatter},
 	})
 
-	compress := GetDecompressor(ret.Compress)
-	if compress != nil {
-		ret.raw = compress.Factory(ret.raw)
-	}
-
 	return ret, nil
 }
 
@@ -8,6 +8,7 @@ import (
 	_ "github.com/OutOfBedlam/tine/plugins/args"
 	_ "github.com/OutOfBedlam/tine/plugins/base"
 	_ "github.com/OutOfBedlam/tine/plugins/chrome"
+	_ "github.com/OutOfBedlam/tine/plugins/dirwatch"
 	_ "github.com/OutOfBedlam/tine/plugins/excel"
 	_ "github.com/OutOfBedlam/tine/plugins/exec"
 	_ "github.com/OutOfBedlam/tine/plugins/expr"
@@ -15,14 +16,21 @@ import (
 	_ "github.com/OutOfBedlam/tine/plugins/http"
 	_ "github.com/OutOfBedlam/tine/plugins/image"
 	_ "github.com/OutOfBedlam/tine/plugins/influx"
+	_ "github.com/OutOfBedlam/tine/plugins/kafka"
 	_ "github.com/OutOfBedlam/tine/plugins/mqtt"
 	_ "github.com/OutOfBedlam/tine/plugins/nats"
 	_ "github.com/OutOfBedlam/tine/plugins/ollama"
+	_ "github.com/OutOfBedlam/tine/plugins/otlp"
+	_ "github.com/OutOfBedlam/tine/plugins/prometheus"
 	_ "github.com/OutOfBedlam/tine/plugins/psutil"
 	_ "github.com/OutOfBedlam/tine/plugins/qrcode"
+	_ "github.com/OutOfBedlam/tine/plugins/redis"
 	_ "github.com/OutOfBedlam/tine/plugins/screenshot"
 	_ "github.com/OutOfBedlam/tine/plugins/snmp"
+	_ "github.com/OutOfBedlam/tine/plugins/socket"
+	_ "github.com/OutOfBedlam/tine/plugins/sql"
 	_ "github.com/OutOfBedlam/tine/plugins/sqlite"
+	_ "github.com/OutOfBedlam/tine/plugins/statsd"
 	_ "github.com/OutOfBedlam/tine/plugins/syslog"
 	_ "github.com/OutOfBedlam/tine/plugins/telegram"
 	_ "github.com/OutOfBedlam/tine/plugins/template"
@@ -1,6 +1,11 @@
 package base
 
-import "github.com/OutOfBedlam/tine/engine"
+import (
+	"container/list"
+	"strings"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
 
 func init() {
 	engine.RegisterFlow(&engine.FlowReg{Name: "merge", Factory: MergeFlow})
@@ -10,4 +15,106 @@ func init() {
 	engine.RegisterFlow(&engine.FlowReg{Name: "select", Factory: SelectFlow})
 	engine.RegisterFlow(&engine.FlowReg{Name: "update", Factory: UpdateFlow})
 	engine.RegisterFlow(&engine.FlowReg{Name: "inject", Factory: InjectFlow})
+	engine.RegisterFlow(&engine.FlowReg{Name: "throttle", Factory: ThrottleFlow})
+	engine.RegisterFlow(&engine.FlowReg{Name: "sample", Factory: SampleFlow})
+	engine.RegisterFlow(&engine.FlowReg{Name: "dedup", Factory: DedupFlow})
+	engine.RegisterFlow(&engine.FlowReg{Name: "resample", Factory: ResampleFlow})
+	engine.RegisterFlow(&engine.FlowReg{Name: "reorder", Factory: ReorderFlow})
+	engine.RegisterFlow(&engine.FlowReg{Name: "multiline", Factory: MultilineFlow})
+	engine.RegisterFlow(&engine.FlowReg{Name: "decode", Factory: DecodeFlow})
+	engine.RegisterFlow(&engine.FlowReg{Name: "encode", Factory: EncodeFlow})
+}
+
+// recordKey builds a key string of the record from the given names.
+// A name that starts with "#" refers to a tag, otherwise it refers to a field.
+// If names is empty, all fields of the record are used.
+func recordKey(r engine.Record, names []string) string {
+	sb := &strings.Builder{}
+	vf := engine.DefaultValueFormat()
+	if len(names) == 0 {
+		for i, f := range r.Fields() {
+			if i > 0 {
+				sb.WriteByte(0)
+			}
+			if f != nil {
+				sb.WriteString(f.Name)
+				sb.WriteByte('=')
+				sb.WriteString(f.Value.Format(vf))
+			}
+		}
+		return sb.String()
+	}
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(0)
+		}
+		var v *engine.Value
+		if strings.HasPrefix(name, "#") {
+			v = r.Tags().Get(name[1:])
+		} else if f := r.Field(name); f != nil {
+			v = f.Value
+		}
+		if v != nil && v.IsNotNull() {
+			sb.WriteString(v.Format(vf))
+		}
+	}
+	return sb.String()
+}
+
+// keyLRU is a bounded map that evicts the least recently used key
+// when the number of keys exceeds the limit.
+type keyLRU[V any] struct {
+	limit int
+	ll    *list.List
+	items map[string]*list.Element
+	// onEvict is called with the key and the value evicted by the limit
+	onEvict func(key string, value V)
+}
+
+type keyLRUEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newKeyLRU[V any](limit int) *keyLRU[V] {
+	return &keyLRU[V]{
+		limit: limit,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *keyLRU[V]) Get(key string) (V, bool) {
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*keyLRUEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *keyLRU[V]) Put(key string, value V) {
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*keyLRUEntry[V]).value = value
+		return
+	}
+	c.items[key] = c.ll.PushFront(&keyLRUEntry[V]{key: key, value: value})
+	for c.limit > 0 && c.ll.Len() > c.limit {
+		e := c.ll.Back()
+		c.remove(e)
+		if c.onEvict != nil {
+			entry := e.Value.(*keyLRUEntry[V])
+			c.onEvict(entry.key, entry.value)
+		}
+	}
+}
+
+func (c *keyLRU[V]) Len() int {
+	return c.ll.Len()
+}
+
+func (c *keyLRU[V]) remove(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*keyLRUEntry[V]).key)
 }
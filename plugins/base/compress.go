@@ -19,8 +19,11 @@ func init() {
 			return ret
 		},
 	})
+	// the decompressors are looked up by the Content-Encoding header,
+	// only the HTTP content codings "deflate" and "gzip" are registered for it.
 	engine.RegisterDecompressor(&engine.Decompressor{
-		Name: "inflate",
+		Name:            "inflate",
+		ContentEncoding: "deflate",
 		Factory: func(r io.Reader) io.ReadCloser {
 			return flate.NewReader(r)
 		},
@@ -47,9 +50,13 @@ func init() {
 		},
 	})
 	engine.RegisterDecompressor(&engine.Decompressor{
-		Name: "gzip",
+		Name:            "gzip",
+		ContentEncoding: "gzip",
 		Factory: func(r io.Reader) io.ReadCloser {
-			ret, _ := gzip.NewReader(r)
+			ret, err := gzip.NewReader(r)
+			if err != nil {
+				return &errReadCloser{err: err}
+			}
 			return ret
 		},
 	})
@@ -76,8 +83,20 @@ func init() {
 	engine.RegisterDecompressor(&engine.Decompressor{
 		Name: "zlib",
 		Factory: func(r io.Reader) io.ReadCloser {
-			ret, _ := zlib.NewReader(r)
+			ret, err := zlib.NewReader(r)
+			if err != nil {
+				return &errReadCloser{err: err}
+			}
 			return ret
 		},
 	})
 }
+
+// errReadCloser is returned by the decompressor when it fails to read the header,
+// so that the error is reported by the first Read().
+type errReadCloser struct {
+	err error
+}
+
+func (r *errReadCloser) Read([]byte) (int, error) { return 0, r.err }
+func (r *errReadCloser) Close() error             { return nil }
@@ -15,8 +15,9 @@ func init() {
 		ContentType: "text/csv",
 	})
 	engine.RegisterDecoder(&engine.DecoderReg{
-		Name:    "csv",
-		Factory: NewCSVDecoder,
+		Name:         "csv",
+		Factory:      NewCSVDecoder,
+		ContentTypes: []string{"text/csv"},
 	})
 }
 
@@ -12,10 +12,12 @@ type damperFlow struct {
 	bufferLimit    int
 	bufferInterval time.Duration
 	lastFlush      time.Time
+	received       uint64
 }
 
 var _ = engine.Flow((*damperFlow)(nil))
 var _ = engine.BufferedFlow((*damperFlow)(nil))
+var _ = engine.PartialFlow((*damperFlow)(nil))
 
 func DamperFlow(ctx *engine.Context) engine.Flow {
 	bufferSize := ctx.Config().GetInt("buffer_size", 20)
@@ -35,6 +37,7 @@ func (df *damperFlow) Parallelism() int { return 1 }
 
 func (df *damperFlow) Process(r []engine.Record, nextFunc engine.FlowNextFunc) {
 	df.buffer = append(df.buffer, r...)
+	df.received += uint64(len(r))
 	if time.Since(df.lastFlush) >= df.bufferInterval || len(df.buffer) >= df.bufferLimit {
 		df.lastFlush = time.Now()
 		ret := df.buffer
@@ -48,6 +51,9 @@ func (df *damperFlow) Process(r []engine.Record, nextFunc engine.FlowNextFunc) {
 	}
 }
 
+// Released returns the number of the received records those are emitted
+func (df *damperFlow) Released() uint64 { return df.received - uint64(len(df.buffer)) }
+
 func (df *damperFlow) Flush(nextFunc engine.FlowNextFunc) {
 	ret := df.buffer
 	df.buffer = make([]engine.Record, 0, df.bufferSize)
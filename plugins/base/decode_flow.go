@@ -0,0 +1,111 @@
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+type decodeFlow struct {
+	ctx       *engine.Context
+	field     string
+	prefix    string
+	dropField bool
+}
+
+var _ = engine.Flow((*decodeFlow)(nil))
+
+func DecodeFlow(ctx *engine.Context) engine.Flow {
+	return &decodeFlow{ctx: ctx}
+}
+
+func (df *decodeFlow) Open() error {
+	conf := df.ctx.Config()
+	df.field = conf.GetString("field", "")
+	df.prefix = conf.GetString("prefix", "")
+	df.dropField = conf.GetBool("drop_field", false)
+	if df.field == "" {
+		return fmt.Errorf("decode: field is required")
+	}
+	format := conf.GetString("format", "json")
+	if engine.GetDecoder(format) == nil {
+		return fmt.Errorf("decode: format %q not found", format)
+	}
+	return nil
+}
+
+func (df *decodeFlow) Close() error     { return nil }
+func (df *decodeFlow) Parallelism() int { return 1 }
+
+func (df *decodeFlow) Process(recs []engine.Record, nextFunc engine.FlowNextFunc) {
+	ret := make([]engine.Record, 0, len(recs))
+	for _, r := range recs {
+		decoded, err := df.decode(r)
+		if err != nil {
+			df.ctx.LogWarn("flows.decode", "field", df.field, "error", err.Error())
+			ret = append(ret, r)
+			continue
+		}
+		if decoded == nil {
+			ret = append(ret, r)
+			continue
+		}
+		for _, d := range decoded {
+			fields := []*engine.Field{}
+			for _, f := range r.Fields() {
+				if f == nil || (df.dropField && strings.EqualFold(f.Name, df.field)) {
+					continue
+				}
+				fields = append(fields, f)
+			}
+			rec := engine.NewRecord(fields...)
+			for _, f := range d.Fields() {
+				if f == nil {
+					continue
+				}
+				rec = rec.AppendOrReplace(f.Copy(df.prefix + f.Name))
+			}
+			rec.Tags().Merge(r.Tags())
+			ret = append(ret, rec)
+		}
+	}
+	nextFunc(ret, nil)
+}
+
+// decode returns the records decoded from the field,
+// it returns nil if the record does not have the field.
+func (df *decodeFlow) decode(r engine.Record) ([]engine.Record, error) {
+	f := r.Field(df.field)
+	if f == nil || f.IsNull() {
+		return nil, nil
+	}
+	var data []byte
+	switch f.Type() {
+	case engine.BINARY:
+		data, _ = f.Value.Bytes()
+	case engine.STRING:
+		str, _ := f.Value.String()
+		data = []byte(str)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", f.Type())
+	}
+	reader, err := engine.NewReader(bytes.NewReader(data), df.ctx.Config())
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,63 @@
+package base_test
+
+import (
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+)
+
+func ExampleDecodeFlow() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			'{"id":"a", "payload":"{\"temp\":21.5, \"unit\":\"C\"}"}',
+			'{"id":"b", "payload":"{\"temp\":22.5, \"unit\":\"C\"}"}',
+		]
+		format = "json"
+	[[flows.decode]]
+		field = "payload"
+		format = "json"
+		prefix = "p_"
+		drop_field = true
+	[[outlets.file]]
+		path = "-"
+		format = "json"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// {"id":"a","p_temp":21.5,"p_unit":"C"}
+	// {"id":"b","p_temp":22.5,"p_unit":"C"}
+}
+
+func ExampleDecodeFlow_csv() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			'{"id":"a", "payload":"x,1\ny,2"}',
+		]
+		format = "json"
+	[[flows.decode]]
+		field = "payload"
+		format = "csv"
+		fields = ["name", "value"]
+		types = ["string", "int"]
+	[[outlets.file]]
+		path = "-"
+		format = "json"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// {"id":"a","name":"x","payload":"x,1\ny,2","value":1}
+	// {"id":"a","name":"y","payload":"x,1\ny,2","value":2}
+}
@@ -0,0 +1,62 @@
+package base
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+type dedupFlow struct {
+	ctx     *engine.Context
+	keys    []string
+	ttl     time.Duration
+	seen    *keyLRU[time.Time]
+	dropped uint64
+}
+
+var _ = engine.Flow((*dedupFlow)(nil))
+var _ = engine.DroppingFlow((*dedupFlow)(nil))
+
+func DedupFlow(ctx *engine.Context) engine.Flow {
+	return &dedupFlow{ctx: ctx}
+}
+
+func (df *dedupFlow) Open() error {
+	conf := df.ctx.Config()
+	df.keys = conf.GetStringSlice("keys", nil)
+	df.ttl = conf.GetDuration("ttl", time.Minute)
+	if df.ttl <= 0 {
+		return fmt.Errorf("dedup: ttl should be greater than 0")
+	}
+	maxKeys := conf.GetInt("max_keys", 10000)
+	if maxKeys < 1 {
+		return fmt.Errorf("dedup: max_keys should be greater than 0")
+	}
+	df.seen = newKeyLRU[time.Time](maxKeys)
+	return nil
+}
+
+func (df *dedupFlow) Close() error     { return nil }
+func (df *dedupFlow) Parallelism() int { return 1 }
+
+// Dropped returns the number of records dropped
+func (df *dedupFlow) Dropped() uint64 {
+	return atomic.LoadUint64(&df.dropped)
+}
+
+func (df *dedupFlow) Process(recs []engine.Record, nextFunc engine.FlowNextFunc) {
+	ret := make([]engine.Record, 0, len(recs))
+	now := engine.Now()
+	for _, r := range recs {
+		key := recordKey(r, df.keys)
+		if last, ok := df.seen.Get(key); ok && now.Sub(last) < df.ttl {
+			atomic.AddUint64(&df.dropped, 1)
+			continue
+		}
+		df.seen.Put(key, now)
+		ret = append(ret, r)
+	}
+	nextFunc(ret, nil)
+}
@@ -0,0 +1,72 @@
+package base_test
+
+import (
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+)
+
+func ExampleDedupFlow() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"a,1",
+			"b,2",
+			"a,1",
+			"c,3",
+			"b,2",
+			"a,4",
+		]
+		format = "csv"
+		fields = ["name", "value"]
+	[[flows.dedup]]
+		ttl = "1m"
+	[[outlets.file]]
+		path = "-"
+		format = "csv"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// a,1
+	// b,2
+	// c,3
+	// a,4
+}
+
+func ExampleDedupFlow_keys() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"a,1",
+			"b,2",
+			"a,3",
+			"c,4",
+		]
+		format = "csv"
+		fields = ["name", "value"]
+	[[flows.dedup]]
+		keys = ["name"]
+		ttl = "1m"
+		max_keys = 1
+	[[outlets.file]]
+		path = "-"
+		format = "csv"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// a,1
+	// b,2
+	// a,3
+	// c,4
+}
@@ -0,0 +1,84 @@
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+type encodeFlow struct {
+	ctx        *engine.Context
+	field      string
+	fields     []string
+	dropFields bool
+}
+
+var _ = engine.Flow((*encodeFlow)(nil))
+
+func EncodeFlow(ctx *engine.Context) engine.Flow {
+	return &encodeFlow{ctx: ctx}
+}
+
+func (ef *encodeFlow) Open() error {
+	conf := ef.ctx.Config()
+	ef.field = conf.GetString("field", "")
+	ef.fields = conf.GetStringSlice("fields", nil)
+	ef.dropFields = conf.GetBool("drop_fields", false)
+	if ef.field == "" {
+		return fmt.Errorf("encode: field is required")
+	}
+	format := conf.GetString("format", "json")
+	if engine.GetEncoder(format) == nil {
+		return fmt.Errorf("encode: format %q not found", format)
+	}
+	return nil
+}
+
+func (ef *encodeFlow) Close() error     { return nil }
+func (ef *encodeFlow) Parallelism() int { return 1 }
+
+func (ef *encodeFlow) Process(recs []engine.Record, nextFunc engine.FlowNextFunc) {
+	ret := make([]engine.Record, 0, len(recs))
+	buff := &bytes.Buffer{}
+	for _, r := range recs {
+		// if fields is empty, all fields are encoded
+		selected := []*engine.Field{}
+		for _, f := range r.Fields(ef.fields...) {
+			if f != nil {
+				selected = append(selected, f)
+			}
+		}
+		buff.Reset()
+		w, err := engine.NewWriter(buff, ef.ctx.Config())
+		if err == nil {
+			err = w.Write([]engine.Record{engine.NewRecord(selected...)})
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			ef.ctx.LogWarn("flows.encode", "field", ef.field, "error", err.Error())
+			ret = append(ret, r)
+			continue
+		}
+		encoded := engine.NewField(ef.field, strings.TrimSuffix(buff.String(), "\n"))
+		if ef.dropFields {
+			fields := []*engine.Field{}
+			for _, f := range r.Fields() {
+				if f == nil || slices.Contains(selected, f) {
+					continue
+				}
+				fields = append(fields, f)
+			}
+			rec := engine.NewRecord(fields...).AppendOrReplace(encoded)
+			rec.Tags().Merge(r.Tags())
+			ret = append(ret, rec)
+		} else {
+			ret = append(ret, r.AppendOrReplace(encoded))
+		}
+	}
+	nextFunc(ret, nil)
+}
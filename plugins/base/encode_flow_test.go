@@ -0,0 +1,37 @@
+package base_test
+
+import (
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+)
+
+func ExampleEncodeFlow() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"a,1,true",
+			"b,2,false",
+		]
+		format = "csv"
+		fields = ["name", "value", "flag"]
+		types = ["string", "int", "bool"]
+	[[flows.encode]]
+		field = "payload"
+		format = "csv"
+		fields = ["name", "value"]
+		drop_fields = true
+	[[outlets.file]]
+		path = "-"
+		format = "json"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// {"flag":true,"payload":"a,1"}
+	// {"flag":false,"payload":"b,2"}
+}
@@ -3,6 +3,7 @@ package base
 import (
 	gojson "encoding/json"
 	"fmt"
+	"sort"
 
 	"github.com/OutOfBedlam/tine/engine"
 	"github.com/OutOfBedlam/tine/util"
@@ -15,8 +16,9 @@ func init() {
 		ContentType: "application/x-ndjson",
 	})
 	engine.RegisterDecoder(&engine.DecoderReg{
-		Name:    "json",
-		Factory: NewJSONDecoder,
+		Name:         "json",
+		Factory:      NewJSONDecoder,
+		ContentTypes: []string{"application/json", "application/x-ndjson"},
 	})
 }
 
@@ -108,19 +110,57 @@ func (jd *JSONDecoder) Decode() ([]engine.Record, error) {
 	return ret, retErr
 }
 
+// map2Record converts a JSON object into a record,
+// nested objects and arrays are flattened into "parent.child" and "array[i]" fields.
 func map2Record(m map[string]any) (engine.Record, error) {
-	rec := engine.NewRecord()
-	for k, val := range m {
-		switch v := val.(type) {
-		case string:
-			rec = rec.Append(engine.NewField(k, v))
-		case float64:
-			rec = rec.Append(engine.NewField(k, v))
-		case bool:
-			rec = rec.Append(engine.NewField(k, v))
-		default:
-			return nil, fmt.Errorf("unsupported type %T", v)
+	fields, err := json2Fields("", m)
+	if err != nil {
+		return nil, err
+	}
+	return engine.NewRecord(fields...), nil
+}
+
+func json2Fields(prefix string, m map[string]any) ([]*engine.Field, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ret := []*engine.Field{}
+	for _, k := range keys {
+		fields, err := json2Field(prefix+k, m[k])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, fields...)
+	}
+	return ret, nil
+}
+
+func json2Field(name string, val any) ([]*engine.Field, error) {
+	switch v := val.(type) {
+	case string:
+		return []*engine.Field{engine.NewField(name, v)}, nil
+	case float64:
+		return []*engine.Field{engine.NewField(name, v)}, nil
+	case bool:
+		return []*engine.Field{engine.NewField(name, v)}, nil
+	case nil:
+		return []*engine.Field{engine.NewFieldWithValue(name, engine.NewUntypedNullValue())}, nil
+	case map[string]any:
+		return json2Fields(name+".", v)
+	case []any:
+		ret := []*engine.Field{}
+		for i, elm := range v {
+			fields, err := json2Field(fmt.Sprintf("%s[%d]", name, i), elm)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, fields...)
 		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
 	}
-	return rec, nil
 }
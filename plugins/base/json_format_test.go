@@ -138,3 +138,31 @@ func ExampleJSONDecoder_data() {
 	// {"_in":"file","_ts":1721954797,"area":"a","bval":true,"fval":1.23,"ival":1.00,"time":"2020-01-01T00:00:00Z"}
 	// {"_in":"file","_ts":1721954797,"area":"b","bval":true,"fval":2.35,"ival":2.00,"time":"2020-01-02T00:00:00Z"}
 }
+
+func ExampleJSONDecoder_nested() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			'{"area": "a", "loc": {"lat": 37.5, "lon": 127.0}, "tags": ["x", "y"], "note": null}',
+		]
+		format = "json"
+	[[flows.select]]
+		includes = ["**"]
+	[[outlets.file]]
+		path = "-"
+		format = "json"
+	`
+	// Make the output timestamp deterministic, so we can compare it
+	// This line is required only for testing
+	engine.Now = func() time.Time { return time.Unix(1721954797, 0) }
+	// Create a new pipeline
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// {"_in":"file","_ts":1721954797,"area":"a","loc.lat":37.5,"loc.lon":127,"note":null,"tags[0]":"x","tags[1]":"y"}
+}
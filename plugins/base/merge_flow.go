@@ -17,6 +17,7 @@ func MergeFlow(ctx *engine.Context) engine.Flow {
 		joinTag:       engine.TAG_TIMESTAMP,
 		namePrefixTag: engine.TAG_INLET,
 		nameInfix:     nameInfix,
+		firsts:        map[int64]uint64{},
 	}
 }
 
@@ -27,15 +28,28 @@ type mergeFlow struct {
 	joinTag       string // joinTag should be time.Time type, for now.
 	namePrefixTag string
 	nameInfix     string
+	received      uint64
+	// sequence of the first received record of each row
+	firsts map[int64]uint64
 }
 
 var _ = engine.Flow((*mergeFlow)(nil))
 var _ = engine.BufferedFlow((*mergeFlow)(nil))
+var _ = engine.PartialFlow((*mergeFlow)(nil))
 
 func (mf *mergeFlow) Open() error      { return nil }
 func (mf *mergeFlow) Close() error     { return nil }
 func (mf *mergeFlow) Parallelism() int { return 1 }
 
+// Released returns the sequence of the first record of the oldest row waiting to be merged
+func (mf *mergeFlow) Released() uint64 {
+	ret := mf.received
+	for _, seq := range mf.firsts {
+		ret = min(ret, seq)
+	}
+	return ret
+}
+
 func (mf *mergeFlow) Flush(cb engine.FlowNextFunc) {
 	ret := []engine.Record{}
 	for _, r := range mf.table.Rows() {
@@ -46,6 +60,8 @@ func (mf *mergeFlow) Flush(cb engine.FlowNextFunc) {
 
 func (mf *mergeFlow) Process(records []engine.Record, nextFunc engine.FlowNextFunc) {
 	for _, rec := range records {
+		seq := mf.received
+		mf.received++
 		var tsValue *engine.Value
 		var ts time.Time
 		if v := rec.Tags().Get(mf.joinTag); v == nil {
@@ -58,6 +74,9 @@ func (mf *mergeFlow) Process(records []engine.Record, nextFunc engine.FlowNextFu
 				ts = t
 			}
 		}
+		if _, ok := mf.firsts[ts.Unix()]; !ok {
+			mf.firsts[ts.Unix()] = seq
+		}
 		var namePrefix string
 		if v := rec.Tags().Get(mf.namePrefixTag); v != nil && !v.IsNull() {
 			if s, ok := v.String(); ok {
@@ -83,6 +102,7 @@ func (mf *mergeFlow) Process(records []engine.Record, nextFunc engine.FlowNextFu
 
 	ret := []engine.Record{}
 	for _, k := range selected.Keys() {
+		delete(mf.firsts, k)
 		row := selected.Get(k)
 		r := engine.NewRecord(row.Fields...)
 		r.Tags().Set(mf.joinTag, engine.NewValue(time.Unix(k, 0)))
@@ -0,0 +1,216 @@
+package base
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+type multilineFlow struct {
+	ctx             *engine.Context
+	field           string
+	keys            []string
+	startPattern    *regexp.Regexp
+	continuePattern *regexp.Regexp
+	separator       string
+	maxLines        int
+	timeout         time.Duration
+
+	mutex    sync.Mutex
+	pending  map[string]*multilineEvent
+	seq      int64
+	received uint64
+	nextFunc engine.FlowNextFunc
+	flushed  bool
+	closeCh  chan struct{}
+	closeWg  sync.WaitGroup
+}
+
+// multilineEvent is a record that continuation lines are being joined to
+type multilineEvent struct {
+	seq   int64
+	first uint64 // sequence of the first line in the received records
+	rec   engine.Record
+	lines []string
+	last  time.Time
+}
+
+var _ = engine.Flow((*multilineFlow)(nil))
+var _ = engine.BufferedFlow((*multilineFlow)(nil))
+var _ = engine.PartialFlow((*multilineFlow)(nil))
+
+func MultilineFlow(ctx *engine.Context) engine.Flow {
+	return &multilineFlow{ctx: ctx}
+}
+
+func (mf *multilineFlow) Open() error {
+	conf := mf.ctx.Config()
+	mf.field = conf.GetString("field", "message")
+	mf.keys = conf.GetStringSlice("keys", nil)
+	mf.separator = conf.GetString("separator", "\n")
+	mf.maxLines = conf.GetInt("max_lines", 500)
+	mf.timeout = conf.GetDuration("timeout", 3*time.Second)
+
+	startPattern := conf.GetString("start_pattern", "")
+	continuePattern := conf.GetString("continue_pattern", "")
+	if startPattern == "" && continuePattern == "" {
+		return fmt.Errorf("multiline: start_pattern or continue_pattern is required")
+	}
+	if startPattern != "" && continuePattern != "" {
+		return fmt.Errorf("multiline: start_pattern and continue_pattern cannot be set at the same time")
+	}
+	if startPattern != "" {
+		if re, err := regexp.Compile(startPattern); err != nil {
+			return fmt.Errorf("multiline: invalid start_pattern, %w", err)
+		} else {
+			mf.startPattern = re
+		}
+	}
+	if continuePattern != "" {
+		if re, err := regexp.Compile(continuePattern); err != nil {
+			return fmt.Errorf("multiline: invalid continue_pattern, %w", err)
+		} else {
+			mf.continuePattern = re
+		}
+	}
+	if mf.maxLines < 1 {
+		return fmt.Errorf("multiline: max_lines should be greater than 0")
+	}
+	mf.pending = make(map[string]*multilineEvent)
+
+	if mf.timeout > 0 {
+		mf.closeCh = make(chan struct{})
+		mf.closeWg.Add(1)
+		go mf.watch()
+	}
+	return nil
+}
+
+func (mf *multilineFlow) Close() error {
+	if mf.closeCh != nil {
+		close(mf.closeCh)
+		mf.closeWg.Wait()
+		mf.closeCh = nil
+	}
+	return nil
+}
+
+func (mf *multilineFlow) Parallelism() int { return 1 }
+
+// watch flushes the pending events those have not been continued within the timeout
+func (mf *multilineFlow) watch() {
+	defer mf.closeWg.Done()
+	ticker := time.NewTicker(mf.timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mf.closeCh:
+			return
+		case now := <-ticker.C:
+			mf.mutex.Lock()
+			if mf.flushed || mf.nextFunc == nil {
+				mf.mutex.Unlock()
+				continue
+			}
+			ret := mf.take(func(ev *multilineEvent) bool { return now.Sub(ev.last) >= mf.timeout })
+			if len(ret) > 0 {
+				mf.nextFunc(ret, nil)
+			}
+			mf.mutex.Unlock()
+		}
+	}
+}
+
+func (mf *multilineFlow) Process(recs []engine.Record, nextFunc engine.FlowNextFunc) {
+	mf.mutex.Lock()
+	defer mf.mutex.Unlock()
+	mf.nextFunc = nextFunc
+
+	ret := []engine.Record{}
+	now := time.Now()
+	for _, r := range recs {
+		seq := mf.received
+		mf.received++
+		f := r.Field(mf.field)
+		if f == nil {
+			// not a line, pass it through
+			ret = append(ret, r)
+			continue
+		}
+		line, _ := f.Value.String()
+		key := ""
+		if len(mf.keys) > 0 {
+			key = recordKey(r, mf.keys)
+		}
+		ev := mf.pending[key]
+
+		isContinue := false
+		if mf.startPattern != nil {
+			isContinue = !mf.startPattern.MatchString(line)
+		} else {
+			isContinue = mf.continuePattern.MatchString(line)
+		}
+
+		if ev != nil && isContinue {
+			ev.lines = append(ev.lines, line)
+			ev.last = now
+		} else {
+			if ev != nil {
+				ret = append(ret, mf.joined(ev))
+			}
+			mf.seq++
+			ev = &multilineEvent{seq: mf.seq, first: seq, rec: r, lines: []string{line}, last: now}
+			mf.pending[key] = ev
+		}
+		if len(ev.lines) >= mf.maxLines {
+			ret = append(ret, mf.joined(ev))
+			delete(mf.pending, key)
+		}
+	}
+	nextFunc(ret, nil)
+}
+
+// Released returns the sequence of the first line of the oldest event being joined
+func (mf *multilineFlow) Released() uint64 {
+	mf.mutex.Lock()
+	defer mf.mutex.Unlock()
+	ret := mf.received
+	for _, ev := range mf.pending {
+		ret = min(ret, ev.first)
+	}
+	return ret
+}
+
+func (mf *multilineFlow) Flush(nextFunc engine.FlowNextFunc) {
+	mf.mutex.Lock()
+	defer mf.mutex.Unlock()
+	mf.flushed = true
+	nextFunc(mf.take(func(*multilineEvent) bool { return true }), nil)
+}
+
+// take removes the pending events that match the cond,
+// and returns the joined records in order of arrival.
+func (mf *multilineFlow) take(cond func(*multilineEvent) bool) []engine.Record {
+	list := []*multilineEvent{}
+	for k, ev := range mf.pending {
+		if cond(ev) {
+			list = append(list, ev)
+			delete(mf.pending, k)
+		}
+	}
+	slices.SortFunc(list, func(a, b *multilineEvent) int { return int(a.seq - b.seq) })
+	ret := make([]engine.Record, len(list))
+	for i, ev := range list {
+		ret[i] = mf.joined(ev)
+	}
+	return ret
+}
+
+func (mf *multilineFlow) joined(ev *multilineEvent) engine.Record {
+	return ev.rec.AppendOrReplace(engine.NewField(mf.field, strings.Join(ev.lines, mf.separator)))
+}
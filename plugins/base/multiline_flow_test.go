@@ -0,0 +1,76 @@
+package base_test
+
+import (
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+)
+
+func ExampleMultilineFlow() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"a,Exception in thread main java.lang.NullPointerException",
+			"b,hello",
+			"a,\tat com.example.Foo.bar(Foo.java:10)",
+			"a,\tat com.example.Foo.main(Foo.java:5)",
+			"b,world",
+			"a,done",
+		]
+		format = "csv"
+		fields = ["src", "message"]
+	[[flows.multiline]]
+		field = "message"
+		start_pattern = '^\S'
+		keys = ["src"]
+		timeout = "10s"
+	[[outlets.file]]
+		path = "-"
+		format = "json"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// {"message":"hello","src":"b"}
+	// {"message":"Exception in thread main java.lang.NullPointerException\n\tat com.example.Foo.bar(Foo.java:10)\n\tat com.example.Foo.main(Foo.java:5)","src":"a"}
+	// {"message":"world","src":"b"}
+	// {"message":"done","src":"a"}
+}
+
+func ExampleMultilineFlow_maxLines() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"line1",
+			"  line2",
+			"  line3",
+			"  line4",
+			"line5",
+		]
+		format = "csv"
+		fields = ["message"]
+	[[flows.multiline]]
+		continue_pattern = '^\s'
+		separator = "|"
+		max_lines = 3
+		timeout = "0s"
+	[[outlets.file]]
+		path = "-"
+		format = "json"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// {"message":"line1|  line2|  line3"}
+	// {"message":"  line4"}
+	// {"message":"line5"}
+}
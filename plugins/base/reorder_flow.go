@@ -0,0 +1,205 @@
+package base
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+type reorderFlow struct {
+	ctx         *engine.Context
+	lateness    time.Duration
+	timeField   string
+	lateTag     string
+	bufferLimit int
+	idleTimeout time.Duration
+
+	mutex     sync.Mutex
+	buffer    []reorderItem
+	watermark time.Time
+	dropped   uint64
+	lastRecv  time.Time
+	received  uint64
+	nextFunc  engine.FlowNextFunc
+	flushed   bool
+	closeCh   chan struct{}
+	closeWg   sync.WaitGroup
+}
+
+type reorderItem struct {
+	ts  time.Time
+	rec engine.Record
+	// sequence of the record in the received order
+	seq uint64
+}
+
+var _ = engine.Flow((*reorderFlow)(nil))
+var _ = engine.BufferedFlow((*reorderFlow)(nil))
+var _ = engine.PartialFlow((*reorderFlow)(nil))
+var _ = engine.DroppingFlow((*reorderFlow)(nil))
+
+func ReorderFlow(ctx *engine.Context) engine.Flow {
+	return &reorderFlow{ctx: ctx}
+}
+
+func (rf *reorderFlow) Open() error {
+	conf := rf.ctx.Config()
+	rf.lateness = conf.GetDuration("lateness", 5*time.Second)
+	rf.timeField = conf.GetString("time_field", "")
+	rf.lateTag = conf.GetString("late_tag", "")
+	rf.bufferLimit = conf.GetInt("buffer_limit", 10000)
+	rf.idleTimeout = conf.GetDuration("idle_timeout", rf.lateness)
+	if rf.lateness < 0 {
+		return fmt.Errorf("reorder: lateness should not be negative")
+	}
+	if rf.bufferLimit < 1 {
+		return fmt.Errorf("reorder: buffer_limit should be greater than 0")
+	}
+	if rf.idleTimeout > 0 {
+		rf.closeCh = make(chan struct{})
+		rf.closeWg.Add(1)
+		go rf.watch()
+	}
+	return nil
+}
+
+func (rf *reorderFlow) Close() error {
+	if rf.closeCh != nil {
+		close(rf.closeCh)
+		rf.closeWg.Wait()
+		rf.closeCh = nil
+	}
+	return nil
+}
+
+func (rf *reorderFlow) Parallelism() int { return 1 }
+
+// watch releases the buffered records if no record has arrived within the idle_timeout,
+// the watermark moves to the last released record.
+func (rf *reorderFlow) watch() {
+	defer rf.closeWg.Done()
+	ticker := time.NewTicker(rf.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rf.closeCh:
+			return
+		case now := <-ticker.C:
+			rf.mutex.Lock()
+			if rf.flushed || rf.nextFunc == nil || len(rf.buffer) == 0 || now.Sub(rf.lastRecv) < rf.idleTimeout {
+				rf.mutex.Unlock()
+				continue
+			}
+			rf.watermark = rf.buffer[len(rf.buffer)-1].ts
+			rf.nextFunc(rf.release(len(rf.buffer)), nil)
+			rf.mutex.Unlock()
+		}
+	}
+}
+
+// Released returns the sequence of the oldest received record in the buffer
+func (rf *reorderFlow) Released() uint64 {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	ret := rf.received
+	for _, item := range rf.buffer {
+		ret = min(ret, item.seq)
+	}
+	return ret
+}
+
+// Dropped returns the number of records dropped,
+// because they have no timestamp or arrived too late.
+func (rf *reorderFlow) Dropped() uint64 {
+	return atomic.LoadUint64(&rf.dropped)
+}
+
+func (rf *reorderFlow) timestamp(r engine.Record) (time.Time, bool) {
+	var v *engine.Value
+	if rf.timeField != "" {
+		if f := r.Field(rf.timeField); f != nil {
+			v = f.Value
+		}
+	} else {
+		v = r.Tags().Get(engine.TAG_TIMESTAMP)
+	}
+	if v == nil || v.IsNull() {
+		return time.Time{}, false
+	}
+	return v.Time()
+}
+
+func (rf *reorderFlow) Process(recs []engine.Record, nextFunc engine.FlowNextFunc) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	rf.nextFunc = nextFunc
+	rf.lastRecv = time.Now()
+
+	ret := []engine.Record{}
+	for _, r := range recs {
+		seq := rf.received
+		rf.received++
+		ts, ok := rf.timestamp(r)
+		if !ok {
+			atomic.AddUint64(&rf.dropped, 1)
+			continue
+		}
+		if !rf.watermark.IsZero() && ts.Before(rf.watermark) {
+			// the records before the watermark are already released
+			if rf.lateTag == "" {
+				atomic.AddUint64(&rf.dropped, 1)
+			} else {
+				r.Tags().Set(rf.lateTag, engine.NewValue(true))
+				ret = append(ret, r)
+			}
+			continue
+		}
+		// keep the arrival order of the records that have the same timestamp
+		idx, _ := slices.BinarySearchFunc(rf.buffer, ts, func(item reorderItem, t time.Time) int {
+			if item.ts.After(t) {
+				return 1
+			}
+			return -1
+		})
+		rf.buffer = slices.Insert(rf.buffer, idx, reorderItem{ts: ts, rec: r, seq: seq})
+		if wm := ts.Add(-rf.lateness); wm.After(rf.watermark) {
+			rf.watermark = wm
+		}
+	}
+
+	n, _ := slices.BinarySearchFunc(rf.buffer, rf.watermark, func(item reorderItem, t time.Time) int {
+		if item.ts.After(t) {
+			return 1
+		}
+		return -1
+	})
+	if over := len(rf.buffer) - rf.bufferLimit; over > n {
+		// the buffer is full, release the oldest records
+		// and move the watermark forward
+		n = over
+		rf.watermark = rf.buffer[n-1].ts
+	}
+	ret = append(ret, rf.release(n)...)
+	nextFunc(ret, nil)
+}
+
+func (rf *reorderFlow) Flush(nextFunc engine.FlowNextFunc) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	rf.flushed = true
+	nextFunc(rf.release(len(rf.buffer)), nil)
+}
+
+// release returns the first n records of the buffer
+func (rf *reorderFlow) release(n int) []engine.Record {
+	ret := make([]engine.Record, n)
+	for i := 0; i < n; i++ {
+		ret[i] = rf.buffer[i].rec
+	}
+	rf.buffer = slices.Delete(rf.buffer, 0, n)
+	return ret
+}
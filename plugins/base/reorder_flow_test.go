@@ -0,0 +1,93 @@
+package base_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleReorderFlow() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"100,a",
+			"103,b",
+			"101,c",
+			"108,d",
+			"104,e",
+			"95,f",
+			"110,g",
+		]
+		format = "csv"
+		fields = ["time", "name"]
+		types = ["time", "string"]
+	[[flows.reorder]]
+		lateness = "5s"
+		time_field = "time"
+		late_tag = "late"
+	[[flows.select]]
+		includes = ["*", "#late"]
+	[[outlets.file]]
+		path = "-"
+		format = "json"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// {"late":true,"name":"f","time":95}
+	// {"late":null,"name":"a","time":100}
+	// {"late":null,"name":"c","time":101}
+	// {"late":null,"name":"b","time":103}
+	// {"late":null,"name":"e","time":104}
+	// {"late":null,"name":"d","time":108}
+	// {"late":null,"name":"g","time":110}
+}
+
+func TestReorderFlowIdle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.csv")
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+	dsl := fmt.Sprintf(`
+	[[inlets.tail]]
+		paths = [%q]
+		poll_interval = "50ms"
+		format = "csv"
+		fields = ["time", "name"]
+		types = ["time", "string"]
+	[[flows.reorder]]
+		lateness = "1h"
+		time_field = "time"
+		idle_timeout = "200ms"
+	[[outlets.file]]
+		format = "csv"
+	`, path)
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+	defer pipeline.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	// the watermark does not pass the records within the lateness,
+	// those are released in order after the idle_timeout
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("102,b\n100,a\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	expect := "100,a\n102,b\n"
+	for i := 0; i < 100 && out.String() != expect; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Equal(t, expect, out.String())
+}
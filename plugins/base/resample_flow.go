@@ -0,0 +1,451 @@
+package base
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+type resampleFlow struct {
+	ctx         *engine.Context
+	step        time.Duration
+	lateness    time.Duration
+	keys        []string
+	fields      []string
+	timeField   string
+	aggregate   string
+	fill        string
+	fillValue   *engine.Value
+	fillLimit   int
+	idleTimeout time.Duration
+
+	mutex     sync.Mutex
+	series    *keyLRU[*resampleSeries]
+	evicted   []*resampleSeries // evicted series those buckets are not emitted yet
+	seriesSeq uint64
+	watermark time.Time
+	dropped   uint64
+	received  uint64
+	lastRecv  time.Time
+	nextFunc  engine.FlowNextFunc
+	flushed   bool
+	closeCh   chan struct{}
+	closeWg   sync.WaitGroup
+}
+
+var _ = engine.Flow((*resampleFlow)(nil))
+var _ = engine.BufferedFlow((*resampleFlow)(nil))
+var _ = engine.PartialFlow((*resampleFlow)(nil))
+var _ = engine.DroppingFlow((*resampleFlow)(nil))
+
+// resampleSeries holds the open buckets of a series identified by the keys
+type resampleSeries struct {
+	id        uint64 // creation order of the series
+	keyFields []*engine.Field
+	names     []string
+	buckets   map[int64]*resampleBucket
+	last      *resampleBucket // the last emitted bucket
+}
+
+// resampleBucket aggregates the values of records those _ts are snapped to the same time
+type resampleBucket struct {
+	ts     time.Time
+	seq    uint64 // sequence of the first received record of the bucket
+	tags   engine.Tags
+	count  int
+	first  []*engine.Value
+	last   []*engine.Value
+	sum    []float64
+	min    []float64
+	max    []float64
+	nums   []int
+	result []*engine.Value
+}
+
+func ResampleFlow(ctx *engine.Context) engine.Flow {
+	return &resampleFlow{ctx: ctx}
+}
+
+func (rf *resampleFlow) Open() error {
+	conf := rf.ctx.Config()
+	rf.step = conf.GetDuration("step", 10*time.Second)
+	rf.lateness = conf.GetDuration("lateness", 0)
+	rf.keys = conf.GetStringSlice("keys", nil)
+	rf.fields = conf.GetStringSlice("fields", nil)
+	rf.timeField = conf.GetString("time_field", "")
+	rf.aggregate = strings.ToLower(conf.GetString("aggregate", "avg"))
+	rf.fill = strings.ToLower(conf.GetString("fill", "null"))
+	rf.fillLimit = conf.GetInt("fill_limit", 1000)
+	rf.idleTimeout = conf.GetDuration("idle_timeout", rf.step+rf.lateness)
+	if rf.step <= 0 {
+		return fmt.Errorf("resample: step should be greater than 0")
+	}
+	if rf.lateness < 0 {
+		return fmt.Errorf("resample: lateness should not be negative")
+	}
+	switch rf.aggregate {
+	case "avg", "sum", "min", "max", "first", "last", "count":
+	default:
+		return fmt.Errorf("resample: unknown aggregate %q", rf.aggregate)
+	}
+	switch rf.fill {
+	case "none", "null", "previous", "linear":
+	case "value":
+		rf.fillValue = conf.GetValue("fill_value")
+		if rf.fillValue.IsNull() {
+			return fmt.Errorf("resample: fill_value is required for fill \"value\"")
+		}
+	default:
+		return fmt.Errorf("resample: unknown fill %q", rf.fill)
+	}
+	// the least recently updated series is evicted when it exceeds max_keys,
+	// its open buckets are emitted
+	rf.series = newKeyLRU[*resampleSeries](conf.GetInt("max_keys", 10000))
+	rf.series.onEvict = func(_ string, ser *resampleSeries) {
+		if len(ser.buckets) > 0 {
+			rf.evicted = append(rf.evicted, ser)
+		}
+	}
+	if rf.idleTimeout > 0 {
+		rf.closeCh = make(chan struct{})
+		rf.closeWg.Add(1)
+		go rf.watch()
+	}
+	return nil
+}
+
+func (rf *resampleFlow) Close() error {
+	if rf.closeCh != nil {
+		close(rf.closeCh)
+		rf.closeWg.Wait()
+		rf.closeCh = nil
+	}
+	return nil
+}
+
+func (rf *resampleFlow) Parallelism() int { return 1 }
+
+// watch closes the open buckets if no record has arrived within the idle_timeout,
+// the watermark moves to the end of the last closed bucket.
+func (rf *resampleFlow) watch() {
+	defer rf.closeWg.Done()
+	ticker := time.NewTicker(rf.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rf.closeCh:
+			return
+		case now := <-ticker.C:
+			rf.mutex.Lock()
+			if rf.flushed || rf.nextFunc == nil || now.Sub(rf.lastRecv) < rf.idleTimeout {
+				rf.mutex.Unlock()
+				continue
+			}
+			for _, e := range rf.series.items {
+				for _, b := range e.Value.(*keyLRUEntry[*resampleSeries]).value.buckets {
+					if end := b.ts.Add(rf.step); end.After(rf.watermark) {
+						rf.watermark = end
+					}
+				}
+			}
+			if recs := rf.emit(false); len(recs) > 0 {
+				rf.nextFunc(recs, nil)
+			}
+			rf.mutex.Unlock()
+		}
+	}
+}
+
+// Released returns the sequence of the first record of the oldest open bucket
+func (rf *resampleFlow) Released() uint64 {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	ret := rf.received
+	for _, ser := range rf.evicted {
+		for _, b := range ser.buckets {
+			ret = min(ret, b.seq)
+		}
+	}
+	for _, e := range rf.series.items {
+		for _, b := range e.Value.(*keyLRUEntry[*resampleSeries]).value.buckets {
+			ret = min(ret, b.seq)
+		}
+	}
+	return ret
+}
+
+// Dropped returns the number of records dropped,
+// because they have no timestamp or arrived after the bucket was closed.
+func (rf *resampleFlow) Dropped() uint64 {
+	return atomic.LoadUint64(&rf.dropped)
+}
+
+func (rf *resampleFlow) Process(recs []engine.Record, nextFunc engine.FlowNextFunc) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	rf.nextFunc = nextFunc
+	rf.lastRecv = time.Now()
+	for _, r := range recs {
+		rf.add(r)
+	}
+	nextFunc(rf.emit(false), nil)
+}
+
+func (rf *resampleFlow) Flush(nextFunc engine.FlowNextFunc) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	rf.flushed = true
+	nextFunc(rf.emit(true), nil)
+}
+
+func (rf *resampleFlow) timestamp(r engine.Record) (time.Time, bool) {
+	var v *engine.Value
+	if rf.timeField != "" {
+		if f := r.Field(rf.timeField); f != nil {
+			v = f.Value
+		}
+	} else {
+		v = r.Tags().Get(engine.TAG_TIMESTAMP)
+	}
+	if v == nil || v.IsNull() {
+		return time.Time{}, false
+	}
+	return v.Time()
+}
+
+func (rf *resampleFlow) add(r engine.Record) {
+	seq := rf.received
+	rf.received++
+	raw, ok := rf.timestamp(r)
+	if !ok {
+		atomic.AddUint64(&rf.dropped, 1)
+		return
+	}
+	ts := raw.Truncate(rf.step)
+
+	// if no keys specified, all records are in a single series
+	key := ""
+	if len(rf.keys) > 0 {
+		key = recordKey(r, rf.keys)
+	}
+	ser, exists := rf.series.Get(key)
+	if !exists {
+		rf.seriesSeq++
+		ser = &resampleSeries{id: rf.seriesSeq, buckets: make(map[int64]*resampleBucket)}
+		for _, k := range rf.keys {
+			if strings.HasPrefix(k, "#") {
+				continue
+			}
+			if f := r.Field(k); f != nil {
+				ser.keyFields = append(ser.keyFields, f.Clone())
+			}
+		}
+		rf.series.Put(key, ser)
+	}
+	if ser.last != nil && !ts.After(ser.last.ts) {
+		// the bucket is already closed
+		atomic.AddUint64(&rf.dropped, 1)
+		return
+	}
+
+	// value fields of the series are fixed by the first record
+	// if "fields" is not specified
+	if ser.names == nil {
+		if len(rf.fields) > 0 {
+			ser.names = rf.fields
+		} else {
+			for _, f := range r.Fields() {
+				if f == nil || strings.EqualFold(f.Name, rf.timeField) || slices.Contains(rf.keys, f.Name) {
+					continue
+				}
+				ser.names = append(ser.names, f.Name)
+			}
+		}
+	}
+
+	b, ok := ser.buckets[ts.UnixNano()]
+	if !ok {
+		n := len(ser.names)
+		b = &resampleBucket{
+			ts:    ts,
+			seq:   seq,
+			first: make([]*engine.Value, n),
+			last:  make([]*engine.Value, n),
+			sum:   make([]float64, n),
+			min:   make([]float64, n),
+			max:   make([]float64, n),
+			nums:  make([]int, n),
+		}
+		ser.buckets[ts.UnixNano()] = b
+	}
+	b.tags = r.Tags()
+	b.count++
+	for i, f := range r.Fields(ser.names...) {
+		if f == nil || f.IsNull() {
+			continue
+		}
+		if b.first[i] == nil {
+			b.first[i] = f.Value
+		}
+		b.last[i] = f.Value
+		if fv, ok := f.Value.Float64(); ok {
+			if b.nums[i] == 0 || fv < b.min[i] {
+				b.min[i] = fv
+			}
+			if b.nums[i] == 0 || fv > b.max[i] {
+				b.max[i] = fv
+			}
+			b.sum[i] += fv
+			b.nums[i]++
+		}
+	}
+
+	// watermark is the max seen time minus the allowed lateness
+	if wm := raw.Add(-rf.lateness); wm.After(rf.watermark) {
+		rf.watermark = wm
+	}
+}
+
+// emit returns the records of the closed buckets and the filled gaps in order of time,
+// the records of the same time are in the order of the series created.
+// If force is true, all buckets are closed, the buckets of the evicted series are always closed.
+//
+// The order is kept within the records returned at once, the gaps of a series
+// that has been quiet are filled when its next bucket is closed, so they can be
+// earlier than the records of the other series returned before.
+func (rf *resampleFlow) emit(force bool) []engine.Record {
+	type closed struct {
+		ser *resampleSeries
+		b   *resampleBucket
+	}
+	list := []closed{}
+	for _, ser := range rf.evicted {
+		for _, b := range ser.buckets {
+			list = append(list, closed{ser: ser, b: b})
+		}
+		clear(ser.buckets)
+	}
+	rf.evicted = nil
+	for _, e := range rf.series.items {
+		ser := e.Value.(*keyLRUEntry[*resampleSeries]).value
+		for k, b := range ser.buckets {
+			// a bucket is closed when the watermark passes the end of the bucket
+			if force || !b.ts.Add(rf.step).After(rf.watermark) {
+				list = append(list, closed{ser: ser, b: b})
+				delete(ser.buckets, k)
+			}
+		}
+	}
+	slices.SortFunc(list, func(a, b closed) int {
+		return cmp.Or(a.b.ts.Compare(b.b.ts), cmp.Compare(a.ser.id, b.ser.id))
+	})
+
+	type emitted struct {
+		ts  time.Time
+		id  uint64
+		rec engine.Record
+	}
+	out := []emitted{}
+	for _, c := range list {
+		ser, b := c.ser, c.b
+		rf.result(ser, b)
+		if ser.last != nil && rf.fill != "none" {
+			gap := 0
+			for ts := ser.last.ts.Add(rf.step); ts.Before(b.ts) && gap < rf.fillLimit; ts = ts.Add(rf.step) {
+				out = append(out, emitted{ts: ts, id: ser.id, rec: rf.record(ser, ts, ser.last.tags, rf.filled(ser, ts, b))})
+				gap++
+			}
+		}
+		out = append(out, emitted{ts: b.ts, id: ser.id, rec: rf.record(ser, b.ts, b.tags, b.result)})
+		ser.last = b
+	}
+	slices.SortStableFunc(out, func(a, b emitted) int {
+		return cmp.Or(a.ts.Compare(b.ts), cmp.Compare(a.id, b.id))
+	})
+	ret := make([]engine.Record, len(out))
+	for i, o := range out {
+		ret[i] = o.rec
+	}
+	return ret
+}
+
+// result makes the aggregated values of the bucket
+func (rf *resampleFlow) result(ser *resampleSeries, b *resampleBucket) {
+	b.result = make([]*engine.Value, len(ser.names))
+	for i := range ser.names {
+		switch rf.aggregate {
+		case "first":
+			b.result[i] = b.first[i]
+		case "last":
+			b.result[i] = b.last[i]
+		case "count":
+			b.result[i] = engine.NewValue(int64(b.count))
+		case "sum":
+			if b.nums[i] > 0 {
+				b.result[i] = engine.NewValue(b.sum[i])
+			}
+		case "avg":
+			if b.nums[i] > 0 {
+				b.result[i] = engine.NewValue(b.sum[i] / float64(b.nums[i]))
+			}
+		case "min":
+			if b.nums[i] > 0 {
+				b.result[i] = engine.NewValue(b.min[i])
+			}
+		case "max":
+			if b.nums[i] > 0 {
+				b.result[i] = engine.NewValue(b.max[i])
+			}
+		}
+		if b.result[i] == nil {
+			b.result[i] = engine.NewNullValue(engine.FLOAT)
+		}
+	}
+}
+
+// filled returns the values of the gap at ts between ser.last and next
+func (rf *resampleFlow) filled(ser *resampleSeries, ts time.Time, next *resampleBucket) []*engine.Value {
+	prev := ser.last
+	ret := make([]*engine.Value, len(ser.names))
+	for i := range ser.names {
+		switch rf.fill {
+		case "previous":
+			ret[i] = prev.result[i]
+		case "value":
+			ret[i] = rf.fillValue
+		case "linear":
+			pv, ok1 := prev.result[i].Float64()
+			nv, ok2 := next.result[i].Float64()
+			if ok1 && ok2 && prev.result[i].IsNotNull() && next.result[i].IsNotNull() {
+				ratio := float64(ts.Sub(prev.ts)) / float64(next.ts.Sub(prev.ts))
+				ret[i] = engine.NewValue(pv + (nv-pv)*ratio)
+			} else {
+				ret[i] = engine.NewNullValue(engine.FLOAT)
+			}
+		default:
+			ret[i] = engine.NewNullValue(prev.result[i].Type())
+		}
+	}
+	return ret
+}
+
+func (rf *resampleFlow) record(ser *resampleSeries, ts time.Time, tags engine.Tags, values []*engine.Value) engine.Record {
+	fields := make([]*engine.Field, 0, len(ser.keyFields)+len(values)+1)
+	if rf.timeField != "" {
+		fields = append(fields, engine.NewField(rf.timeField, ts))
+	}
+	fields = append(fields, ser.keyFields...)
+	for i, name := range ser.names {
+		fields = append(fields, engine.NewFieldWithValue(name, values[i]))
+	}
+	ret := engine.NewRecord(fields...)
+	ret.Tags().Merge(tags)
+	ret.Tags().Set(engine.TAG_TIMESTAMP, engine.NewValue(ts))
+	return ret
+}
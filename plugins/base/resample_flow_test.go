@@ -0,0 +1,207 @@
+package base_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleResampleFlow() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"100,a,0",
+			"105,a,2",
+			"100,b,10",
+			"120,b,30",
+			"130,a,4",
+		]
+		format = "csv"
+		fields = ["time", "name", "value"]
+		types = ["time", "string", "float"]
+	[[flows.resample]]
+		step = "10s"
+		keys = ["name"]
+		time_field = "time"
+		aggregate = "avg"
+		fill = "linear"
+	[[outlets.file]]
+		path = "-"
+		format = "csv"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// 100,a,1
+	// 100,b,10
+	// 110,b,20
+	// 120,b,30
+	// 110,a,2
+	// 120,a,3
+	// 130,a,4
+}
+
+func ExampleResampleFlow_previous() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"100,1",
+			"101,2",
+			"102,3",
+			"130,4",
+			"131,5",
+		]
+		format = "csv"
+		fields = ["time", "value"]
+		types = ["time", "int"]
+	[[flows.resample]]
+		step = "10s"
+		time_field = "time"
+		aggregate = "max"
+		fill = "previous"
+	[[outlets.file]]
+		path = "-"
+		format = "csv"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// 100,3
+	// 110,3
+	// 120,3
+	// 130,5
+}
+
+func ExampleResampleFlow_order() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"100,a,0",
+			"100,b,1",
+			"110,b,2",
+			"120,b,3",
+			"130,a,4",
+			"140,b,5",
+		]
+		format = "csv"
+		fields = ["time", "name", "value"]
+		types = ["time", "string", "float"]
+	[[flows.resample]]
+		step = "10s"
+		keys = ["name"]
+		time_field = "time"
+		fill = "previous"
+	[[outlets.file]]
+		path = "-"
+		format = "csv"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// 100,a,0
+	// 100,b,1
+	// 110,a,0
+	// 110,b,2
+	// 120,a,0
+	// 120,b,3
+	// 130,a,4
+	// 130,b,3
+	// 140,b,5
+}
+
+func ExampleResampleFlow_maxKeys() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"100,a,1",
+			"100,b,2",
+			"105,a,3",
+		]
+		format = "csv"
+		fields = ["time", "name", "value"]
+		types = ["time", "string", "float"]
+	[[flows.resample]]
+		step = "10s"
+		lateness = "1m"
+		keys = ["name"]
+		time_field = "time"
+		max_keys = 1
+	[[outlets.file]]
+		path = "-"
+		format = "csv"
+	`
+	// the evicted series emits its open bucket,
+	// and it starts again as a new series
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// 100,a,1
+	// 100,b,2
+	// 100,a,3
+}
+
+func TestResampleFlowIdle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.csv")
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+	dsl := fmt.Sprintf(`
+	[[inlets.tail]]
+		paths = [%q]
+		poll_interval = "50ms"
+		format = "csv"
+		fields = ["time", "name", "value"]
+		types = ["time", "string", "float"]
+	[[flows.resample]]
+		step = "10s"
+		lateness = "1h"
+		keys = ["name"]
+		time_field = "time"
+		idle_timeout = "200ms"
+	[[outlets.file]]
+		format = "csv"
+	`, path)
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+	defer pipeline.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	// the watermark does not pass the buckets within the lateness,
+	// those are closed after the idle_timeout
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("101,b,2\n100,a,1\n105,a,3\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	expect := "100,b,2\n100,a,2\n"
+	for i := 0; i < 100 && out.String() != expect; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Equal(t, expect, out.String())
+}
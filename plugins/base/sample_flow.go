@@ -0,0 +1,85 @@
+package base
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+type sampleFlow struct {
+	ctx      *engine.Context
+	keys     []string
+	random   bool
+	ratio    float64
+	nth      uint64
+	counters *keyLRU[uint64]
+	dropped  uint64
+}
+
+var _ = engine.Flow((*sampleFlow)(nil))
+var _ = engine.DroppingFlow((*sampleFlow)(nil))
+
+func SampleFlow(ctx *engine.Context) engine.Flow {
+	return &sampleFlow{ctx: ctx}
+}
+
+func (sf *sampleFlow) Open() error {
+	conf := sf.ctx.Config()
+	sf.keys = conf.GetStringSlice("keys", nil)
+	switch method := conf.GetString("method", "nth"); method {
+	case "random":
+		sf.random = true
+		sf.ratio = conf.GetFloat("ratio", 0.1)
+		if sf.ratio < 0 || sf.ratio > 1 {
+			return fmt.Errorf("sample: ratio should be in range of 0.0 ~ 1.0")
+		}
+	case "nth":
+		sf.random = false
+		n := conf.GetInt("n", 10)
+		if n < 1 {
+			return fmt.Errorf("sample: n should be greater than 0")
+		}
+		sf.nth = uint64(n)
+	default:
+		return fmt.Errorf("sample: unknown method %q", method)
+	}
+	sf.counters = newKeyLRU[uint64](conf.GetInt("max_keys", 10000))
+	return nil
+}
+
+func (sf *sampleFlow) Close() error     { return nil }
+func (sf *sampleFlow) Parallelism() int { return 1 }
+
+// Dropped returns the number of records dropped
+func (sf *sampleFlow) Dropped() uint64 {
+	return atomic.LoadUint64(&sf.dropped)
+}
+
+func (sf *sampleFlow) Process(recs []engine.Record, nextFunc engine.FlowNextFunc) {
+	ret := make([]engine.Record, 0, len(recs))
+	for _, r := range recs {
+		if sf.random {
+			if rand.Float64() >= sf.ratio {
+				atomic.AddUint64(&sf.dropped, 1)
+				continue
+			}
+			ret = append(ret, r)
+			continue
+		}
+		// if no keys specified, all records share a single counter
+		key := ""
+		if len(sf.keys) > 0 {
+			key = recordKey(r, sf.keys)
+		}
+		count, _ := sf.counters.Get(key)
+		sf.counters.Put(key, count+1)
+		if count%sf.nth != 0 {
+			atomic.AddUint64(&sf.dropped, 1)
+			continue
+		}
+		ret = append(ret, r)
+	}
+	nextFunc(ret, nil)
+}
@@ -0,0 +1,42 @@
+package base_test
+
+import (
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+)
+
+func ExampleSampleFlow() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"a,1",
+			"a,2",
+			"b,3",
+			"a,4",
+			"b,5",
+			"a,6",
+			"b,7",
+		]
+		format = "csv"
+		fields = ["name", "value"]
+	[[flows.sample]]
+		method = "nth"
+		n = 2
+		keys = ["name"]
+	[[outlets.file]]
+		path = "-"
+		format = "csv"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// a,1
+	// b,3
+	// a,4
+	// b,7
+}
@@ -0,0 +1,398 @@
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "tail",
+		Factory: TailInlet,
+	})
+}
+
+func TailInlet(ctx *engine.Context) engine.Inlet {
+	return &tailInlet{
+		ctx:     ctx,
+		files:   map[string]*tailFile{},
+		closeCh: make(chan struct{}),
+	}
+}
+
+type tailInlet struct {
+	ctx          *engine.Context
+	conf         engine.Config
+	paths        []string
+	fromStart    bool
+	stateFile    string
+	pathTag      string
+	pollInterval time.Duration
+	maxLineSize  int
+
+	mutex     sync.Mutex
+	files     map[string]*tailFile // by the key of the file
+	scanned   bool
+	closeCh   chan struct{}
+	closeOnce sync.Once
+
+	// checkpoints those are acknowledged by the outlets
+	stateMutex sync.Mutex
+	states     map[string]tailState
+	hasState   bool
+	stateErr   bool
+}
+
+// tailFile is a file being followed
+type tailFile struct {
+	key    string
+	path   string
+	file   *os.File
+	offset int64
+}
+
+// tailState is the checkpoint of a file saved in the state file
+// by the key of the file, device and inode, so that the file is
+// followed by the checkpoint even if it is renamed by the rotation.
+type tailState struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+}
+
+var _ = engine.Inlet((*tailInlet)(nil))
+
+func (ti *tailInlet) Open() error {
+	conf := ti.ctx.Config()
+	ti.paths = conf.GetStringSlice("paths", nil)
+	ti.stateFile = conf.GetString("state_file", "")
+	ti.pathTag = conf.GetString("path_tag", "path")
+	ti.pollInterval = conf.GetDuration("poll_interval", time.Second)
+	ti.maxLineSize = conf.GetInt("max_line_size", 1024*1024)
+	// tailing files can not be decompressed
+	ti.conf = maps.Clone(conf).Unset("compress")
+
+	if len(ti.paths) == 0 {
+		return fmt.Errorf("inlet.tail paths are required")
+	}
+	for _, p := range ti.paths {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("inlet.tail invalid path %q, %w", p, err)
+		}
+	}
+	switch from := conf.GetString("from", "end"); from {
+	case "beginning":
+		ti.fromStart = true
+	case "end":
+		ti.fromStart = false
+	default:
+		return fmt.Errorf("inlet.tail unknown from %q", from)
+	}
+	if ti.pollInterval <= 0 {
+		return fmt.Errorf("inlet.tail poll_interval should be greater than 0")
+	}
+	if _, err := engine.NewReader(bytes.NewReader(nil), ti.conf); err != nil {
+		return fmt.Errorf("inlet.tail %w", err)
+	}
+	ti.ctx.LogDebug("inlet.tail", "paths", ti.paths, "state_file", ti.stateFile)
+	return nil
+}
+
+func (ti *tailInlet) Close() error {
+	ti.closeOnce.Do(func() {
+		close(ti.closeCh)
+		ti.mutex.Lock()
+		defer ti.mutex.Unlock()
+		for _, tf := range ti.files {
+			tf.file.Close()
+		}
+		ti.files = map[string]*tailFile{}
+	})
+	return nil
+}
+
+func (ti *tailInlet) Process(next engine.InletNextFunc) {
+	ticker := time.NewTicker(ti.pollInterval)
+	defer ticker.Stop()
+	for {
+		ti.poll(next)
+		select {
+		case <-ti.closeCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll scans the paths for new, rotated and truncated files,
+// and sends the records of the lines appended since the last poll.
+// The files are identified by the device and inode, a file renamed by the rotation
+// is followed from where it was read even if it is matched by the paths again.
+func (ti *tailInlet) poll(next engine.InletNextFunc) {
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+	select {
+	case <-ti.closeCh:
+		return
+	default:
+	}
+
+	var states map[string]tailState
+	if !ti.scanned {
+		states = ti.loadState()
+	}
+	matches := map[string]string{}
+	keys := []string{}
+	for _, p := range ti.paths {
+		m, _ := filepath.Glob(p)
+		slices.Sort(m)
+		for _, path := range m {
+			fi, err := os.Stat(path)
+			if err != nil || fi.IsDir() {
+				continue
+			}
+			key := fileKey(path, fi)
+			if _, ok := matches[key]; !ok {
+				matches[key] = path
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	// files those are gone or rotated out of the paths
+	for key, tf := range ti.files {
+		if _, ok := matches[key]; ok {
+			continue
+		}
+		// read the rest of the rotated file
+		ti.read(tf, next)
+		tf.file.Close()
+		delete(ti.files, key)
+		ti.forget(key, next)
+		ti.ctx.LogDebug("inlet.tail", "rotated", tf.path)
+	}
+
+	// the files being followed are read before the new files,
+	// so that the rest of a rotated file comes before the new file
+	for _, key := range keys {
+		tf, ok := ti.files[key]
+		if !ok {
+			continue
+		}
+		tf.path = matches[key]
+		if fi, err := tf.file.Stat(); err == nil && fi.Size() < tf.offset {
+			// truncated
+			ti.ctx.LogDebug("inlet.tail", "truncated", tf.path)
+			tf.offset = 0
+		}
+		ti.read(tf, next)
+	}
+
+	initial := map[string]tailState{}
+	for _, key := range keys {
+		if _, ok := ti.files[key]; ok {
+			continue
+		}
+		path := matches[key]
+		f, err := os.Open(path)
+		if err != nil {
+			ti.ctx.LogWarn("inlet.tail", "path", path, "error", err.Error())
+			continue
+		}
+		fi, err := f.Stat()
+		if err != nil || fileKey(path, fi) != key {
+			// replaced after it is matched, it is opened by the next poll
+			f.Close()
+			continue
+		}
+		tf := &tailFile{key: key, path: path, file: f}
+		if st, ok := states[key]; ok && st.Offset <= fi.Size() {
+			// resume from the checkpoint
+			tf.offset = st.Offset
+		} else if !ti.scanned && !ti.fromStart && !ti.hasState {
+			tf.offset = fi.Size()
+		}
+		// files those appear after the start or after the checkpoint are read from the beginning
+		ti.files[key] = tf
+		if !ti.scanned {
+			initial[key] = tailState{Path: path, Offset: tf.offset}
+		}
+		ti.read(tf, next)
+	}
+
+	if !ti.scanned && ti.stateFile != "" {
+		ti.stateMutex.Lock()
+		// the checkpoints of the files those are gone while stopped
+		for key := range ti.states {
+			if _, ok := ti.files[key]; !ok {
+				delete(ti.states, key)
+			}
+		}
+		// the files start from where they are found, unless the records are handled already
+		for key, st := range initial {
+			if _, ok := ti.states[key]; !ok {
+				ti.states[key] = st
+			}
+		}
+		if !ti.stateErr {
+			if err := ti.saveState(); err != nil {
+				ti.ctx.LogError("inlet.tail", "state_file", ti.stateFile, "error", err.Error())
+			}
+		}
+		ti.stateMutex.Unlock()
+	}
+	ti.scanned = true
+}
+
+// read sends the records of the complete lines from the offset,
+// an incomplete last line is left for the next poll.
+func (ti *tailInlet) read(tf *tailFile, next engine.InletNextFunc) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := tf.file.ReadAt(buf, tf.offset)
+		if err != nil && err != io.EOF {
+			ti.ctx.LogWarn("inlet.tail", "path", tf.path, "error", err.Error())
+			return
+		}
+		data := buf[:n]
+		idx := bytes.LastIndexByte(data, '\n')
+		if idx < 0 {
+			if n == len(buf) && len(buf) < ti.maxLineSize {
+				// a line longer than the buffer
+				buf = make([]byte, min(len(buf)*2, ti.maxLineSize))
+				continue
+			} else if n < len(buf) {
+				return
+			}
+			// the line is too long, take it as it is
+			idx = n - 1
+		}
+		chunk := data[:idx+1]
+		recs := ti.decode(tf.path, chunk)
+		tf.offset += int64(len(chunk))
+		if ti.stateFile != "" {
+			next(engine.WithAck(recs, ti.checkpoint(tf.key, tf.path, tf.offset)), nil)
+		} else if len(recs) > 0 {
+			next(recs, nil)
+		}
+		if n < len(buf) {
+			return
+		}
+	}
+}
+
+func (ti *tailInlet) decode(path string, chunk []byte) []engine.Record {
+	reader, err := engine.NewReader(bytes.NewReader(chunk), ti.conf)
+	if err != nil {
+		ti.ctx.LogError("inlet.tail", "path", path, "error", err.Error())
+		return nil
+	}
+	defer reader.Close()
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			ti.ctx.LogWarn("inlet.tail", "path", path, "decode error", err.Error())
+			break
+		}
+	}
+	if ti.pathTag != "" {
+		for _, r := range ret {
+			r.Tags().Set(ti.pathTag, engine.NewValue(path))
+		}
+	}
+	return ret
+}
+
+// checkpoint returns the callback that saves the offset of the file
+// after the records before the offset are handled by the outlets.
+func (ti *tailInlet) checkpoint(key string, path string, offset int64) func(error) {
+	return func(err error) {
+		ti.stateMutex.Lock()
+		defer ti.stateMutex.Unlock()
+		if err != nil {
+			// the records are read again from the last checkpoint when it restarts
+			ti.stateErr = true
+			ti.ctx.LogWarn("inlet.tail", "path", path, "offset", offset, "error", err.Error())
+			return
+		}
+		if ti.stateErr {
+			return
+		}
+		ti.states[key] = tailState{Path: path, Offset: offset}
+		if err := ti.saveState(); err != nil {
+			ti.ctx.LogError("inlet.tail", "state_file", ti.stateFile, "error", err.Error())
+		}
+	}
+}
+
+// forget removes the checkpoint of the file that is not followed anymore,
+// after the records of the file are handled.
+func (ti *tailInlet) forget(key string, next engine.InletNextFunc) {
+	if ti.stateFile == "" {
+		return
+	}
+	next(engine.WithAck(nil, func(err error) {
+		ti.stateMutex.Lock()
+		defer ti.stateMutex.Unlock()
+		if err != nil || ti.stateErr {
+			return
+		}
+		delete(ti.states, key)
+		if err := ti.saveState(); err != nil {
+			ti.ctx.LogError("inlet.tail", "state_file", ti.stateFile, "error", err.Error())
+		}
+	}), nil)
+}
+
+// loadState reads the checkpoints from the state file
+func (ti *tailInlet) loadState() map[string]tailState {
+	ret := map[string]tailState{}
+	defer func() {
+		ti.stateMutex.Lock()
+		ti.states = maps.Clone(ret)
+		ti.stateMutex.Unlock()
+	}()
+	if ti.stateFile == "" {
+		return ret
+	}
+	b, err := os.ReadFile(ti.stateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			ti.ctx.LogWarn("inlet.tail", "state_file", ti.stateFile, "error", err.Error())
+		}
+		return ret
+	}
+	if err := json.Unmarshal(b, &ret); err != nil {
+		ti.ctx.LogWarn("inlet.tail", "state_file", ti.stateFile, "error", err.Error())
+		return ret
+	}
+	ti.hasState = true
+	return ret
+}
+
+// saveState writes the checkpoints into the state file, the caller should hold stateMutex.
+// it writes a temporary file and renames it, so that the state file is never broken.
+func (ti *tailInlet) saveState() error {
+	b, err := json.Marshal(ti.states)
+	if err != nil {
+		return err
+	}
+	tmp := ti.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ti.stateFile)
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package base
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileKey returns the device and inode of the file,
+// which are kept by the file when it is renamed by the log rotation.
+func fileKey(path string, fi os.FileInfo) string {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", uint64(st.Dev), uint64(st.Ino))
+	}
+	return path
+}
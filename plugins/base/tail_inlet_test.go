@@ -0,0 +1,212 @@
+package base_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+func appendFile(t *testing.T, name string, data string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func waitOutput(t *testing.T, out *syncBuffer, expect string) {
+	t.Helper()
+	for i := 0; i < 100 && out.String() != expect; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Equal(t, expect, out.String())
+}
+
+// waitSaved waits until the state file has the offset of the path
+func waitSaved(t *testing.T, stateFile string, path string, offset int64) {
+	t.Helper()
+	found := false
+	for i := 0; i < 100 && !found; i++ {
+		states := map[string]struct {
+			Path   string `json:"path"`
+			Offset int64  `json:"offset"`
+		}{}
+		if b, err := os.ReadFile(stateFile); err == nil && json.Unmarshal(b, &states) == nil {
+			for _, st := range states {
+				if st.Path == path && st.Offset == offset {
+					found = true
+				}
+			}
+		}
+		if !found {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	require.True(t, found, "offset %d of %s is not saved", offset, path)
+}
+
+func TestTailInlet(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	stateFile := filepath.Join(dir, "tail.state")
+	dsl := fmt.Sprintf(`
+	[[inlets.tail]]
+		paths = [%q]
+		state_file = %q
+		poll_interval = "50ms"
+		format = "csv"
+	[[outlets.file]]
+		format = "csv"
+	`, path, stateFile)
+
+	appendFile := func(name string, data string) { appendFile(t, name, data) }
+	waitFor := func(out *syncBuffer, expect string) { waitOutput(t, out, expect) }
+
+	// the existing lines are skipped, it starts from the end
+	appendFile(path, "a,1\n")
+
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+
+	// wait until the first poll saves the state
+	waitSaved(t, stateFile, path, 4)
+
+	// an incomplete line waits for the newline
+	appendFile(path, "b,2\nc,")
+	waitFor(out, "b,2\n")
+	appendFile(path, "3\n")
+	waitFor(out, "b,2\nc,3\n")
+
+	// rotation, the rest of the rotated file is read before the new file
+	appendFile(path, "d,4\n")
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(path, "e,5\n")
+	waitFor(out, "b,2\nc,3\nd,4\ne,5\n")
+
+	// truncation
+	require.NoError(t, os.Truncate(path, 0))
+	time.Sleep(200 * time.Millisecond)
+	appendFile(path, "f,6\n")
+	waitFor(out, "b,2\nc,3\nd,4\ne,5\nf,6\n")
+	// the offset is saved after the outlet handles the records
+	waitSaved(t, stateFile, path, 4)
+	pipeline.Stop()
+
+	// restart from the checkpoint, lines written while stopped are not lost
+	appendFile(path, "g,7\n")
+	out = &syncBuffer{}
+	pipeline, err = engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+	waitFor(out, "g,7\n")
+	appendFile(path, "h,8\n")
+	waitFor(out, "g,7\nh,8\n")
+	pipeline.Stop()
+}
+
+func TestTailInletRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	stateFile := filepath.Join(dir, "tail.state")
+	dsl := fmt.Sprintf(`
+	[[inlets.tail]]
+		paths = [%q]
+		state_file = %q
+		poll_interval = "50ms"
+		format = "csv"
+	[[outlets.file]]
+		format = "csv"
+	`, path+"*", stateFile)
+
+	appendFile(t, path, "a,1\n")
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+	waitSaved(t, stateFile, path, 4)
+
+	appendFile(t, path, "b,2\n")
+	waitOutput(t, out, "b,2\n")
+
+	// the rotated file that is matched by the paths is followed, not read again
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path+".1", "c,3\n")
+	appendFile(t, path, "d,4\n")
+	waitOutput(t, out, "b,2\nc,3\nd,4\n")
+	waitSaved(t, stateFile, path+".1", 12)
+	waitSaved(t, stateFile, path, 4)
+	pipeline.Stop()
+
+	// while stopped, the rotated file is appended and a new file is created at the same path
+	appendFile(t, path+".1", "e,5\n")
+	require.NoError(t, os.Rename(path, path+".2"))
+	appendFile(t, path, "f,6\n")
+
+	// the rotated files resume from the checkpoints, the new file is read from the beginning
+	out = &syncBuffer{}
+	pipeline, err = engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+	waitOutput(t, out, "f,6\ne,5\n")
+	pipeline.Stop()
+}
+
+func TestTailInletFromBeginning(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), []byte(`{"n":1}`+"\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.log"), []byte(`{"n":2}`+"\n"), 0644))
+	dsl := fmt.Sprintf(`
+	[[inlets.tail]]
+		paths = [%q]
+		from = "beginning"
+		path_tag = "file"
+		poll_interval = "50ms"
+		format = "json"
+	[[flows.select]]
+		includes = ["#file", "*"]
+	[[outlets.file]]
+		format = "json"
+	`, filepath.Join(dir, "*.log"))
+
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+
+	expect := fmt.Sprintf(`{"file":%q,"n":1}`+"\n"+`{"file":%q,"n":2}`+"\n",
+		filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log"))
+	for i := 0; i < 100 && out.String() != expect; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	pipeline.Stop()
+	require.Equal(t, expect, out.String())
+}
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package base
+
+import "os"
+
+// fileKey returns the path of the file, the inode is not available on windows,
+// rotated files are detected only by truncation.
+func fileKey(path string, fi os.FileInfo) string {
+	return path
+}
@@ -0,0 +1,84 @@
+package base
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"golang.org/x/time/rate"
+)
+
+type throttleFlow struct {
+	ctx      *engine.Context
+	keys     []string
+	limit    rate.Limit
+	burst    int
+	delay    bool
+	limiters *keyLRU[*rate.Limiter]
+	dropped  uint64
+}
+
+var _ = engine.Flow((*throttleFlow)(nil))
+var _ = engine.DroppingFlow((*throttleFlow)(nil))
+
+func ThrottleFlow(ctx *engine.Context) engine.Flow {
+	return &throttleFlow{ctx: ctx}
+}
+
+func (tf *throttleFlow) Open() error {
+	conf := tf.ctx.Config()
+	tf.keys = conf.GetStringSlice("keys", nil)
+	tf.limit = rate.Limit(conf.GetFloat("rate", 10))
+	tf.burst = conf.GetInt("burst", 1)
+	if tf.limit <= 0 {
+		return fmt.Errorf("throttle: rate should be greater than 0")
+	}
+	if tf.burst < 1 {
+		return fmt.Errorf("throttle: burst should be greater than 0")
+	}
+	switch mode := conf.GetString("mode", "drop"); mode {
+	case "drop":
+		tf.delay = false
+	case "delay":
+		tf.delay = true
+	default:
+		return fmt.Errorf("throttle: unknown mode %q", mode)
+	}
+	tf.limiters = newKeyLRU[*rate.Limiter](conf.GetInt("max_keys", 10000))
+	return nil
+}
+
+func (tf *throttleFlow) Close() error     { return nil }
+func (tf *throttleFlow) Parallelism() int { return 1 }
+
+// Dropped returns the number of records dropped
+func (tf *throttleFlow) Dropped() uint64 {
+	return atomic.LoadUint64(&tf.dropped)
+}
+
+func (tf *throttleFlow) Process(recs []engine.Record, nextFunc engine.FlowNextFunc) {
+	ret := make([]engine.Record, 0, len(recs))
+	for _, r := range recs {
+		// if no keys specified, all records share a single bucket
+		key := ""
+		if len(tf.keys) > 0 {
+			key = recordKey(r, tf.keys)
+		}
+		limiter, ok := tf.limiters.Get(key)
+		if !ok {
+			limiter = rate.NewLimiter(tf.limit, tf.burst)
+			tf.limiters.Put(key, limiter)
+		}
+		if tf.delay {
+			if err := limiter.Wait(tf.ctx); err != nil {
+				atomic.AddUint64(&tf.dropped, 1)
+				continue
+			}
+		} else if !limiter.Allow() {
+			atomic.AddUint64(&tf.dropped, 1)
+			continue
+		}
+		ret = append(ret, r)
+	}
+	nextFunc(ret, nil)
+}
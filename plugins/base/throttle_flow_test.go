@@ -0,0 +1,129 @@
+package base_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	"github.com/stretchr/testify/require"
+)
+
+// flowStats returns the stats of the flow in the pipeline
+func flowStats(p *engine.Pipeline, name string) engine.FlowStats {
+	var ret engine.FlowStats
+	p.Walk(func(_ string, kind string, step string, handler any) {
+		if fh, ok := handler.(*engine.FlowHandler); ok && kind == "flows" && step == name {
+			ret = fh.Stats()
+		}
+	})
+	return ret
+}
+
+func ExampleThrottleFlow() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"a,1",
+			"a,2",
+			"b,3",
+			"a,4",
+			"b,5",
+			"b,6",
+		]
+		format = "csv"
+		fields = ["name", "value"]
+	[[flows.throttle]]
+		keys = ["name"]
+		rate = 0.001
+		burst = 2
+	[[outlets.file]]
+		path = "-"
+		format = "csv"
+	`
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// a,1
+	// a,2
+	// b,3
+	// b,5
+}
+
+func TestThrottleFlowDelay(t *testing.T) {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"a,1",
+			"a,2",
+			"a,3",
+			"a,4",
+			"a,5",
+		]
+		format = "csv"
+		fields = ["name", "value"]
+	[[flows.throttle]]
+		keys = ["name"]
+		rate = 20
+		burst = 1
+		mode = "delay"
+	[[outlets.file]]
+		format = "csv"
+	`
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	start := time.Now()
+	require.NoError(t, pipeline.Run())
+	// the records are held until the bucket allows them, none is dropped
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	require.Equal(t, "a,1\na,2\na,3\na,4\na,5\n", out.String())
+	require.Equal(t, engine.FlowStats{Recv: 5, Sent: 5, Dropped: 0}, flowStats(pipeline, "throttle"))
+}
+
+func TestFlowDropped(t *testing.T) {
+	tests := []struct {
+		name   string
+		flow   string
+		expect engine.FlowStats
+	}{
+		{
+			name:   "throttle",
+			flow:   "keys = [\"name\"]\nrate = 0.001\nburst = 2",
+			expect: engine.FlowStats{Recv: 6, Sent: 4, Dropped: 2},
+		},
+		{
+			name:   "sample",
+			flow:   "method = \"nth\"\nn = 2\nkeys = [\"name\"]",
+			expect: engine.FlowStats{Recv: 6, Sent: 4, Dropped: 2},
+		},
+		{
+			name:   "dedup",
+			flow:   "keys = [\"name\"]\nttl = \"1m\"",
+			expect: engine.FlowStats{Recv: 6, Sent: 2, Dropped: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsl := `
+			[[inlets.file]]
+				data = ["a,1", "a,2", "b,3", "a,4", "b,5", "b,6"]
+				format = "csv"
+				fields = ["name", "value"]
+			[[flows.` + tt.name + `]]
+			` + tt.flow + `
+			[[outlets.file]]
+				format = "csv"
+			`
+			pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(&syncBuffer{}))
+			require.NoError(t, err)
+			require.NoError(t, pipeline.Run())
+			require.Equal(t, tt.expect, flowStats(pipeline, tt.name))
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package dirwatch
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/fsnotify/fsnotify"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "dir_watch",
+		Factory: DirWatchInlet,
+	})
+}
+
+func DirWatchInlet(ctx *engine.Context) engine.Inlet {
+	return &dirWatchInlet{
+		ctx:     ctx,
+		failed:  map[string]time.Time{},
+		sent:    map[string]struct{}{},
+		closeCh: make(chan struct{}),
+	}
+}
+
+type dirWatchInlet struct {
+	ctx          *engine.Context
+	conf         engine.Config
+	dir          string
+	pattern      string
+	pollInterval time.Duration
+	settle       time.Duration
+	after        string
+	moveTo       string
+	suffix       string
+	filenameTag  string
+
+	watcher *fsnotify.Watcher
+	// failed and sent are updated by the acknowledgements of the pipeline
+	mutex     sync.Mutex
+	failed    map[string]time.Time
+	sent      map[string]struct{}
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+var _ = engine.Inlet((*dirWatchInlet)(nil))
+
+// compressExts is the decompressor of the file extension
+var compressExts = map[string]string{
+	".gz":      "gzip",
+	".gzip":    "gzip",
+	".zlib":    "zlib",
+	".zz":      "zlib",
+	".deflate": "inflate",
+	".lzw":     "lzw",
+}
+
+// formatExts is the decoder of the file extension
+var formatExts = map[string]string{
+	".csv":    "csv",
+	".json":   "json",
+	".ndjson": "json",
+	".jsonl":  "json",
+}
+
+func (dw *dirWatchInlet) Open() error {
+	conf := dw.ctx.Config()
+	dw.dir = conf.GetString("path", "")
+	dw.pattern = conf.GetString("pattern", "*")
+	dw.pollInterval = conf.GetDuration("poll_interval", 10*time.Second)
+	dw.settle = conf.GetDuration("settle", time.Second)
+	dw.after = conf.GetString("after", "rename")
+	dw.moveTo = conf.GetString("move_to", "")
+	dw.suffix = conf.GetString("suffix", ".done")
+	dw.filenameTag = conf.GetString("filename_tag", "filename")
+	dw.conf = maps.Clone(conf)
+
+	if dw.dir == "" {
+		return fmt.Errorf("inlet.dir_watch path is required")
+	}
+	if fi, err := os.Stat(dw.dir); err != nil {
+		return fmt.Errorf("inlet.dir_watch %w", err)
+	} else if !fi.IsDir() {
+		return fmt.Errorf("inlet.dir_watch %q is not a directory", dw.dir)
+	}
+	if _, err := filepath.Match(dw.pattern, ""); err != nil {
+		return fmt.Errorf("inlet.dir_watch invalid pattern %q, %w", dw.pattern, err)
+	}
+	if format := conf.GetString("format", ""); format != "" && engine.GetDecoder(format) == nil {
+		return fmt.Errorf("inlet.dir_watch format %q not found", format)
+	}
+	if dw.pollInterval <= 0 {
+		return fmt.Errorf("inlet.dir_watch poll_interval should be greater than 0")
+	}
+	switch dw.after {
+	case "delete":
+	case "move":
+		if dw.moveTo == "" {
+			return fmt.Errorf("inlet.dir_watch move_to is required for after=\"move\"")
+		}
+		if err := os.MkdirAll(dw.moveTo, 0755); err != nil {
+			return fmt.Errorf("inlet.dir_watch %w", err)
+		}
+	case "rename":
+		if dw.suffix == "" {
+			return fmt.Errorf("inlet.dir_watch suffix is required for after=\"rename\"")
+		}
+	default:
+		return fmt.Errorf("inlet.dir_watch unknown after %q", dw.after)
+	}
+
+	switch watch := conf.GetString("watch", "auto"); watch {
+	case "auto":
+		if w, err := fsnotify.NewWatcher(); err != nil {
+			dw.ctx.LogWarn("inlet.dir_watch", "fsnotify", err.Error(), "fallback", "poll")
+		} else if err := w.Add(dw.dir); err != nil {
+			w.Close()
+			dw.ctx.LogWarn("inlet.dir_watch", "fsnotify", err.Error(), "fallback", "poll")
+		} else {
+			dw.watcher = w
+		}
+	case "poll":
+	default:
+		return fmt.Errorf("inlet.dir_watch unknown watch %q", watch)
+	}
+	dw.ctx.LogDebug("inlet.dir_watch", "path", dw.dir, "pattern", dw.pattern, "fsnotify", dw.watcher != nil)
+	return nil
+}
+
+func (dw *dirWatchInlet) Close() error {
+	dw.closeOnce.Do(func() {
+		close(dw.closeCh)
+		if dw.watcher != nil {
+			dw.watcher.Close()
+		}
+	})
+	return nil
+}
+
+func (dw *dirWatchInlet) Process(next engine.InletNextFunc) {
+	var events chan fsnotify.Event
+	var errors chan error
+	if dw.watcher != nil {
+		events, errors = dw.watcher.Events, dw.watcher.Errors
+	}
+	ticker := time.NewTicker(dw.pollInterval)
+	defer ticker.Stop()
+	// rescan after the settle time if there are files still being written
+	var settleC <-chan time.Time
+
+	rescan := true
+	for {
+		if rescan && dw.scan(next) {
+			settleC = time.After(dw.settle)
+		}
+		rescan = true
+		select {
+		case <-dw.closeCh:
+			return
+		case <-ticker.C:
+		case <-settleC:
+			settleC = nil
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+			}
+			rescan = ok && (ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) || ev.Has(fsnotify.Rename))
+		case err, ok := <-errors:
+			if !ok {
+				errors = nil
+			} else {
+				dw.ctx.LogWarn("inlet.dir_watch", "fsnotify", err.Error())
+			}
+			rescan = false
+		}
+	}
+}
+
+// scan processes the files those match the pattern,
+// it returns true if there are files those are not settled yet.
+func (dw *dirWatchInlet) scan(next engine.InletNextFunc) bool {
+	entries, err := os.ReadDir(dw.dir)
+	if err != nil {
+		dw.ctx.LogWarn("inlet.dir_watch", "path", dw.dir, "error", err.Error())
+		return false
+	}
+	pending := false
+	names := []string{}
+	for _, ent := range entries {
+		name := ent.Name()
+		if ent.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if dw.after == "rename" && strings.HasSuffix(name, dw.suffix) {
+			continue
+		}
+		if ok, _ := filepath.Match(dw.pattern, name); ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		select {
+		case <-dw.closeCh:
+			return false
+		default:
+		}
+		path := filepath.Join(dw.dir, name)
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		dw.mutex.Lock()
+		_, sent := dw.sent[path]
+		t, failed := dw.failed[path]
+		if failed && !t.Equal(fi.ModTime()) {
+			delete(dw.failed, path)
+			failed = false
+		}
+		dw.mutex.Unlock()
+		if sent || failed {
+			// waiting for the pipeline, or failed before and not changed since then
+			continue
+		}
+		if time.Since(fi.ModTime()) < dw.settle {
+			pending = true
+			continue
+		}
+		recs, err := dw.process(path)
+		if err != nil {
+			dw.ctx.LogError("inlet.dir_watch", "path", path, "error", err.Error())
+			dw.mutex.Lock()
+			dw.failed[path] = fi.ModTime()
+			dw.mutex.Unlock()
+			continue
+		}
+		dw.mutex.Lock()
+		dw.sent[path] = struct{}{}
+		dw.mutex.Unlock()
+		next(engine.WithAck(recs, func(err error) {
+			if err == nil {
+				err = dw.done(path)
+				if err != nil {
+					dw.ctx.LogError("inlet.dir_watch", "path", path, "after", dw.after, "error", err.Error())
+				}
+			} else {
+				dw.ctx.LogWarn("inlet.dir_watch", "path", path, "error", err.Error())
+			}
+			dw.mutex.Lock()
+			defer dw.mutex.Unlock()
+			delete(dw.sent, path)
+			if err != nil {
+				dw.failed[path] = fi.ModTime()
+			}
+		}), nil)
+	}
+	return pending
+}
+
+// process decodes the whole file into the records,
+// the format and the compression are detected by the extensions of the file
+// if they are not specified in the config.
+// No records are returned if the file can not be decoded to the end.
+func (dw *dirWatchInlet) process(path string) ([]engine.Record, error) {
+	name := filepath.Base(path)
+	conf := maps.Clone(dw.conf)
+	ext := strings.ToLower(filepath.Ext(name))
+	if compress, ok := compressExts[ext]; ok {
+		if conf.GetString("compress", "") == "" {
+			conf.Set("compress", compress)
+		}
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(name, filepath.Ext(name))))
+	}
+	if conf.GetString("format", "") == "" {
+		if format, ok := formatExts[ext]; ok {
+			conf.Set("format", format)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader, err := engine.NewReader(f, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if dw.filenameTag != "" {
+		for _, r := range ret {
+			r.Tags().Set(dw.filenameTag, engine.NewValue(name))
+		}
+	}
+	return ret, nil
+}
+
+// done deletes, moves or renames the processed file,
+// it is called after the records of the file are handled by the outlets.
+func (dw *dirWatchInlet) done(path string) error {
+	switch dw.after {
+	case "delete":
+		return os.Remove(path)
+	case "move":
+		return os.Rename(path, filepath.Join(dw.moveTo, filepath.Base(path)))
+	default:
+		return os.Rename(path, path+dw.suffix)
+	}
+}
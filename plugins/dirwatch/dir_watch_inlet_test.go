@@ -0,0 +1,175 @@
+package dirwatch_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/dirwatch"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+func writeGzip(t *testing.T, path string, data string) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	w.Write([]byte(data))
+	require.NoError(t, w.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestDirWatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   string
+		after  func(t *testing.T, dir string)
+		exists []string
+	}{
+		{
+			name:   "rename",
+			conf:   `watch = "auto"`,
+			exists: []string{"a.csv.done", "b.json.gz.done", "c.csv.done", "d.json.gz", "x.txt"},
+		},
+		{
+			name:   "move",
+			conf:   `watch = "poll"` + "\n" + `after = "move"` + "\n" + `move_to = "%s/done"`,
+			exists: []string{"d.json.gz", "done", "done/a.csv", "done/b.json.gz", "done/c.csv", "x.txt"},
+		},
+		{
+			name:   "delete",
+			conf:   `after = "delete"`,
+			exists: []string{"d.json.gz", "x.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "a.csv"), []byte("a,1\n"), 0644))
+			writeGzip(t, filepath.Join(dir, "b.json.gz"), `{"b":2}`+"\n")
+			// the records of a broken file are not sent, and the file is left
+			writeGzip(t, filepath.Join(dir, "d.json.gz"), `{"d":4}`+"\n"+`{"d":`)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "x.txt"), []byte("x,0\n"), 0644))
+			conf := tt.conf
+			if tt.name == "move" {
+				conf = fmt.Sprintf(conf, dir)
+			}
+			dsl := fmt.Sprintf(`
+			[[inlets.dir_watch]]
+				path = %q
+				pattern = "*.*[vz]"
+				settle = "100ms"
+				poll_interval = "100ms"
+				%s
+			[[flows.select]]
+				includes = ["#filename", "*"]
+			[[outlets.file]]
+				format = "json"
+			`, dir, conf)
+
+			out := &syncBuffer{}
+			pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+			require.NoError(t, err)
+			go pipeline.Run()
+
+			expect := `{"0":"a","1":"1","filename":"a.csv"}` + "\n" +
+				`{"b":2,"filename":"b.json.gz"}` + "\n"
+			for i := 0; i < 100 && out.String() != expect; i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+			require.Equal(t, expect, out.String())
+
+			// a file dropped while running
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "c.csv"), []byte("c,3\n"), 0644))
+			expect += `{"0":"c","1":"3","filename":"c.csv"}` + "\n"
+			for i := 0; i < 100 && out.String() != expect; i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+			require.Equal(t, expect, out.String())
+
+			// the files are renamed, moved or deleted after the records are handled
+			listFiles := func() []string {
+				exists := []string{}
+				filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+					if path != dir {
+						rel, _ := filepath.Rel(dir, path)
+						exists = append(exists, rel)
+					}
+					return nil
+				})
+				return exists
+			}
+			for i := 0; i < 100 && !slices.Equal(tt.exists, listFiles()); i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+			pipeline.Stop()
+			require.Equal(t, tt.exists, listFiles())
+		})
+	}
+}
+
+func TestDirWatchOutletFail(t *testing.T) {
+	handled := make(chan struct{}, 1)
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name: "test-dir-watch-fail",
+		Factory: func(ctx *engine.Context) engine.Outlet {
+			return engine.OutletWithFunc(func(recs []engine.Record) error {
+				select {
+				case handled <- struct{}{}:
+				default:
+				}
+				return errors.New("fail")
+			})
+		},
+	})
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.csv"), []byte("a,1\n"), 0644))
+	dsl := fmt.Sprintf(`
+	[[inlets.dir_watch]]
+		path = %q
+		settle = "0s"
+		watch = "poll"
+	[[outlets.test-dir-watch-fail]]
+	`, dir)
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	require.NoError(t, err)
+	go pipeline.Run()
+
+	select {
+	case <-handled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the records are not handled")
+	}
+	pipeline.Stop()
+
+	// the file is left as it is, so that it is processed again
+	_, err = os.Stat(filepath.Join(dir, "a.csv"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "a.csv.done"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -1,10 +1,13 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -31,6 +34,14 @@ type httpInlet struct {
 	runCount int64
 
 	addr          string
+	method        string
+	headers       engine.Config
+	body          string
+	username      string
+	password      string
+	token         string
+	format        string
+	jsonPath      []string
 	successCode   int
 	runCountLimit int64
 }
@@ -38,12 +49,30 @@ type httpInlet struct {
 var _ = engine.Inlet((*httpInlet)(nil))
 
 func (hi *httpInlet) Open() error {
-	hi.addr = hi.ctx.Config().GetString("address", "")
-	hi.successCode = hi.ctx.Config().GetInt("success", 200)
-	timeout := hi.ctx.Config().GetDuration("timeout", 3*time.Second)
-	hi.runCountLimit = int64(hi.ctx.Config().GetInt("count", 1))
+	conf := hi.ctx.Config()
+	hi.addr = conf.GetString("address", "")
+	hi.method = strings.ToUpper(conf.GetString("method", http.MethodGet))
+	hi.headers = conf.GetConfig("headers", nil)
+	hi.body = conf.GetString("body", "")
+	hi.username = conf.GetString("username", "")
+	hi.password = conf.GetString("password", "")
+	hi.token = conf.GetString("token", "")
+	hi.format = conf.GetString("format", "")
+	hi.successCode = conf.GetInt("success", 200)
+	timeout := conf.GetDuration("timeout", 3*time.Second)
+	hi.runCountLimit = int64(conf.GetInt("count", 1))
 
-	hi.ctx.LogDebug("inlet.http", "address", hi.addr, "success", hi.successCode, "timeout", timeout)
+	if hi.format != "" && engine.GetDecoder(hi.format) == nil {
+		return fmt.Errorf("inlet.http format %q not found", hi.format)
+	}
+	if path := conf.GetString("json_path", ""); path != "" {
+		if hi.format != "" && hi.format != "json" {
+			return fmt.Errorf("inlet.http json_path is only available with json format")
+		}
+		hi.jsonPath = strings.Split(path, ".")
+	}
+
+	hi.ctx.LogDebug("inlet.http", "address", hi.addr, "method", hi.method, "success", hi.successCode, "timeout", timeout)
 
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -70,7 +99,12 @@ func (hi *httpInlet) Process(next engine.InletNextFunc) {
 	}
 	runCount := atomic.AddInt64(&hi.runCount, 1)
 
-	rsp, err := hi.client.Get(hi.addr)
+	req, err := hi.newRequest()
+	if err != nil {
+		next(nil, err)
+		return
+	}
+	rsp, err := hi.client.Do(req)
 	if err != nil {
 		next(nil, err)
 		return
@@ -94,52 +128,129 @@ func (hi *httpInlet) Process(next engine.InletNextFunc) {
 		resultErr = io.EOF
 	}
 
-	// TODO: support other content-type
-	// [x] application/json
-	// [ ] application/x-ndjson
-	// [ ] text/csv
-	if contentType := rsp.Header.Get("Content-Type"); strings.Contains(contentType, "application/json") {
-		obj := map[string]any{}
-		if err := json.Unmarshal(body, &obj); err != nil {
-			hi.ctx.LogWarn("inlet.http", "status", rsp.StatusCode, "unmarshal error", err.Error())
+	format := hi.format
+	if format == "" {
+		contentType := rsp.Header.Get("Content-Type")
+		reg := engine.GetDecoderByContentType(contentType)
+		if reg == nil {
+			hi.ctx.LogWarn("inlet.http", "status", rsp.StatusCode, "unsupported content-type", contentType)
+			next(nil, resultErr)
+			return
+		}
+		format = reg.Name
+	}
+
+	var src io.Reader = bytes.NewReader(body)
+	if enc := rsp.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		reg := engine.GetDecompressorByContentEncoding(enc)
+		if reg == nil {
+			hi.ctx.LogWarn("inlet.http", "status", rsp.StatusCode, "unsupported content-encoding", enc)
+			next(nil, resultErr)
+			return
+		}
+		dec := reg.Factory(src)
+		defer dec.Close()
+		src = dec
+	}
+
+	if len(hi.jsonPath) > 0 && format == "json" {
+		if src, err = hi.selectJSONPath(src); err != nil {
+			hi.ctx.LogWarn("inlet.http", "json_path", strings.Join(hi.jsonPath, "."), "error", err.Error())
 			next(nil, err)
 			return
 		}
-		ret := json2Record("", obj)
-		next([]engine.Record{ret}, resultErr)
-		return
-	} else {
-		hi.ctx.LogWarn("inlet.http", "status", rsp.StatusCode, "unsupported content-type", contentType)
-		next(nil, resultErr)
+	}
+
+	conf := maps.Clone(hi.ctx.Config())
+	conf.Set("format", format).Unset("compress")
+	reader, err := engine.NewReader(src, conf)
+	if err != nil {
+		next(nil, err)
 		return
 	}
+	defer reader.Close()
+
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			hi.ctx.LogWarn("inlet.http", "status", rsp.StatusCode, "decode error", err.Error())
+			next(nil, err)
+			return
+		}
+	}
+	next(ret, resultErr)
 }
 
-func json2Record(prefix string, obj map[string]any) engine.Record {
-	ret := engine.NewRecord()
-	for k, v := range obj {
-		ret = ret.Append(json2Field(prefix+k, v)...)
+func (hi *httpInlet) newRequest() (*http.Request, error) {
+	var body io.Reader
+	if hi.body != "" {
+		body = strings.NewReader(hi.body)
+	}
+	req, err := http.NewRequest(hi.method, hi.addr, body)
+	if err != nil {
+		return nil, err
+	}
+	for k := range hi.headers {
+		req.Header.Set(k, hi.headers.GetString(k, ""))
+	}
+	if hi.token != "" {
+		req.Header.Set("Authorization", "Bearer "+hi.token)
+	} else if hi.username != "" {
+		req.SetBasicAuth(hi.username, hi.password)
 	}
-	return ret
+	return req, nil
 }
 
-func json2Field(name string, v any) []*engine.Field {
-	switch v := v.(type) {
-	case float64:
-		return []*engine.Field{engine.NewField(name, v)}
-	case string:
-		return []*engine.Field{engine.NewField(name, v)}
-	case bool:
-		return []*engine.Field{engine.NewField(name, v)}
-	case map[string]any:
-		subRec := json2Record(name+".", v)
-		return subRec.Fields()
+// selectJSONPath returns the JSON objects at the json_path of the document in r
+// as a stream of new-line delimited JSON, so that the decoder makes a record of each object.
+// The path is the dot separated names of the objects, and the index of the array.
+// e.g. "data.items", "results.0.series"
+func (hi *httpInlet) selectJSONPath(r io.Reader) (io.Reader, error) {
+	var doc any
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	for _, name := range hi.jsonPath {
+		switch v := doc.(type) {
+		case map[string]any:
+			elm, ok := v[name]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", name)
+			}
+			doc = elm
+		case []any:
+			idx, err := strconv.Atoi(name)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, fmt.Errorf("invalid array index %q", name)
+			}
+			doc = v[idx]
+		default:
+			return nil, fmt.Errorf("%q is not an object or an array", name)
+		}
+	}
+
+	var objs []any
+	switch v := doc.(type) {
 	case []any:
-		ret := []*engine.Field{}
-		for i, v := range v {
-			ret = append(ret, json2Field(fmt.Sprintf("%s[%d]", name, i), v)...)
+		objs = v
+	case map[string]any:
+		objs = []any{v}
+	default:
+		return nil, fmt.Errorf("%T is not an object or an array", v)
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for _, obj := range objs {
+		if _, ok := obj.(map[string]any); !ok {
+			return nil, fmt.Errorf("%T is not an object", obj)
+		}
+		if err := enc.Encode(obj); err != nil {
+			return nil, err
 		}
-		return ret
 	}
-	return nil
+	return buf, nil
 }
@@ -2,6 +2,7 @@ package http_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"net"
@@ -27,6 +28,30 @@ func runInletTestServer() (string, error) {
 				w.Header().Set("Content-Type", "application/json")
 				w.Write([]byte(`{"a":1, "b":{"c":true, "d":3.14, "str":"text"}, "arr":["first", 2, 3.14, true]}` + "\n"))
 				w.WriteHeader(200)
+			case "/ndjson":
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.Write([]byte(`{"name":"a","value":1}` + "\n" + `{"name":"b","value":2}` + "\n"))
+			case "/csv":
+				w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+				w.Write([]byte("a,1\nb,2\n"))
+			case "/gzip":
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", "gzip")
+				zw := gzip.NewWriter(w)
+				zw.Write([]byte(`{"name":"gz","value":1}`))
+				zw.Close()
+			case "/items":
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"status":"ok","data":{"items":[{"name":"a","value":1},{"name":"b","value":{"x":2}}]}}`))
+			case "/query":
+				user, pass, ok := r.BasicAuth()
+				if r.Method != http.MethodPost || !ok || user != "user" || pass != "pass" || r.Header.Get("X-Query") != "yes" {
+					w.WriteHeader(401)
+					return
+				}
+				body, _ := io.ReadAll(r.Body)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(fmt.Sprintf(`{"query":%q}`, string(body))))
 			case "/binary":
 				w.Header().Set("Content-Type", "application/octet-stream")
 				w.Write([]byte{0x01, 0x02, 0x03})
@@ -59,6 +84,7 @@ func TestHttpInlet(t *testing.T) {
 	tests := []struct {
 		name       string
 		path       string
+		conf       string
 		expectBody string
 	}{
 		{
@@ -76,6 +102,54 @@ func TestHttpInlet(t *testing.T) {
 			path:       "/binary",
 			expectBody: "",
 		},
+		{
+			name: "ndjson",
+			path: "/ndjson",
+			expectBody: `{"_in":"http","_ts":1721954797,"name":"a","value":1}` + "\n" +
+				`{"_in":"http","_ts":1721954797,"name":"b","value":2}`,
+		},
+		{
+			name: "csv",
+			path: "/csv",
+			conf: `fields = ["name", "value"]
+				types = ["string", "int"]`,
+			expectBody: `{"_in":"http","_ts":1721954797,"name":"a","value":1}` + "\n" +
+				`{"_in":"http","_ts":1721954797,"name":"b","value":2}`,
+		},
+		{
+			name:       "explicit format",
+			path:       "/binary",
+			conf:       `format = "json"`,
+			expectBody: "",
+		},
+		{
+			name:       "gzip",
+			path:       "/gzip",
+			conf:       `headers = { "Accept-Encoding" = "gzip" }`,
+			expectBody: `{"_in":"http","_ts":1721954797,"name":"gz","value":1}`,
+		},
+		{
+			name: "json_path",
+			path: "/items",
+			conf: `json_path = "data.items"`,
+			expectBody: `{"_in":"http","_ts":1721954797,"name":"a","value":1}` + "\n" +
+				`{"_in":"http","_ts":1721954797,"name":"b","value.x":2}`,
+		},
+		{
+			name: "request",
+			path: "/query",
+			conf: `method = "post"
+				body = "select 1"
+				username = "user"
+				password = "pass"
+				headers = { "X-Query" = "yes" }`,
+			expectBody: `{"_in":"http","_ts":1721954797,"query":"select 1"}`,
+		},
+		{
+			name:       "unauthorized",
+			path:       "/query",
+			expectBody: "",
+		},
 	}
 
 	for _, tt := range tests {
@@ -84,12 +158,14 @@ func TestHttpInlet(t *testing.T) {
 				address = "http://%s%s"
 				success = 200
 				timeout = "3s"
+				interval = "100ms"
 				count = 1
+				%s
 			[[flows.select]]
 				includes = ["**"]
 			[[outlets.file]]
 				format = "json"
-			`, addr, tt.path)
+			`, addr, tt.path, tt.conf)
 		// Make the output time deterministic. so we can compare it.
 		// This line is not needed in production code.
 		engine.Now = func() time.Time { return time.Unix(1721954797, 0) }
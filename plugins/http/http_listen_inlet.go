@@ -0,0 +1,226 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "http_listen",
+		Factory: HttpListenInlet,
+	})
+}
+
+func HttpListenInlet(ctx *engine.Context) engine.Inlet {
+	return &httpListenInlet{
+		ctx:     ctx,
+		pushCh:  make(chan []engine.Record),
+		closeCh: make(chan struct{}),
+	}
+}
+
+type httpListenInlet struct {
+	ctx *engine.Context
+
+	path        string
+	format      string
+	maxBodySize int64
+	successCode int
+	username    string
+	password    string
+	token       string
+
+	lsnr      net.Listener
+	svr       *http.Server
+	pushCh    chan []engine.Record
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+var _ = engine.Inlet((*httpListenInlet)(nil))
+
+func (hi *httpListenInlet) Open() error {
+	conf := hi.ctx.Config()
+	address := conf.GetString("address", "127.0.0.1:8080")
+	hi.path = conf.GetString("path", "/")
+	hi.format = conf.GetString("format", "")
+	hi.maxBodySize = conf.GetInt64("max_body_size", 10*1024*1024)
+	hi.successCode = conf.GetInt("success", http.StatusOK)
+	hi.username = conf.GetString("username", "")
+	hi.password = conf.GetString("password", "")
+	hi.token = conf.GetString("token", "")
+
+	if hi.format != "" && engine.GetDecoder(hi.format) == nil {
+		return fmt.Errorf("inlet.http_listen format %q not found", hi.format)
+	}
+
+	lsnr, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	hi.lsnr = lsnr
+	hi.ctx.LogDebug("inlet.http_listen", "address", lsnr.Addr().String(), "path", hi.path)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(hi.path, hi.handle)
+	hi.svr = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: conf.GetDuration("timeout", 10*time.Second),
+	}
+	go func() {
+		if err := hi.svr.Serve(lsnr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			hi.ctx.LogError("inlet.http_listen", "error", err.Error())
+		}
+	}()
+	return nil
+}
+
+func (hi *httpListenInlet) Close() error {
+	hi.closeOnce.Do(func() {
+		if hi.svr != nil {
+			// wait for the handlers those are pushing records,
+			// the handlers still waiting after the timeout respond 503 by closeCh
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			hi.svr.Shutdown(ctx)
+			cancel()
+		}
+		close(hi.closeCh)
+	})
+	return nil
+}
+
+func (hi *httpListenInlet) Process(next engine.InletNextFunc) {
+	for {
+		select {
+		case <-hi.closeCh:
+			return
+		case recs := <-hi.pushCh:
+			next(recs, nil)
+		}
+	}
+}
+
+func (hi *httpListenInlet) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !hi.authorized(r) {
+		if hi.token == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="tine"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	format := hi.format
+	if format == "" {
+		reg := engine.GetDecoderByContentType(r.Header.Get("Content-Type"))
+		if reg == nil {
+			http.Error(w, fmt.Sprintf("unsupported content-type %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+			return
+		}
+		format = reg.Name
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, hi.maxBodySize)
+	if enc := r.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		reg := engine.GetDecompressorByContentEncoding(enc)
+		if reg == nil {
+			http.Error(w, fmt.Sprintf("unsupported content-encoding %q", enc), http.StatusUnsupportedMediaType)
+			return
+		}
+		dec := reg.Factory(body)
+		defer dec.Close()
+		body = dec
+	}
+
+	conf := maps.Clone(hi.ctx.Config())
+	conf.Set("format", format).Unset("compress")
+	reader, err := engine.NewReader(body, conf)
+	if err != nil {
+		http.Error(w, err.Error(), readErrorCode(err, http.StatusInternalServerError))
+		return
+	}
+	defer reader.Close()
+
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			hi.ctx.LogWarn("inlet.http_listen", "remote", r.RemoteAddr, "error", err.Error())
+			http.Error(w, err.Error(), readErrorCode(err, http.StatusBadRequest))
+			return
+		}
+	}
+	if len(ret) > 0 {
+		// respond after the records are handled by the outlets
+		ackCh := make(chan error, 1)
+		select {
+		case hi.pushCh <- engine.WithAck(ret, func(err error) { ackCh <- err }):
+		case <-r.Context().Done():
+			// the client has gone away
+			return
+		case <-hi.closeCh:
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		select {
+		case err := <-ackCh:
+			if errors.Is(err, engine.ErrAckStopped) {
+				http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+				return
+			} else if err != nil {
+				hi.ctx.LogWarn("inlet.http_listen", "remote", r.RemoteAddr, "error", err.Error())
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-hi.closeCh:
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.WriteHeader(hi.successCode)
+}
+
+// readErrorCode returns the status code of the error reading the request body
+func readErrorCode(err error, code int) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return code
+}
+
+func (hi *httpListenInlet) authorized(r *http.Request) bool {
+	if hi.token != "" {
+		auth := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(token), []byte(hi.token)) == 1
+	}
+	if hi.username != "" {
+		user, pass, ok := r.BasicAuth()
+		return ok &&
+			subtle.ConstantTimeCompare([]byte(user), []byte(hi.username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(pass), []byte(hi.password)) == 1
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package http_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttpListenInlet(t *testing.T) {
+	lsnr, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lsnr.Addr().String()
+	lsnr.Close()
+
+	dsl := fmt.Sprintf(`
+		[[inlets.http_listen]]
+			address = "%s"
+			path = "/push"
+			token = "secret"
+			max_body_size = 64
+			fields = ["name", "value"]
+			types = ["string", "int"]
+		[[outlets.file]]
+			format = "json"
+		`, addr)
+	out := &bytes.Buffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+
+	gzipped := &bytes.Buffer{}
+	gw := gzip.NewWriter(gzipped)
+	gw.Write([]byte("c,3\nd,4\n"))
+	gw.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		token       string
+		contentType string
+		encoding    string
+		body        []byte
+		expectCode  int
+	}{
+		{"json", "POST", "/push", "secret", "application/json", "", []byte(`{"name":"a","value":1}`), 200},
+		{"csv", "POST", "/push", "secret", "text/csv", "", []byte("b,2\n"), 200},
+		{"gzip", "PUT", "/push", "secret", "text/csv; charset=utf-8", "gzip", gzipped.Bytes(), 200},
+		{"unauthorized", "POST", "/push", "wrong", "text/csv", "", []byte("x,0\n"), 401},
+		{"method", "GET", "/push", "secret", "", "", nil, 405},
+		{"content-type", "POST", "/push", "secret", "application/octet-stream", "", []byte{0x01}, 415},
+		{"encoding", "POST", "/push", "secret", "text/csv", "br", []byte{0x01}, 415},
+		{"encoding-zlib", "POST", "/push", "secret", "text/csv", "zlib", []byte{0x01}, 415},
+		{"bad body", "POST", "/push", "secret", "application/json", "", []byte(`{"name":`), 400},
+		{"too large", "POST", "/push", "secret", "text/csv", "", bytes.Repeat([]byte("x,0\n"), 20), 413},
+		{"not found", "POST", "/other", "secret", "text/csv", "", []byte("x,0\n"), 404},
+	}
+
+	for _, tt := range tests {
+		var rsp *http.Response
+		// wait until the server is ready
+		for i := 0; i < 20; i++ {
+			req, _ := http.NewRequest(tt.method, fmt.Sprintf("http://%s%s", addr, tt.path), bytes.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rsp, err = http.DefaultClient.Do(req)
+			if err == nil {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		require.NoError(t, err, tt.name)
+		rsp.Body.Close()
+		require.Equal(t, tt.expectCode, rsp.StatusCode, tt.name)
+	}
+
+	pipeline.Stop()
+	require.Equal(t, `{"name":"a","value":1}
+{"name":"b","value":2}
+{"name":"c","value":3}
+{"name":"d","value":4}
+`, out.String())
+}
+
+func TestHttpListenInletOutletFail(t *testing.T) {
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name: "test-http-listen-fail",
+		Factory: func(ctx *engine.Context) engine.Outlet {
+			return engine.OutletWithFunc(func(recs []engine.Record) error {
+				return errors.New("fail")
+			})
+		},
+	})
+	lsnr, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lsnr.Addr().String()
+	lsnr.Close()
+
+	dsl := fmt.Sprintf(`
+		[[inlets.http_listen]]
+			address = "%s"
+		[[outlets.test-http-listen-fail]]
+		`, addr)
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	require.NoError(t, err)
+	go pipeline.Run()
+	defer pipeline.Stop()
+
+	var rsp *http.Response
+	for i := 0; i < 20; i++ {
+		rsp, err = http.Post(fmt.Sprintf("http://%s/", addr), "text/csv", bytes.NewReader([]byte("a,1\n")))
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	rsp.Body.Close()
+	// the failure of the outlet is responded
+	require.Equal(t, http.StatusInternalServerError, rsp.StatusCode)
+}
@@ -0,0 +1,253 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "kafka",
+		Factory: KafkaInlet,
+	})
+}
+
+func KafkaInlet(ctx *engine.Context) engine.Inlet {
+	return &kafkaInlet{
+		ctx:    ctx,
+		doneCh: make(chan struct{}),
+	}
+}
+
+type kafkaInlet struct {
+	ctx             *engine.Context
+	conf            engine.Config
+	client          *kgo.Client
+	group           string
+	timeout         time.Duration
+	dropUndecodable bool
+	pendingWg       sync.WaitGroup
+
+	pollCtx    context.Context
+	pollCancel context.CancelFunc
+	running    bool
+	doneCh     chan struct{}
+	mutex      sync.Mutex
+	closeOnce  sync.Once
+}
+
+var _ = engine.Inlet((*kafkaInlet)(nil))
+
+func (ki *kafkaInlet) Open() error {
+	conf := ki.ctx.Config()
+	brokers := conf.GetStringSlice("brokers", []string{"127.0.0.1:9092"})
+	topics := conf.GetStringSlice("topics", nil)
+	group := conf.GetString("group", "")
+	ki.group = group
+	ki.timeout = conf.GetDuration("timeout", 3*time.Second)
+	ki.dropUndecodable = conf.GetBool("drop_undecodable", true)
+	if len(topics) == 0 {
+		return fmt.Errorf("inlet.kafka topics are required")
+	}
+
+	format := conf.GetString("format", "json")
+	if engine.GetDecoder(format) == nil {
+		return fmt.Errorf("inlet.kafka format %q not found", format)
+	}
+	ki.conf = maps.Clone(conf).Set("format", format)
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.ClientID(conf.GetString("client_id", "tine")),
+		kgo.ConsumeTopics(topics...),
+		kgo.DialTimeout(ki.timeout),
+	}
+	switch start := conf.GetString("start", "latest"); start {
+	case "earliest":
+		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+	case "latest":
+		opts = append(opts, kgo.ConsumeResetOffset(kgo.NewOffset().AtEnd()))
+	default:
+		return fmt.Errorf("inlet.kafka unknown start %q", start)
+	}
+	if group != "" {
+		// only the offsets of the records those are handled by the outlets are committed,
+		// periodically, when the partitions are revoked and when the inlet is closed.
+		opts = append(opts,
+			kgo.ConsumerGroup(group),
+			kgo.AutoCommitMarks(),
+			kgo.AutoCommitInterval(conf.GetDuration("commit_interval", 5*time.Second)),
+		)
+	}
+	if user := conf.GetString("username", ""); user != "" {
+		opts = append(opts, saslOpt(conf.GetString("sasl", "plain"), user, conf.GetString("password", "")))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return fmt.Errorf("inlet.kafka %w", err)
+	}
+	ki.client = client
+	ki.pollCtx, ki.pollCancel = context.WithCancel(ki.ctx)
+	ki.ctx.LogDebug("inlet.kafka", "brokers", brokers, "topics", topics, "group", group)
+	return nil
+}
+
+func (ki *kafkaInlet) Close() error {
+	ki.closeOnce.Do(func() {
+		ki.pollCancel()
+		ki.mutex.Lock()
+		running := ki.running
+		ki.mutex.Unlock()
+		if running {
+			<-ki.doneCh
+		}
+		// wait a while for the records in the pipeline to be handled and marked,
+		// the records those are not marked are consumed again by the group.
+		ki.waitPending()
+		// commits the marked offsets and leaves the group
+		ki.client.Close()
+	})
+	return nil
+}
+
+func (ki *kafkaInlet) Process(next engine.InletNextFunc) {
+	ki.mutex.Lock()
+	if ki.pollCtx.Err() != nil {
+		ki.mutex.Unlock()
+		return
+	}
+	ki.running = true
+	ki.mutex.Unlock()
+	defer close(ki.doneCh)
+
+	for {
+		fetches := ki.client.PollFetches(ki.pollCtx)
+		if ki.pollCtx.Err() != nil || fetches.IsClientClosed() {
+			return
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			if !errors.Is(err, context.Canceled) {
+				ki.ctx.LogWarn("inlet.kafka", "topic", topic, "partition", partition, "error", err.Error())
+			}
+		})
+
+		ret := []engine.Record{}
+		krecs := []*kgo.Record{}
+		var decodeErr error
+		fetches.EachRecord(func(kr *kgo.Record) {
+			if decodeErr != nil {
+				return
+			}
+			recs, err := ki.records(kr)
+			if err != nil {
+				if !ki.dropUndecodable {
+					// stop before the record, not to commit its offset
+					decodeErr = err
+					return
+				}
+				ki.ctx.LogWarn("inlet.kafka", "topic", kr.Topic, "partition", kr.Partition, "offset", kr.Offset, "drop", err.Error())
+			}
+			krecs = append(krecs, kr)
+			ret = append(ret, recs...)
+		})
+		if len(krecs) > 0 {
+			ki.send(next, ret, krecs)
+		}
+		if decodeErr != nil {
+			// next closes the inlet, it should not wait for this loop
+			ki.mutex.Lock()
+			ki.running = false
+			ki.mutex.Unlock()
+			next(nil, decodeErr)
+			return
+		}
+	}
+}
+
+// send passes the records to the pipeline, the kafka records are marked to be committed
+// after the records are handled by the outlets.
+func (ki *kafkaInlet) send(next engine.InletNextFunc, ret []engine.Record, krecs []*kgo.Record) {
+	if ki.group == "" {
+		if len(ret) > 0 {
+			next(ret, nil)
+		}
+		return
+	}
+	ki.pendingWg.Add(1)
+	next(engine.WithAck(ret, func(err error) {
+		defer ki.pendingWg.Done()
+		if err != nil {
+			// the pipeline stops by the failure of the outlet, the records are consumed again
+			return
+		}
+		ki.client.MarkCommitRecords(krecs...)
+	}), nil)
+}
+
+// waitPending waits until the sent records are handled or the timeout expires
+func (ki *kafkaInlet) waitPending() {
+	done := make(chan struct{})
+	go func() {
+		ki.pendingWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ki.timeout):
+		ki.ctx.LogWarn("inlet.kafka", "group", ki.group, "timeout", "waiting for the records to be handled")
+	}
+}
+
+// records decodes the kafka record, it returns an error if the value can not be decoded.
+func (ki *kafkaInlet) records(kr *kgo.Record) ([]engine.Record, error) {
+	reader, err := engine.NewReader(bytes.NewReader(kr.Value), ki.conf)
+	if err != nil {
+		return nil, fmt.Errorf("inlet.kafka topic %q partition %d offset %d, %w", kr.Topic, kr.Partition, kr.Offset, err)
+	}
+	defer reader.Close()
+
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("inlet.kafka topic %q partition %d offset %d decode, %w", kr.Topic, kr.Partition, kr.Offset, err)
+		}
+	}
+	for _, r := range ret {
+		tags := r.Tags()
+		tags.Set("topic", engine.NewValue(kr.Topic))
+		tags.Set("partition", engine.NewValue(int64(kr.Partition)))
+		tags.Set("offset", engine.NewValue(kr.Offset))
+		if kr.Key != nil {
+			tags.Set("key", engine.NewValue(string(kr.Key)))
+		}
+	}
+	return ret, nil
+}
+
+// saslOpt returns the SASL authentication option of the mechanism
+func saslOpt(mechanism string, user string, pass string) kgo.Opt {
+	switch mechanism {
+	case "scram-sha-256":
+		return kgo.SASL(scram.Auth{User: user, Pass: pass}.AsSha256Mechanism())
+	case "scram-sha-512":
+		return kgo.SASL(scram.Auth{User: user, Pass: pass}.AsSha512Mechanism())
+	default:
+		return kgo.SASL(plain.Auth{User: user, Pass: pass}.AsMechanism())
+	}
+}
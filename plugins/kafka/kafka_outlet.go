@@ -0,0 +1,166 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func init() {
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name:    "kafka",
+		Factory: KafkaOutlet,
+	})
+}
+
+func KafkaOutlet(ctx *engine.Context) engine.Outlet {
+	return &kafkaOutlet{ctx: ctx}
+}
+
+type kafkaOutlet struct {
+	ctx      *engine.Context
+	conf     engine.Config
+	topic    string
+	keyField string
+	timeout  time.Duration
+	client   *kgo.Client
+}
+
+var _ = engine.Outlet((*kafkaOutlet)(nil))
+
+func (ko *kafkaOutlet) Open() error {
+	conf := ko.ctx.Config()
+	brokers := conf.GetStringSlice("brokers", []string{"127.0.0.1:9092"})
+	ko.topic = conf.GetString("topic", "")
+	ko.keyField = conf.GetString("key_field", "")
+	ko.timeout = conf.GetDuration("timeout", 3*time.Second)
+	if ko.topic == "" {
+		return fmt.Errorf("outlet.kafka topic is required")
+	}
+
+	format := conf.GetString("format", "json")
+	if engine.GetEncoder(format) == nil {
+		return fmt.Errorf("outlet.kafka format %q not found", format)
+	}
+	// compression is done by the kafka client per batch
+	ko.conf = maps.Clone(conf).Set("format", format).Unset("compress")
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.ClientID(conf.GetString("client_id", "tine")),
+		kgo.DefaultProduceTopic(ko.topic),
+		kgo.DialTimeout(ko.timeout),
+		kgo.ProducerLinger(conf.GetDuration("linger", 0)),
+		kgo.ProducerBatchMaxBytes(int32(conf.GetInt("batch_max_bytes", 1000000))),
+	}
+
+	switch partitioner := conf.GetString("partitioner", "hash"); partitioner {
+	case "hash":
+		// records with the same key go to the same partition
+		opts = append(opts, kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)))
+	case "round_robin":
+		opts = append(opts, kgo.RecordPartitioner(kgo.RoundRobinPartitioner()))
+	case "sticky":
+		opts = append(opts, kgo.RecordPartitioner(kgo.StickyPartitioner()))
+	case "least_backup":
+		opts = append(opts, kgo.RecordPartitioner(kgo.LeastBackupPartitioner()))
+	default:
+		return fmt.Errorf("outlet.kafka unknown partitioner %q", partitioner)
+	}
+
+	switch compress := conf.GetString("compress", "none"); compress {
+	case "none", "":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.NoCompression()))
+	case "gzip":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.GzipCompression()))
+	case "snappy":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.SnappyCompression()))
+	case "lz4":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.Lz4Compression()))
+	case "zstd":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.ZstdCompression()))
+	default:
+		return fmt.Errorf("outlet.kafka unknown compress %q", compress)
+	}
+
+	switch acks := conf.GetString("acks", "all"); acks {
+	case "all":
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	case "leader":
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	case "none":
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite())
+	default:
+		return fmt.Errorf("outlet.kafka unknown acks %q", acks)
+	}
+
+	if user := conf.GetString("username", ""); user != "" {
+		opts = append(opts, saslOpt(conf.GetString("sasl", "plain"), user, conf.GetString("password", "")))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return fmt.Errorf("outlet.kafka %w", err)
+	}
+	ko.client = client
+	ko.ctx.LogDebug("outlet.kafka", "brokers", brokers, "topic", ko.topic)
+	return nil
+}
+
+func (ko *kafkaOutlet) Close() error {
+	if ko.client != nil {
+		ctx, cancel := context.WithTimeout(ko.ctx, ko.timeout)
+		ko.client.Flush(ctx)
+		cancel()
+		ko.client.Close()
+	}
+	return nil
+}
+
+// Handle produces a message for each record, and waits until all messages are acknowledged.
+func (ko *kafkaOutlet) Handle(recs []engine.Record) error {
+	krecs := make([]*kgo.Record, 0, len(recs))
+	for _, r := range recs {
+		if r.Empty() {
+			continue
+		}
+		data := &bytes.Buffer{}
+		w, err := engine.NewWriter(data, ko.conf)
+		if err != nil {
+			return err
+		}
+		if err := w.Write([]engine.Record{r}); err != nil {
+			return err
+		}
+		w.Close()
+
+		kr := &kgo.Record{
+			Value:   bytes.TrimSuffix(data.Bytes(), []byte("\n")),
+			Headers: []kgo.RecordHeader{{Key: "Content-Type", Value: []byte(w.ContentType)}},
+		}
+		if ko.keyField != "" {
+			if f := r.Field(ko.keyField); f != nil && !f.IsNull() {
+				if b, ok := f.Value.Raw().([]byte); ok {
+					kr.Key = b
+				} else {
+					kr.Key = []byte(f.Value.Format(engine.DefaultValueFormat()))
+				}
+			}
+		}
+		krecs = append(krecs, kr)
+	}
+	if len(krecs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ko.ctx, ko.timeout)
+	defer cancel()
+	if err := ko.client.ProduceSync(ctx, krecs...).FirstErr(); err != nil {
+		return fmt.Errorf("outlet.kafka produce %q, %w", ko.topic, err)
+	}
+	return nil
+}
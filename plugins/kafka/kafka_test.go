@@ -0,0 +1,168 @@
+package kafka_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/kafka"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+func TestKafka(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(2, "metrics"))
+	require.NoError(t, err)
+	defer cluster.Close()
+	brokers := fmt.Sprintf("%q", cluster.ListenAddrs()[0])
+
+	outDsl := fmt.Sprintf(`
+		[[inlets.file]]
+			data = ["a,1", "b,2", "a,3"]
+			format = "csv"
+			fields = ["name", "value"]
+			types = ["string", "int"]
+		[[outlets.kafka]]
+			brokers = [%s]
+			topic = "metrics"
+			key_field = "name"
+			compress = "gzip"
+		`, brokers)
+	outPipeline, err := engine.New(engine.WithConfig(outDsl))
+	require.NoError(t, err)
+	require.NoError(t, outPipeline.Run())
+
+	inDsl := fmt.Sprintf(`
+		[[inlets.kafka]]
+			brokers = [%s]
+			topics = ["metrics"]
+			group = "tine"
+			start = "earliest"
+		[[flows.select]]
+			includes = ["#key", "#partition", "#offset", "*"]
+		[[outlets.file]]
+			format = "json"
+		`, brokers)
+	out := &syncBuffer{}
+	inPipeline, err := engine.New(engine.WithConfig(inDsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go inPipeline.Run()
+
+	for i := 0; i < 200 && strings.Count(out.String(), "\n") < 3; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	inPipeline.Stop()
+
+	// records of the same key are in the same partition in order
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3, out.String())
+	partitions := map[string]float64{}
+	values := map[string][]float64{}
+	for _, line := range lines {
+		m := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(line), &m))
+		key := m["key"].(string)
+		require.Equal(t, m["name"], key, line)
+		if p, ok := partitions[key]; ok {
+			require.Equal(t, p, m["partition"], line)
+		}
+		partitions[key] = m["partition"].(float64)
+		values[key] = append(values[key], m["value"].(float64))
+	}
+	require.Equal(t, []float64{1, 3}, values["a"])
+	require.Equal(t, []float64{2}, values["b"])
+
+	// the offsets of the delivered records are committed
+	client, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...))
+	require.NoError(t, err)
+	defer client.Close()
+	adm := kadm.NewClient(client)
+	offsets, err := adm.FetchOffsets(context.Background(), "tine")
+	require.NoError(t, err)
+	committed := int64(0)
+	offsets.Each(func(o kadm.OffsetResponse) {
+		committed += o.At
+	})
+	require.Equal(t, int64(3), committed)
+}
+
+func TestKafkaUndecodable(t *testing.T) {
+	tests := []struct {
+		drop      bool
+		expect    string
+		committed int64
+	}{
+		{drop: true, expect: `{"name":"a"}` + "\n" + `{"name":"b"}` + "\n", committed: 3},
+		{drop: false, expect: `{"name":"a"}` + "\n", committed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("drop_%v", tt.drop), func(t *testing.T) {
+			cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "events"))
+			require.NoError(t, err)
+			defer cluster.Close()
+
+			client, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...))
+			require.NoError(t, err)
+			defer client.Close()
+			for _, v := range []string{`{"name":"a"}`, `not json`, `{"name":"b"}`} {
+				err := client.ProduceSync(context.Background(), &kgo.Record{Topic: "events", Value: []byte(v)}).FirstErr()
+				require.NoError(t, err)
+			}
+
+			inDsl := fmt.Sprintf(`
+				[[inlets.kafka]]
+					brokers = [%q]
+					topics = ["events"]
+					group = "tine"
+					start = "earliest"
+					drop_undecodable = %v
+				[[outlets.file]]
+					format = "json"
+				`, cluster.ListenAddrs()[0], tt.drop)
+			out := &syncBuffer{}
+			inPipeline, err := engine.New(engine.WithConfig(inDsl), engine.WithWriter(out))
+			require.NoError(t, err)
+			go inPipeline.Run()
+			for i := 0; i < 200 && len(out.String()) < len(tt.expect); i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+			inPipeline.Stop()
+			require.Equal(t, tt.expect, out.String())
+
+			// the offset of the undecodable record is not committed if it is not dropped
+			offsets, err := kadm.NewClient(client).FetchOffsets(context.Background(), "tine")
+			require.NoError(t, err)
+			committed := int64(0)
+			offsets.Each(func(o kadm.OffsetResponse) {
+				committed += o.At
+			})
+			require.Equal(t, tt.committed, committed)
+		})
+	}
+}
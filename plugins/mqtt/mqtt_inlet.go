@@ -0,0 +1,258 @@
+package mqtt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "mqtt",
+		Factory: MqttInlet,
+	})
+}
+
+func MqttInlet(ctx *engine.Context) engine.Inlet {
+	return &mqttInlet{
+		ctx:     ctx,
+		pushCh:  make(chan []engine.Record),
+		closeCh: make(chan struct{}),
+	}
+}
+
+type mqttInlet struct {
+	ctx *engine.Context
+
+	host     string
+	qos      byte
+	timeout  time.Duration
+	topicTag string
+	conf     engine.Config
+	filters  []*topicFilter
+	client   paho.Client
+
+	pushCh    chan []engine.Record
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+var _ = engine.Inlet((*mqttInlet)(nil))
+
+// topicFilter is a subscription filter,
+// the wildcard levels can be named like "sensors/+site/+device/#rest"
+// to map the matched levels to the tags.
+type topicFilter struct {
+	filter string   // the filter to subscribe, names are removed e.g. "sensors/+/+/#"
+	levels []string // the levels of the filter
+	names  []string // the tag names of the wildcard levels, empty if not named
+}
+
+func parseTopicFilter(str string) (*topicFilter, error) {
+	ret := &topicFilter{}
+	share := ""
+	if strings.HasPrefix(str, "$share/") {
+		// shared subscription "$share/{group}/{filter}"
+		parts := strings.SplitN(str, "/", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid topic %q, shared subscription requires a filter", str)
+		}
+		share, str = parts[0]+"/"+parts[1]+"/", parts[2]
+	}
+	levels := strings.Split(str, "/")
+	for i, lv := range levels {
+		if strings.HasPrefix(lv, "+") || strings.HasPrefix(lv, "#") {
+			if lv[0] == '#' && i != len(levels)-1 {
+				return nil, fmt.Errorf("invalid topic %q, '#' should be the last level", str)
+			}
+			ret.names = append(ret.names, lv[1:])
+			lv = lv[:1]
+		} else if strings.ContainsAny(lv, "+#") {
+			return nil, fmt.Errorf("invalid topic %q, wildcard should occupy an entire level", str)
+		} else {
+			ret.names = append(ret.names, "")
+		}
+		ret.levels = append(ret.levels, lv)
+	}
+	ret.filter = share + strings.Join(ret.levels, "/")
+	return ret, nil
+}
+
+// match returns the tags of the named wildcard levels,
+// and false if the topic does not match the filter.
+func (tf *topicFilter) match(topic string) (map[string]string, bool) {
+	levels := strings.Split(topic, "/")
+	ret := map[string]string{}
+	for i, lv := range tf.levels {
+		switch {
+		case lv == "#":
+			if tf.names[i] != "" {
+				ret[tf.names[i]] = strings.Join(levels[i:], "/")
+			}
+			return ret, true
+		case i >= len(levels):
+			return nil, false
+		case lv == "+":
+			if tf.names[i] != "" {
+				ret[tf.names[i]] = levels[i]
+			}
+		case lv != levels[i]:
+			return nil, false
+		}
+	}
+	return ret, len(levels) == len(tf.levels)
+}
+
+func (mi *mqttInlet) Open() error {
+	id := atomic.AddUint64(&serial, 1)
+
+	conf := mi.ctx.Config()
+	mi.host = conf.GetString("server", "tcp://127.0.0.1:1883")
+	mi.qos = byte(conf.GetInt("qos", 1))
+	mi.timeout = conf.GetDuration("timeout", 3*time.Second)
+	mi.topicTag = conf.GetString("topic_tag", "topic")
+	clientId := conf.GetString("client_id", "")
+	cleanSession := conf.GetBool("clean_session", true)
+
+	if mi.qos > 2 {
+		return fmt.Errorf("inlet.mqtt invalid qos %d", mi.qos)
+	}
+	if !cleanSession && clientId == "" {
+		return fmt.Errorf("inlet.mqtt client_id is required for the persistent session")
+	}
+	if clientId == "" {
+		clientId = fmt.Sprintf("mqtt-%d", id)
+	}
+	format := conf.GetString("format", "json")
+	if engine.GetDecoder(format) == nil {
+		return fmt.Errorf("inlet.mqtt format %q not found", format)
+	}
+	mi.conf = maps.Clone(conf).Set("format", format)
+	topics := conf.GetStringSlice("topics", nil)
+	if len(topics) == 0 {
+		return fmt.Errorf("inlet.mqtt topics are required")
+	}
+	filters := map[string]byte{}
+	for _, t := range topics {
+		tf, err := parseTopicFilter(t)
+		if err != nil {
+			return fmt.Errorf("inlet.mqtt %w", err)
+		}
+		mi.filters = append(mi.filters, tf)
+		filters[tf.filter] = mi.qos
+	}
+
+	opts := paho.NewClientOptions()
+	opts.SetCleanSession(cleanSession)
+	opts.SetConnectRetry(false)
+	opts.SetAutoReconnect(true)
+	opts.SetProtocolVersion(4)
+	opts.SetClientID(clientId)
+	opts.AddBroker(mi.host)
+	opts.SetKeepAlive(60 * time.Second)
+	opts.SetUsername(conf.GetString("username", ""))
+	opts.SetPassword(conf.GetString("password", ""))
+	opts.SetDefaultPublishHandler(mi.handle)
+	opts.SetOnConnectHandler(func(c paho.Client) {
+		// subscribe again on reconnect,
+		// the persistent session keeps the subscriptions in the broker, but it does not hurt
+		tok := c.SubscribeMultiple(filters, mi.handle)
+		if tok.WaitTimeout(mi.timeout) && tok.Error() != nil {
+			mi.ctx.LogError("inlet.mqtt", "subscribe", topics, "error", tok.Error().Error())
+		}
+	})
+	opts.SetConnectionLostHandler(func(c paho.Client, err error) {
+		mi.ctx.LogWarn("inlet.mqtt", "connection lost", err.Error())
+	})
+
+	mi.ctx.LogDebug("inlet.mqtt", "server", mi.host, "client_id", clientId, "topics", topics, "qos", mi.qos)
+	mi.client = paho.NewClient(opts)
+	token := mi.client.Connect()
+	if !token.WaitTimeout(mi.timeout) {
+		return fmt.Errorf("inlet.mqtt connect timeout %s", mi.host)
+	}
+	if token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (mi *mqttInlet) Close() error {
+	mi.closeOnce.Do(func() {
+		close(mi.closeCh)
+		if mi.client != nil {
+			mi.client.Disconnect(250)
+		}
+	})
+	return nil
+}
+
+func (mi *mqttInlet) Process(next engine.InletNextFunc) {
+	for {
+		select {
+		case <-mi.closeCh:
+			return
+		case recs := <-mi.pushCh:
+			select {
+			case <-mi.closeCh:
+				// closed while waiting, drop it
+				return
+			default:
+				next(recs, nil)
+			}
+		}
+	}
+}
+
+func (mi *mqttInlet) handle(_ paho.Client, msg paho.Message) {
+	reader, err := engine.NewReader(bytes.NewReader(msg.Payload()), mi.conf)
+	if err != nil {
+		mi.ctx.LogError("inlet.mqtt", "topic", msg.Topic(), "error", err.Error())
+		return
+	}
+	defer reader.Close()
+
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			mi.ctx.LogWarn("inlet.mqtt", "topic", msg.Topic(), "decode error", err.Error())
+			return
+		}
+	}
+	if len(ret) == 0 {
+		return
+	}
+
+	var captured map[string]string
+	for _, tf := range mi.filters {
+		if m, ok := tf.match(msg.Topic()); ok {
+			captured = m
+			break
+		}
+	}
+	for _, r := range ret {
+		if mi.topicTag != "" {
+			r.Tags().Set(mi.topicTag, engine.NewValue(msg.Topic()))
+		}
+		for k, v := range captured {
+			r.Tags().Set(k, engine.NewValue(v))
+		}
+	}
+
+	select {
+	case <-mi.closeCh:
+	case mi.pushCh <- ret:
+	}
+}
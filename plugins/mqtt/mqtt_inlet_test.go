@@ -0,0 +1,114 @@
+package mqtt_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/mqtt"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+func TestMqttInlet(t *testing.T) {
+	dsl := `
+	[[inlets.mqtt]]
+		server = "tcp://127.0.0.1:1883"
+		topics = ["sensors/+site/+device", "alerts/#path"]
+		qos = 1
+		format = "json"
+	[[flows.select]]
+		includes = ["#topic", "#site", "#device", "#path", "*"]
+	[[outlets.file]]
+		format = "json"
+	`
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+
+	// wait until the inlet subscribes the topics
+	for i := 0; i < 50 && len(server.Topics.Subscribers("alerts/x").Subscriptions) == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	require.NoError(t, server.Publish("sensors/seoul/d1", []byte(`{"temp":21.5}`), false, 1))
+	require.NoError(t, server.Publish("alerts/seoul/d1/fire", []byte(`{"level":3}`), false, 1))
+	require.NoError(t, server.Publish("others/seoul", []byte(`{"level":0}`), false, 1))
+
+	expect := `{"device":"d1","path":null,"site":"seoul","temp":21.5,"topic":"sensors/seoul/d1"}` + "\n" +
+		`{"device":null,"level":3,"path":"seoul/d1/fire","site":null,"topic":"alerts/seoul/d1/fire"}` + "\n"
+	for i := 0; i < 50 && len(out.String()) < len(expect); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	pipeline.Stop()
+	require.Equal(t, expect, out.String())
+}
+
+func TestMqttInletPersistentSession(t *testing.T) {
+	clientId := fmt.Sprintf("persist-%d", time.Now().UnixNano())
+	dsl := fmt.Sprintf(`
+	[[inlets.mqtt]]
+		server = "tcp://127.0.0.1:1883"
+		topics = ["persist/+id"]
+		qos = 1
+		clean_session = false
+		client_id = "%s"
+	[[flows.select]]
+		includes = ["#id", "*"]
+	[[outlets.file]]
+		format = "json"
+	`, clientId)
+	// client_id is required for the persistent session
+	pipeline, err := engine.New(engine.WithConfig(strings.Replace(dsl, clientId, "", 1)))
+	require.NoError(t, err)
+	require.Error(t, pipeline.Build())
+
+	// subscribe and disconnect
+	pipeline, err = engine.New(engine.WithConfig(dsl), engine.WithWriter(&syncBuffer{}))
+	require.NoError(t, err)
+	go pipeline.Run()
+	for i := 0; i < 50; i++ {
+		if cl, ok := server.Clients.Get(clientId); ok && cl.State.Subscriptions.Len() > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	pipeline.Stop()
+
+	// the message published while the inlet is offline should be delivered on reconnect
+	require.NoError(t, server.Publish("persist/p1", []byte(`{"value":1}`), false, 1))
+
+	out := &syncBuffer{}
+	pipeline, err = engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+
+	expect := `{"id":"p1","value":1}` + "\n"
+	for i := 0; i < 50 && len(out.String()) < len(expect); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	pipeline.Stop()
+	require.Equal(t, expect, out.String())
+}
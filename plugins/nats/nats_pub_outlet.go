@@ -0,0 +1,103 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func init() {
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name:    "nats",
+		Factory: NatsOutlet,
+	})
+}
+
+func NatsOutlet(ctx *engine.Context) engine.Outlet {
+	return &natsOutlet{ctx: ctx}
+}
+
+type natsOutlet struct {
+	ctx     *engine.Context
+	conf    engine.Config
+	subject string
+	timeout time.Duration
+
+	conn *nats.Conn
+	js   jetstream.JetStream
+}
+
+var _ = engine.Outlet((*natsOutlet)(nil))
+
+func (no *natsOutlet) Open() error {
+	conf := no.ctx.Config()
+	no.subject = conf.GetString("subject", "")
+	no.timeout = conf.GetDuration("timeout", 3*time.Second)
+	if no.subject == "" {
+		return fmt.Errorf("outlet.nats subject is required")
+	}
+	format := conf.GetString("format", "json")
+	if engine.GetEncoder(format) == nil {
+		return fmt.Errorf("outlet.nats format %q not found", format)
+	}
+	no.conf = maps.Clone(conf).Set("format", format)
+
+	conn, err := natsConnect(no.ctx, "outlet.nats")
+	if err != nil {
+		return err
+	}
+	no.conn = conn
+	if conf.GetBool("jetstream", false) {
+		if no.js, err = jetstream.New(conn); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	no.ctx.LogDebug("outlet.nats", "server", conn.ConnectedUrl(), "subject", no.subject, "jetstream", no.js != nil)
+	return nil
+}
+
+func (no *natsOutlet) Close() error {
+	if no.conn != nil {
+		// flush the pending messages
+		no.conn.FlushTimeout(no.timeout)
+		no.conn.Close()
+	}
+	return nil
+}
+
+func (no *natsOutlet) Handle(recs []engine.Record) error {
+	data := &bytes.Buffer{}
+	w, err := engine.NewWriter(data, no.conf)
+	if err != nil {
+		return err
+	}
+	if err := w.Write(recs); err != nil {
+		return err
+	}
+	w.Close()
+
+	msg := nats.NewMsg(no.subject)
+	msg.Data = data.Bytes()
+	msg.Header.Set("Content-Type", w.ContentType)
+	if w.ContentEncoding != "" {
+		msg.Header.Set("Content-Encoding", w.ContentEncoding)
+	}
+
+	if no.js != nil {
+		// wait for the acknowledgement of the stream
+		ctx, cancel := context.WithTimeout(no.ctx, no.timeout)
+		defer cancel()
+		if _, err := no.js.PublishMsg(ctx, msg); err != nil {
+			return fmt.Errorf("outlet.nats publish %q, %w", no.subject, err)
+		}
+		return nil
+	}
+	return no.conn.PublishMsg(msg)
+}
@@ -0,0 +1,290 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "nats",
+		Factory: NatsInlet,
+	})
+}
+
+func NatsInlet(ctx *engine.Context) engine.Inlet {
+	return &natsInlet{
+		ctx:     ctx,
+		pushCh:  make(chan natsData),
+		closeCh: make(chan struct{}),
+	}
+}
+
+type natsInlet struct {
+	ctx        *engine.Context
+	conf       engine.Config
+	subjectTag string
+
+	conn     *nats.Conn
+	subs     []*nats.Subscription
+	consumer jetstream.ConsumeContext
+
+	pushCh    chan natsData
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// natsData is the records of a message,
+// msg is not nil if the message of the JetStream should be acknowledged
+// after the records are handled by the outlets.
+type natsData struct {
+	records []engine.Record
+	msg     jetstream.Msg
+}
+
+var _ = engine.Inlet((*natsInlet)(nil))
+
+func (ni *natsInlet) Open() error {
+	conf := ni.ctx.Config()
+	subjects := conf.GetStringSlice("subjects", nil)
+	queue := conf.GetString("queue", "")
+	stream := conf.GetString("stream", "")
+	ni.subjectTag = conf.GetString("subject_tag", "subject")
+
+	format := conf.GetString("format", "json")
+	if engine.GetDecoder(format) == nil {
+		return fmt.Errorf("inlet.nats format %q not found", format)
+	}
+	ni.conf = maps.Clone(conf).Set("format", format)
+
+	if len(subjects) == 0 && stream == "" {
+		return fmt.Errorf("inlet.nats subjects or stream is required")
+	}
+
+	conn, err := natsConnect(ni.ctx, "inlet.nats")
+	if err != nil {
+		return err
+	}
+	ni.conn = conn
+
+	if stream != "" {
+		return ni.openJetStream(stream, subjects)
+	}
+	for _, subj := range subjects {
+		var sub *nats.Subscription
+		if queue != "" {
+			sub, err = conn.QueueSubscribe(subj, queue, ni.handle)
+		} else {
+			sub, err = conn.Subscribe(subj, ni.handle)
+		}
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("inlet.nats subscribe %q, %w", subj, err)
+		}
+		ni.subs = append(ni.subs, sub)
+	}
+	ni.ctx.LogDebug("inlet.nats", "server", conn.ConnectedUrl(), "subjects", subjects, "queue", queue)
+	return nil
+}
+
+// openJetStream consumes the messages of the stream with the durable consumer,
+// the messages are acknowledged explicitly after their records are handled by the outlets.
+func (ni *natsInlet) openJetStream(stream string, subjects []string) error {
+	conf := ni.ctx.Config()
+	timeout := conf.GetDuration("timeout", 3*time.Second)
+	cfg := jetstream.ConsumerConfig{
+		Durable:        conf.GetString("durable", ""),
+		FilterSubjects: subjects,
+		AckPolicy:      jetstream.AckExplicitPolicy,
+		AckWait:        conf.GetDuration("ack_wait", 30*time.Second),
+		MaxAckPending:  conf.GetInt("max_ack_pending", 1000),
+	}
+	switch deliver := conf.GetString("deliver", "all"); deliver {
+	case "all":
+		cfg.DeliverPolicy = jetstream.DeliverAllPolicy
+	case "new":
+		cfg.DeliverPolicy = jetstream.DeliverNewPolicy
+	case "last":
+		cfg.DeliverPolicy = jetstream.DeliverLastPolicy
+	default:
+		ni.conn.Close()
+		return fmt.Errorf("inlet.nats unknown deliver %q", deliver)
+	}
+
+	js, err := jetstream.New(ni.conn)
+	if err != nil {
+		ni.conn.Close()
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ni.ctx, timeout)
+	defer cancel()
+	cons, err := js.CreateOrUpdateConsumer(ctx, stream, cfg)
+	if err != nil {
+		ni.conn.Close()
+		return fmt.Errorf("inlet.nats consumer of stream %q, %w", stream, err)
+	}
+	cc, err := cons.Consume(func(msg jetstream.Msg) {
+		ni.push(msg.Subject(), msg.Headers(), msg.Data(), msg)
+	})
+	if err != nil {
+		ni.conn.Close()
+		return err
+	}
+	ni.consumer = cc
+	ni.ctx.LogDebug("inlet.nats", "server", ni.conn.ConnectedUrl(), "stream", stream, "durable", cfg.Durable, "subjects", subjects)
+	return nil
+}
+
+func (ni *natsInlet) Close() error {
+	ni.closeOnce.Do(func() {
+		close(ni.closeCh)
+		if ni.consumer != nil {
+			ni.consumer.Stop()
+		}
+		for _, sub := range ni.subs {
+			sub.Unsubscribe()
+		}
+		if ni.conn != nil {
+			ni.conn.Close()
+		}
+	})
+	return nil
+}
+
+func (ni *natsInlet) Process(next engine.InletNextFunc) {
+	for {
+		select {
+		case <-ni.closeCh:
+			return
+		case d := <-ni.pushCh:
+			select {
+			case <-ni.closeCh:
+				// closed while waiting, it will be redelivered
+				if d.msg != nil {
+					d.msg.Nak()
+				}
+				return
+			default:
+				if d.msg == nil {
+					next(d.records, nil)
+					continue
+				}
+				msg := d.msg
+				next(engine.WithAck(d.records, func(err error) {
+					if err != nil {
+						msg.Nak()
+					} else {
+						msg.Ack()
+					}
+				}), nil)
+			}
+		}
+	}
+}
+
+func (ni *natsInlet) handle(msg *nats.Msg) {
+	ni.push(msg.Subject, msg.Header, msg.Data, nil)
+}
+
+// push decodes the message and sends the records to the pipeline.
+// The message of the JetStream those can not be decoded is terminated not to be redelivered.
+func (ni *natsInlet) push(subject string, header nats.Header, data []byte, msg jetstream.Msg) {
+	conf := ni.conf
+	var src io.Reader = bytes.NewReader(data)
+	// the message published by outlets.nats has the content encoding in the header
+	if enc := header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		reg := engine.GetDecompressorByContentEncoding(enc)
+		if reg == nil {
+			ni.ctx.LogWarn("inlet.nats", "subject", subject, "unsupported content-encoding", enc)
+			if msg != nil {
+				msg.Term()
+			}
+			return
+		}
+		dec := reg.Factory(src)
+		defer dec.Close()
+		src = dec
+		conf = maps.Clone(conf).Unset("compress")
+	}
+	reader, err := engine.NewReader(src, conf)
+	if err != nil {
+		ni.ctx.LogError("inlet.nats", "subject", subject, "error", err.Error())
+		if msg != nil {
+			msg.Term()
+		}
+		return
+	}
+	defer reader.Close()
+
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			// the message can not be decoded, redelivery does not help
+			ni.ctx.LogWarn("inlet.nats", "subject", subject, "decode error", err.Error())
+			if msg != nil {
+				msg.Term()
+			}
+			return
+		}
+	}
+	if len(ret) == 0 {
+		if msg != nil {
+			msg.Ack()
+		}
+		return
+	}
+	if ni.subjectTag != "" {
+		for _, r := range ret {
+			r.Tags().Set(ni.subjectTag, engine.NewValue(subject))
+		}
+	}
+
+	select {
+	case <-ni.closeCh:
+		if msg != nil {
+			msg.Nak()
+		}
+	case ni.pushCh <- natsData{records: ret, msg: msg}:
+	}
+}
+
+// natsConnect connects to the nats server with the options of the config
+func natsConnect(ctx *engine.Context, name string) (*nats.Conn, error) {
+	conf := ctx.Config()
+	server := conf.GetString("server", nats.DefaultURL)
+	opts := []nats.Option{
+		nats.Name(fmt.Sprintf("tine-%s", ctx.PipelineName())),
+		nats.Timeout(conf.GetDuration("timeout", 3*time.Second)),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(c *nats.Conn, err error) {
+			if err != nil {
+				ctx.LogWarn(name, "disconnected", err.Error())
+			}
+		}),
+	}
+	if user := conf.GetString("username", ""); user != "" {
+		opts = append(opts, nats.UserInfo(user, conf.GetString("password", "")))
+	}
+	if token := conf.GetString("token", ""); token != "" {
+		opts = append(opts, nats.Token(token))
+	}
+	// server can be a comma separated list of the cluster
+	conn, err := nats.Connect(server, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s connect %q, %w", name, server, err)
+	}
+	return conn, nil
+}
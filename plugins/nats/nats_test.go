@@ -0,0 +1,178 @@
+package nats_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/nats"
+	gonatsd "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+func runServer(t *testing.T) *gonatsd.Server {
+	t.Helper()
+	svr, err := gonatsd.NewServer(&gonatsd.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+	go svr.Start()
+	require.True(t, svr.ReadyForConnections(5*time.Second))
+	t.Cleanup(svr.Shutdown)
+	return svr
+}
+
+// waitFor waits until the output has the expected length
+func waitFor(out *syncBuffer, expect string) {
+	for i := 0; i < 100 && len(out.String()) < len(expect); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestNats(t *testing.T) {
+	svr := runServer(t)
+
+	inDsl := fmt.Sprintf(`
+		[[inlets.nats]]
+			server = "%s"
+			subjects = ["metrics.>"]
+			queue = "workers"
+			format = "csv"
+			fields = ["name", "value"]
+			types = ["string", "int"]
+		[[flows.select]]
+			includes = ["#subject", "*"]
+		[[outlets.file]]
+			format = "json"
+		`, svr.ClientURL())
+	out := &syncBuffer{}
+	inPipeline, err := engine.New(engine.WithConfig(inDsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go inPipeline.Run()
+	for i := 0; i < 100 && svr.NumSubscriptions() == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	outDsl := fmt.Sprintf(`
+		[[inlets.file]]
+			data = ["a,1", "b,2"]
+			format = "csv"
+		[[outlets.nats]]
+			server = "%s"
+			subject = "metrics.cpu"
+			format = "csv"
+			compress = "gzip"
+		`, svr.ClientURL())
+	outPipeline, err := engine.New(engine.WithConfig(outDsl))
+	require.NoError(t, err)
+	require.NoError(t, outPipeline.Run())
+
+	expect := `{"name":"a","subject":"metrics.cpu","value":1}` + "\n" +
+		`{"name":"b","subject":"metrics.cpu","value":2}` + "\n"
+	waitFor(out, expect)
+	inPipeline.Stop()
+	require.Equal(t, expect, out.String())
+}
+
+func TestNatsJetStream(t *testing.T) {
+	svr := runServer(t)
+
+	conn, err := nats.Connect(svr.ClientURL())
+	require.NoError(t, err)
+	defer conn.Close()
+	js, err := jetstream.New(conn)
+	require.NoError(t, err)
+	ctx := context.Background()
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "EVENTS", Subjects: []string{"events.>"}})
+	require.NoError(t, err)
+
+	outDsl := fmt.Sprintf(`
+		[[inlets.file]]
+			data = [
+				'{"name":"a","value":1}',
+				'{"name":"b","value":2}',
+			]
+			format = "json"
+		[[outlets.nats]]
+			server = "%s"
+			subject = "events.app"
+			jetstream = true
+		`, svr.ClientURL())
+	outPipeline, err := engine.New(engine.WithConfig(outDsl))
+	require.NoError(t, err)
+	require.NoError(t, outPipeline.Run())
+
+	// the message that can not be decoded should be terminated
+	_, err = js.Publish(ctx, "events.bad", []byte("not json"))
+	require.NoError(t, err)
+
+	info, err := stream.Info(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), info.State.Msgs)
+
+	inDsl := fmt.Sprintf(`
+		[[inlets.nats]]
+			server = "%s"
+			stream = "EVENTS"
+			durable = "tine"
+			subjects = ["events.>"]
+		[[flows.select]]
+			includes = ["#subject", "*"]
+		[[outlets.file]]
+			format = "json"
+		`, svr.ClientURL())
+	out := &syncBuffer{}
+	inPipeline, err := engine.New(engine.WithConfig(inDsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go inPipeline.Run()
+
+	expect := `{"name":"a","subject":"events.app","value":1}` + "\n" +
+		`{"name":"b","subject":"events.app","value":2}` + "\n"
+	waitFor(out, expect)
+	require.Equal(t, expect, out.String())
+
+	// the messages should be acknowledged after the outlet handled the records
+	cons, err := stream.Consumer(ctx, "tine")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		if ci, err := cons.Info(ctx); err == nil && ci.AckFloor.Consumer == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	ci, err := cons.Info(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, ci.NumAckPending)
+	require.Equal(t, uint64(2), ci.AckFloor.Consumer)
+	require.Equal(t, 0, ci.NumRedelivered)
+	inPipeline.Stop()
+}
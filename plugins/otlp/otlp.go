@@ -0,0 +1,222 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// reserved tags those are not exported as attributes
+var reservedTags = []string{engine.TAG_INLET, engine.TAG_TIMESTAMP}
+
+// anyValue converts the OTLP AnyValue to the value,
+// the array and the key-value list are converted to the JSON string.
+func anyValue(av *commonpb.AnyValue) *engine.Value {
+	switch v := av.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return engine.NewValue(v.StringValue)
+	case *commonpb.AnyValue_BoolValue:
+		return engine.NewValue(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return engine.NewValue(v.IntValue)
+	case *commonpb.AnyValue_DoubleValue:
+		return engine.NewValue(v.DoubleValue)
+	case *commonpb.AnyValue_BytesValue:
+		return engine.NewValue(v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue, *commonpb.AnyValue_KvlistValue:
+		b, err := json.Marshal(anyRaw(av))
+		if err != nil {
+			return engine.NewNullValue(engine.STRING)
+		}
+		return engine.NewValue(string(b))
+	default:
+		return nil
+	}
+}
+
+func anyRaw(av *commonpb.AnyValue) any {
+	switch v := av.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return v.BytesValue
+	case *commonpb.AnyValue_ArrayValue:
+		ret := make([]any, 0, len(v.ArrayValue.GetValues()))
+		for _, e := range v.ArrayValue.GetValues() {
+			ret = append(ret, anyRaw(e))
+		}
+		return ret
+	case *commonpb.AnyValue_KvlistValue:
+		ret := map[string]any{}
+		for _, kv := range v.KvlistValue.GetValues() {
+			ret[kv.Key] = anyRaw(kv.Value)
+		}
+		return ret
+	default:
+		return nil
+	}
+}
+
+// setAttributes sets the attributes to the tags
+func setAttributes(tags engine.Tags, attrs []*commonpb.KeyValue) {
+	for _, kv := range attrs {
+		if v := anyValue(kv.GetValue()); v != nil {
+			tags.Set(kv.Key, v)
+		}
+	}
+}
+
+// toAnyValue converts the value to the OTLP AnyValue,
+// the time is converted to the RFC3339 string.
+func toAnyValue(v *engine.Value) *commonpb.AnyValue {
+	if v == nil || v.IsNull() {
+		return nil
+	}
+	switch raw := v.Raw().(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: raw}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: raw}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: raw}}
+	case uint64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(raw)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: raw}}
+	case []byte:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: raw}}
+	case time.Time:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: raw.Format(time.RFC3339Nano)}}
+	default:
+		return nil
+	}
+}
+
+// tagAttributes returns the attributes of the tags in the order of the names,
+// except the reserved tags.
+func tagAttributes(tags engine.Tags) []*commonpb.KeyValue {
+	names := tags.Names()
+	slices.Sort(names)
+	ret := make([]*commonpb.KeyValue, 0, len(names))
+	for _, name := range names {
+		if slices.Contains(reservedTags, name) {
+			continue
+		}
+		if av := toAnyValue(tags.Get(name)); av != nil {
+			ret = append(ret, &commonpb.KeyValue{Key: name, Value: av})
+		}
+	}
+	return ret
+}
+
+// configAttributes returns the attributes of the config in the order of the keys
+func configAttributes(conf engine.Config) []*commonpb.KeyValue {
+	keys := make([]string, 0, len(conf))
+	for k := range conf {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	ret := make([]*commonpb.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		if av := toAnyValue(conf.GetValue(k)); av != nil {
+			ret = append(ret, &commonpb.KeyValue{Key: k, Value: av})
+		}
+	}
+	return ret
+}
+
+// recordTime returns the time of the "timestamp" field or the _ts tag,
+// if there are neither it returns the current time.
+func recordTime(r engine.Record) time.Time {
+	if f := r.Field("timestamp"); f != nil && !f.IsNull() {
+		if t, ok := f.Value.Time(); ok {
+			return t
+		}
+	}
+	if v := r.Tags().Get(engine.TAG_TIMESTAMP); v != nil && !v.IsNull() {
+		if t, ok := v.Time(); ok {
+			return t
+		}
+	}
+	return engine.Now()
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// OTLP/JSON encodes the trace id and the span id as the hex string instead of the base64
+// that is the protojson encoding of the bytes.
+var idKeys = []string{"traceId", "spanId"}
+
+// convertIds converts the trace id and the span id in the OTLP/JSON,
+// from the hex to the base64 if toBase64 is true, otherwise from the base64 to the hex.
+func convertIds(data []byte, toBase64 bool) ([]byte, error) {
+	if !strings.Contains(string(data), `"traceId"`) && !strings.Contains(string(data), `"spanId"`) {
+		return data, nil
+	}
+	var obj any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	if err := walkIds(obj, toBase64); err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+func walkIds(obj any, toBase64 bool) error {
+	switch o := obj.(type) {
+	case map[string]any:
+		for k, v := range o {
+			if s, ok := v.(string); ok && slices.Contains(idKeys, k) {
+				if toBase64 {
+					b, err := hex.DecodeString(s)
+					if err != nil {
+						return fmt.Errorf("invalid %s %q", k, s)
+					}
+					o[k] = base64.StdEncoding.EncodeToString(b)
+				} else {
+					b, err := base64.StdEncoding.DecodeString(s)
+					if err != nil {
+						return fmt.Errorf("invalid %s %q", k, s)
+					}
+					o[k] = hex.EncodeToString(b)
+				}
+				continue
+			}
+			if err := walkIds(v, toBase64); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, v := range o {
+			if err := walkIds(v, toBase64); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,492 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "otlp",
+		Factory: OtlpInlet,
+	})
+}
+
+func OtlpInlet(ctx *engine.Context) engine.Inlet {
+	return &otlpInlet{
+		ctx:     ctx,
+		pushCh:  make(chan []engine.Record),
+		closeCh: make(chan struct{}),
+	}
+}
+
+type otlpInlet struct {
+	ctx *engine.Context
+
+	maxBodySize int64
+	pushTimeout time.Duration
+
+	grpcLsnr  net.Listener
+	grpcSvr   *grpc.Server
+	httpLsnr  net.Listener
+	httpSvr   *http.Server
+	pushCh    chan []engine.Record
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// errUnavailable is returned when the pipeline does not take the records in time,
+// the client should retry later.
+var errUnavailable = errors.New("inlet.otlp pipeline unavailable")
+
+var _ = engine.Inlet((*otlpInlet)(nil))
+
+func (oi *otlpInlet) Open() error {
+	conf := oi.ctx.Config()
+	grpcAddress := conf.GetString("grpc_address", "127.0.0.1:4317")
+	httpAddress := conf.GetString("http_address", "127.0.0.1:4318")
+	oi.maxBodySize = conf.GetInt64("max_body_size", 10*1024*1024)
+	oi.pushTimeout = conf.GetDuration("push_timeout", 10*time.Second)
+	if grpcAddress == "" && httpAddress == "" {
+		return errors.New("inlet.otlp requires grpc_address or http_address")
+	}
+
+	if grpcAddress != "" {
+		lsnr, err := net.Listen("tcp", grpcAddress)
+		if err != nil {
+			return err
+		}
+		oi.grpcLsnr = lsnr
+		oi.grpcSvr = grpc.NewServer(grpc.MaxRecvMsgSize(int(oi.maxBodySize)))
+		colmetricspb.RegisterMetricsServiceServer(oi.grpcSvr, &metricsServer{oi: oi})
+		collogspb.RegisterLogsServiceServer(oi.grpcSvr, &logsServer{oi: oi})
+		oi.ctx.LogDebug("inlet.otlp", "grpc_address", lsnr.Addr().String())
+		go func() {
+			if err := oi.grpcSvr.Serve(lsnr); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				oi.ctx.LogError("inlet.otlp", "error", err.Error())
+			}
+		}()
+	}
+
+	if httpAddress != "" {
+		lsnr, err := net.Listen("tcp", httpAddress)
+		if err != nil {
+			if oi.grpcSvr != nil {
+				oi.grpcSvr.Stop()
+			}
+			return err
+		}
+		oi.httpLsnr = lsnr
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/metrics", oi.handleMetrics)
+		mux.HandleFunc("/v1/logs", oi.handleLogs)
+		oi.httpSvr = &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: conf.GetDuration("timeout", 10*time.Second),
+		}
+		oi.ctx.LogDebug("inlet.otlp", "http_address", lsnr.Addr().String())
+		go func() {
+			if err := oi.httpSvr.Serve(lsnr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				oi.ctx.LogError("inlet.otlp", "error", err.Error())
+			}
+		}()
+	}
+	return nil
+}
+
+func (oi *otlpInlet) Close() error {
+	oi.closeOnce.Do(func() {
+		// wait for the handlers those are pushing records,
+		// the handlers still waiting after the timeout return unavailable by closeCh
+		if oi.httpSvr != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			oi.httpSvr.Shutdown(ctx)
+			cancel()
+		}
+		close(oi.closeCh)
+		if oi.grpcSvr != nil {
+			oi.grpcSvr.GracefulStop()
+		}
+	})
+	return nil
+}
+
+func (oi *otlpInlet) Process(next engine.InletNextFunc) {
+	for {
+		select {
+		case <-oi.closeCh:
+			return
+		case recs := <-oi.pushCh:
+			next(recs, nil)
+		}
+	}
+}
+
+// push sends the records to the pipeline, it returns errUnavailable
+// if the pipeline does not take the records within push_timeout or the inlet is closed.
+func (oi *otlpInlet) push(ctx context.Context, recs []engine.Record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	timer := time.NewTimer(oi.pushTimeout)
+	defer timer.Stop()
+	select {
+	case oi.pushCh <- recs:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-oi.closeCh:
+		return errUnavailable
+	case <-timer.C:
+		return errUnavailable
+	}
+}
+
+// grpcError converts the error of push to the gRPC status
+func grpcError(err error) error {
+	if errors.Is(err, errUnavailable) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.FromContextError(err).Err()
+}
+
+type metricsServer struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	oi *otlpInlet
+}
+
+func (ms *metricsServer) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	if err := ms.oi.push(ctx, metricsRecords(req)); err != nil {
+		return nil, grpcError(err)
+	}
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+type logsServer struct {
+	collogspb.UnimplementedLogsServiceServer
+	oi *otlpInlet
+}
+
+func (ls *logsServer) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	if err := ls.oi.push(ctx, logsRecords(req)); err != nil {
+		return nil, grpcError(err)
+	}
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (oi *otlpInlet) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	req := &colmetricspb.ExportMetricsServiceRequest{}
+	contentType, ok := oi.readRequest(w, r, req)
+	if !ok {
+		return
+	}
+	if !oi.pushRequest(w, r, metricsRecords(req)) {
+		return
+	}
+	oi.writeResponse(w, contentType, &colmetricspb.ExportMetricsServiceResponse{})
+}
+
+func (oi *otlpInlet) handleLogs(w http.ResponseWriter, r *http.Request) {
+	req := &collogspb.ExportLogsServiceRequest{}
+	contentType, ok := oi.readRequest(w, r, req)
+	if !ok {
+		return
+	}
+	if !oi.pushRequest(w, r, logsRecords(req)) {
+		return
+	}
+	oi.writeResponse(w, contentType, &collogspb.ExportLogsServiceResponse{})
+}
+
+// pushRequest pushes the records of the OTLP/HTTP request,
+// it writes 503 and returns false if the pipeline is not available.
+func (oi *otlpInlet) pushRequest(w http.ResponseWriter, r *http.Request, recs []engine.Record) bool {
+	if err := oi.push(r.Context(), recs); err != nil {
+		if errors.Is(err, errUnavailable) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+		// otherwise the client has gone away
+		return false
+	}
+	return true
+}
+
+// readRequest reads the OTLP/HTTP request in protobuf or JSON,
+// it writes the error response and returns false if it fails.
+func (oi *otlpInlet) readRequest(w http.ResponseWriter, r *http.Request, msg proto.Message) (string, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return "", false
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		http.Error(w, fmt.Sprintf("unsupported content-type %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return "", false
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, oi.maxBodySize)
+	compressed := false
+	switch enc := r.Header.Get("Content-Encoding"); enc {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), readErrorCode(err))
+			return "", false
+		}
+		defer zr.Close()
+		// the decompressed size is also limited by max_body_size
+		body = io.LimitReader(zr, oi.maxBodySize+1)
+		compressed = true
+	default:
+		http.Error(w, fmt.Sprintf("unsupported content-encoding %q", enc), http.StatusUnsupportedMediaType)
+		return "", false
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), readErrorCode(err))
+		return "", false
+	}
+	if compressed && int64(len(data)) > oi.maxBodySize {
+		http.Error(w, fmt.Sprintf("decompressed body is larger than %d bytes", oi.maxBodySize), http.StatusRequestEntityTooLarge)
+		return "", false
+	}
+	if contentType == contentTypeJSON {
+		if data, err = convertIds(data, true); err == nil {
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, msg)
+		}
+	} else {
+		err = proto.Unmarshal(data, msg)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return contentType, true
+}
+
+// readErrorCode returns the status code of the error reading the request body
+func readErrorCode(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func (oi *otlpInlet) writeResponse(w http.ResponseWriter, contentType string, msg proto.Message) {
+	var data []byte
+	var err error
+	if contentType == contentTypeJSON {
+		data, err = protojson.Marshal(msg)
+	} else {
+		data, err = proto.Marshal(msg)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, bytes.NewReader(data))
+}
+
+// metricsRecords returns a record for each data point,
+// the resource and data point attributes are set as tags.
+func metricsRecords(req *colmetricspb.ExportMetricsServiceRequest) []engine.Record {
+	ret := []engine.Record{}
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			tags := engine.Tags{}
+			setAttributes(tags, rm.GetResource().GetAttributes())
+			if name := sm.GetScope().GetName(); name != "" {
+				tags.Set("otel.scope.name", engine.NewValue(name))
+			}
+			for _, m := range sm.GetMetrics() {
+				ret = append(ret, metricRecords(m, tags)...)
+			}
+		}
+	}
+	return ret
+}
+
+func metricRecords(m *metricspb.Metric, tags engine.Tags) []engine.Record {
+	newRecord := func(typ string, ts uint64, attrs []*commonpb.KeyValue) engine.Record {
+		rec := engine.NewRecord(
+			engine.NewField("name", m.GetName()),
+			engine.NewField("type", typ),
+		)
+		if m.GetUnit() != "" {
+			rec = rec.Append(engine.NewField("unit", m.GetUnit()))
+		}
+		if ts != 0 {
+			rec = rec.Append(engine.NewField("timestamp", time.Unix(0, int64(ts))))
+		}
+		// the data point attributes override the resource attributes
+		rec.Tags().Merge(tags)
+		setAttributes(rec.Tags(), attrs)
+		return rec
+	}
+	appendNumber := func(rec engine.Record, dp *metricspb.NumberDataPoint) engine.Record {
+		switch v := dp.GetValue().(type) {
+		case *metricspb.NumberDataPoint_AsInt:
+			return rec.Append(engine.NewField("value", v.AsInt))
+		case *metricspb.NumberDataPoint_AsDouble:
+			return rec.Append(engine.NewField("value", v.AsDouble))
+		}
+		return rec
+	}
+	appendDist := func(rec engine.Record, count uint64, sum, min, max *float64) engine.Record {
+		rec = rec.Append(engine.NewField("count", count))
+		for _, f := range []struct {
+			name string
+			v    *float64
+		}{{"sum", sum}, {"min", min}, {"max", max}} {
+			if f.v != nil {
+				rec = rec.Append(engine.NewField(f.name, *f.v))
+			}
+		}
+		return rec
+	}
+	// appendBuckets appends the cumulative count of each bucket as "le_<upper bound>" field,
+	// the last bucket of the infinite upper bound is "le_inf".
+	appendBuckets := func(rec engine.Record, bounds []float64, counts []uint64) engine.Record {
+		cumulative := uint64(0)
+		for i, c := range counts {
+			cumulative += c
+			name := "le_inf"
+			if i < len(bounds) {
+				name = "le_" + strconv.FormatFloat(bounds[i], 'g', -1, 64)
+			}
+			rec = rec.Append(engine.NewField(name, cumulative))
+		}
+		return rec
+	}
+
+	ret := []engine.Record{}
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		for _, dp := range data.Gauge.GetDataPoints() {
+			ret = append(ret, appendNumber(newRecord("gauge", dp.GetTimeUnixNano(), dp.GetAttributes()), dp))
+		}
+	case *metricspb.Metric_Sum:
+		for _, dp := range data.Sum.GetDataPoints() {
+			ret = append(ret, appendNumber(newRecord("sum", dp.GetTimeUnixNano(), dp.GetAttributes()), dp))
+		}
+	case *metricspb.Metric_Histogram:
+		for _, dp := range data.Histogram.GetDataPoints() {
+			rec := newRecord("histogram", dp.GetTimeUnixNano(), dp.GetAttributes())
+			rec = appendDist(rec, dp.GetCount(), dp.Sum, dp.Min, dp.Max)
+			ret = append(ret, appendBuckets(rec, dp.GetExplicitBounds(), dp.GetBucketCounts()))
+		}
+	case *metricspb.Metric_ExponentialHistogram:
+		for _, dp := range data.ExponentialHistogram.GetDataPoints() {
+			rec := newRecord("exponential_histogram", dp.GetTimeUnixNano(), dp.GetAttributes())
+			rec = appendDist(rec, dp.GetCount(), dp.Sum, dp.Min, dp.Max)
+			bounds, counts := exponentialBuckets(dp)
+			ret = append(ret, appendBuckets(rec, bounds, counts))
+		}
+	case *metricspb.Metric_Summary:
+		for _, dp := range data.Summary.GetDataPoints() {
+			rec := newRecord("summary", dp.GetTimeUnixNano(), dp.GetAttributes())
+			sum := dp.GetSum()
+			rec = appendDist(rec, dp.GetCount(), &sum, nil, nil)
+			for _, q := range dp.GetQuantileValues() {
+				name := "p" + strconv.FormatFloat(q.GetQuantile()*100, 'f', -1, 64)
+				rec = rec.Append(engine.NewField(name, q.GetValue()))
+			}
+			ret = append(ret, rec)
+		}
+	}
+	return ret
+}
+
+// exponentialBuckets converts the buckets of the exponential histogram to the explicit buckets,
+// the negative buckets, the zero bucket and the positive buckets in the ascending order of the values.
+// The bucket of index i covers (base^i, base^(i+1)] where base = 2^(2^-scale).
+func exponentialBuckets(dp *metricspb.ExponentialHistogramDataPoint) ([]float64, []uint64) {
+	base := math.Exp2(math.Exp2(-float64(dp.GetScale())))
+	bounds := []float64{}
+	counts := []uint64{}
+	neg := dp.GetNegative()
+	for i := len(neg.GetBucketCounts()) - 1; i >= 0; i-- {
+		bounds = append(bounds, -math.Pow(base, float64(int(neg.GetOffset())+i)))
+		counts = append(counts, neg.GetBucketCounts()[i])
+	}
+	bounds = append(bounds, dp.GetZeroThreshold())
+	counts = append(counts, dp.GetZeroCount())
+	pos := dp.GetPositive()
+	for i, c := range pos.GetBucketCounts() {
+		bounds = append(bounds, math.Pow(base, float64(int(pos.GetOffset())+i+1)))
+		counts = append(counts, c)
+	}
+	// the last bucket has no upper bound
+	return bounds[:len(bounds)-1], counts
+}
+
+// logsRecords returns a record for each log record,
+// the resource and log attributes are set as tags.
+func logsRecords(req *collogspb.ExportLogsServiceRequest) []engine.Record {
+	ret := []engine.Record{}
+	for _, rl := range req.GetResourceLogs() {
+		for _, sl := range rl.GetScopeLogs() {
+			tags := engine.Tags{}
+			setAttributes(tags, rl.GetResource().GetAttributes())
+			if name := sl.GetScope().GetName(); name != "" {
+				tags.Set("otel.scope.name", engine.NewValue(name))
+			}
+			for _, lr := range sl.GetLogRecords() {
+				ts := lr.GetTimeUnixNano()
+				if ts == 0 {
+					ts = lr.GetObservedTimeUnixNano()
+				}
+				rec := engine.NewRecord()
+				if ts != 0 {
+					rec = rec.Append(engine.NewField("timestamp", time.Unix(0, int64(ts))))
+				}
+				rec = rec.Append(engine.NewField("severity_number", int64(lr.GetSeverityNumber())))
+				if lr.GetSeverityText() != "" {
+					rec = rec.Append(engine.NewField("severity_text", lr.GetSeverityText()))
+				}
+				if body := anyValue(lr.GetBody()); body != nil {
+					rec = rec.Append(engine.NewFieldWithValue("body", body))
+				}
+				if len(lr.GetTraceId()) > 0 {
+					rec = rec.Append(engine.NewField("trace_id", hex.EncodeToString(lr.GetTraceId())))
+				}
+				if len(lr.GetSpanId()) > 0 {
+					rec = rec.Append(engine.NewField("span_id", hex.EncodeToString(lr.GetSpanId())))
+				}
+				rec.Tags().Merge(tags)
+				setAttributes(rec.Tags(), lr.GetAttributes())
+				ret = append(ret, rec)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,180 @@
+package otlp_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/otlp"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// freeAddr returns the address that is not listening
+func freeAddr(t *testing.T) string {
+	lsnr, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lsnr.Close()
+	return lsnr.Addr().String()
+}
+
+// waitListen waits until the address is listening
+func waitListen(t *testing.T, addr string) {
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("%s is not listening", addr)
+}
+
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.lock.Lock()
+	defer sb.lock.Unlock()
+	return sb.buf.String()
+}
+
+func TestOtlpInlet(t *testing.T) {
+	grpcAddr, httpAddr := freeAddr(t), freeAddr(t)
+	dsl := fmt.Sprintf(`
+		[[inlets.otlp]]
+			grpc_address = "%s"
+			http_address = "%s"
+			max_body_size = 2048
+		[[flows.select]]
+			includes = ["#service.name", "#host", "name", "type", "value", "count", "p99", "le_1", "le_2", "le_inf", "body", "trace_id"]
+		[[outlets.file]]
+			format = "json"
+		`, grpcAddr, httpAddr)
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+	waitListen(t, grpcAddr)
+	waitListen(t, httpAddr)
+
+	metricsJSON := `{"resourceMetrics":[{
+		"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"svc"}}]},
+		"scopeMetrics":[{"metrics":[
+			{"name":"cpu","gauge":{"dataPoints":[{"asDouble":1.5,"timeUnixNano":"1700000000000000000",
+				"attributes":[{"key":"host","value":{"stringValue":"a"}}]}]}},
+			{"name":"requests","sum":{"isMonotonic":true,"dataPoints":[{"asInt":"10"}]}},
+			{"name":"latency","summary":{"dataPoints":[{"count":"3","sum":6,
+				"quantileValues":[{"quantile":0.99,"value":3}]}]}},
+			{"name":"size","histogram":{"dataPoints":[{"count":"6","sum":9,
+				"explicitBounds":[1,2],"bucketCounts":["1","2","3"]}]}},
+			{"name":"delay","exponentialHistogram":{"dataPoints":[{"count":"4","sum":5,"scale":0,
+				"zeroCount":"1","positive":{"offset":0,"bucketCounts":["1","2"]}}]}}
+		]}]}]}`
+	logsJSON := `{"resourceLogs":[{
+		"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"svc"}}]},
+		"scopeLogs":[{"logRecords":[
+			{"severityNumber":9,"severityText":"INFO","body":{"stringValue":"hello"},
+				"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174",
+				"attributes":[{"key":"host","value":{"stringValue":"b"}}]}
+		]}]}]}`
+	gzipped := &bytes.Buffer{}
+	gw := gzip.NewWriter(gzipped)
+	gw.Write([]byte(logsJSON))
+	gw.Close()
+	// small compressed body that is larger than max_body_size after decompression
+	bomb := &bytes.Buffer{}
+	gw = gzip.NewWriter(bomb)
+	gw.Write(bytes.Repeat([]byte(" "), 100*1024))
+	gw.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		encoding    string
+		body        []byte
+		expectCode  int
+	}{
+		{"metrics", "POST", "/v1/metrics", "application/json", "", []byte(metricsJSON), 200},
+		{"logs", "POST", "/v1/logs", "application/json; charset=utf-8", "gzip", gzipped.Bytes(), 200},
+		{"method", "GET", "/v1/logs", "", "", nil, 405},
+		{"content-type", "POST", "/v1/logs", "text/plain", "", []byte("hello"), 415},
+		{"encoding", "POST", "/v1/logs", "application/json", "br", []byte{0x01}, 415},
+		{"bad body", "POST", "/v1/logs", "application/json", "", []byte(`{"resourceLogs":`), 400},
+		{"bad protobuf", "POST", "/v1/metrics", "application/x-protobuf", "", []byte{0xff, 0xff}, 400},
+		{"too large", "POST", "/v1/logs", "application/json", "", bytes.Repeat([]byte(" "), 4096), 413},
+		{"too large decompressed", "POST", "/v1/logs", "application/json", "gzip", bomb.Bytes(), 413},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "http://"+httpAddr+tt.path, bytes.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.encoding != "" {
+			req.Header.Set("Content-Encoding", tt.encoding)
+		}
+		rsp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err, tt.name)
+		rsp.Body.Close()
+		require.Equal(t, tt.expectCode, rsp.StatusCode, tt.name)
+		if tt.expectCode == 200 {
+			require.Equal(t, "application/json", rsp.Header.Get("Content-Type"), tt.name)
+		}
+	}
+
+	conn, err := grpc.NewClient(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = colmetricspb.NewMetricsServiceClient(conn).Export(context.Background(), &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				{Key: "service.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "grpc"}}},
+			}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{{
+				Name: "mem",
+				Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{{
+					Value: &metricspb.NumberDataPoint_AsInt{AsInt: 42},
+					Attributes: []*commonpb.KeyValue{
+						// the data point attribute overrides the resource attribute
+						{Key: "service.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "point"}}},
+					},
+				}}}},
+			}}}},
+		}},
+	})
+	require.NoError(t, err)
+
+	pipeline.Stop()
+	require.Equal(t, ``+
+		`{"body":null,"count":null,"host":"a","le_1":null,"le_2":null,"le_inf":null,"name":"cpu","p99":null,"service.name":"svc","trace_id":null,"type":"gauge","value":1.5}`+"\n"+
+		`{"body":null,"count":null,"host":null,"le_1":null,"le_2":null,"le_inf":null,"name":"requests","p99":null,"service.name":"svc","trace_id":null,"type":"sum","value":10}`+"\n"+
+		`{"body":null,"count":3,"host":null,"le_1":null,"le_2":null,"le_inf":null,"name":"latency","p99":3,"service.name":"svc","trace_id":null,"type":"summary","value":null}`+"\n"+
+		`{"body":null,"count":6,"host":null,"le_1":1,"le_2":3,"le_inf":6,"name":"size","p99":null,"service.name":"svc","trace_id":null,"type":"histogram","value":null}`+"\n"+
+		`{"body":null,"count":4,"host":null,"le_1":null,"le_2":2,"le_inf":4,"name":"delay","p99":null,"service.name":"svc","trace_id":null,"type":"exponential_histogram","value":null}`+"\n"+
+		`{"body":"hello","count":null,"host":"b","le_1":null,"le_2":null,"le_inf":null,"name":null,"p99":null,"service.name":"svc","trace_id":"5b8efff798038103d269b633813fc60c","type":null,"value":null}`+"\n"+
+		`{"body":null,"count":null,"host":null,"le_1":null,"le_2":null,"le_inf":null,"name":"mem","p99":null,"service.name":"point","trace_id":null,"type":"gauge","value":42}`+"\n",
+		out.String())
+}
@@ -0,0 +1,385 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/OutOfBedlam/tine/util"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name:    "otlp",
+		Factory: OtlpOutlet,
+	})
+}
+
+func OtlpOutlet(ctx *engine.Context) engine.Outlet {
+	return &otlpOutlet{ctx: ctx}
+}
+
+type otlpOutlet struct {
+	ctx *engine.Context
+
+	address     string
+	signal      string
+	encoding    string
+	compression string
+	timeout     time.Duration
+	headers     map[string]string
+	resource    *resourcepb.Resource
+
+	// OTLP/HTTP
+	url    string
+	client *http.Client
+	// OTLP/gRPC
+	conn          *grpc.ClientConn
+	metricsClient colmetricspb.MetricsServiceClient
+	logsClient    collogspb.LogsServiceClient
+}
+
+var _ = engine.Outlet((*otlpOutlet)(nil))
+
+func (oo *otlpOutlet) Open() error {
+	conf := oo.ctx.Config()
+	oo.address = conf.GetString("address", "grpc://127.0.0.1:4317")
+	oo.signal = conf.GetString("signal", "metrics")
+	oo.encoding = conf.GetString("encoding", "protobuf")
+	oo.compression = conf.GetString("compression", "")
+	oo.timeout = conf.GetDuration("timeout", 10*time.Second)
+	oo.headers = map[string]string{}
+	headers := conf.GetConfig("headers", nil)
+	for k := range headers {
+		oo.headers[k] = headers.GetString(k, "")
+	}
+	oo.resource = &resourcepb.Resource{
+		Attributes: configAttributes(conf.GetConfig("resource", engine.Config{"service.name": "tine"})),
+	}
+
+	if oo.signal != "metrics" && oo.signal != "logs" {
+		return fmt.Errorf("outlet.otlp unsupported signal %q", oo.signal)
+	}
+	if oo.encoding != "protobuf" && oo.encoding != "json" {
+		return fmt.Errorf("outlet.otlp unsupported encoding %q", oo.encoding)
+	}
+	if oo.compression != "" && oo.compression != "gzip" {
+		return fmt.Errorf("outlet.otlp unsupported compression %q", oo.compression)
+	}
+
+	u, err := url.Parse(oo.address)
+	if err != nil {
+		return fmt.Errorf("outlet.otlp invalid address %q, %w", oo.address, err)
+	}
+	var tlsConf *tls.Config
+	if u.Scheme == "https" || u.Scheme == "grpcs" {
+		tlsConf, err = util.NewClientTLSConfig(util.TLSConfig{
+			CertFile:           conf.GetString("tls_cert", ""),
+			KeyFile:            conf.GetString("tls_key", ""),
+			CAFile:             conf.GetString("tls_ca", ""),
+			InsecureSkipVerify: conf.GetBool("tls_insecure_skip_verify", false),
+			ServerName:         conf.GetString("tls_server_name", ""),
+		})
+		if err != nil {
+			return fmt.Errorf("outlet.otlp %w", err)
+		}
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/v1/" + oo.signal
+		}
+		oo.url = u.String()
+		oo.client = &http.Client{
+			Timeout:   oo.timeout,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConf},
+		}
+	case "grpc", "grpcs":
+		creds := insecure.NewCredentials()
+		if tlsConf != nil {
+			creds = credentials.NewTLS(tlsConf)
+		}
+		conn, err := grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return fmt.Errorf("outlet.otlp %w", err)
+		}
+		oo.conn = conn
+		oo.metricsClient = colmetricspb.NewMetricsServiceClient(conn)
+		oo.logsClient = collogspb.NewLogsServiceClient(conn)
+	default:
+		return fmt.Errorf("outlet.otlp unsupported protocol: %s in %s", u.Scheme, oo.address)
+	}
+	oo.ctx.LogDebug("outlet.otlp", "address", oo.address, "signal", oo.signal)
+	return nil
+}
+
+func (oo *otlpOutlet) Close() error {
+	if oo.conn != nil {
+		oo.conn.Close()
+		oo.conn = nil
+	}
+	if oo.client != nil {
+		oo.client.CloseIdleConnections()
+		oo.client = nil
+	}
+	return nil
+}
+
+func (oo *otlpOutlet) Handle(recs []engine.Record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	var req proto.Message
+	if oo.signal == "logs" {
+		req = &collogspb.ExportLogsServiceRequest{ResourceLogs: []*logspb.ResourceLogs{{
+			Resource:  oo.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{LogRecords: logRecords(recs)}},
+		}}}
+	} else {
+		metrics := recordsMetrics(recs)
+		if len(metrics) == 0 {
+			return nil
+		}
+		req = &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource:     oo.resource,
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: metrics}},
+		}}}
+	}
+
+	var err error
+	if oo.conn != nil {
+		err = oo.exportGrpc(req)
+	} else {
+		err = oo.exportHttp(req)
+	}
+	if err != nil {
+		return fmt.Errorf("outlet.otlp %s export error, %w", oo.signal, err)
+	}
+	return nil
+}
+
+func (oo *otlpOutlet) exportGrpc(req proto.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), oo.timeout)
+	defer cancel()
+	for k, v := range oo.headers {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+	var opts []grpc.CallOption
+	if oo.compression != "" {
+		opts = append(opts, grpc.UseCompressor(oo.compression))
+	}
+	var err error
+	switch r := req.(type) {
+	case *colmetricspb.ExportMetricsServiceRequest:
+		_, err = oo.metricsClient.Export(ctx, r, opts...)
+	case *collogspb.ExportLogsServiceRequest:
+		_, err = oo.logsClient.Export(ctx, r, opts...)
+	}
+	return err
+}
+
+func (oo *otlpOutlet) exportHttp(req proto.Message) error {
+	var data []byte
+	var err error
+	contentType := contentTypeProtobuf
+	if oo.encoding == "json" {
+		contentType = contentTypeJSON
+		if data, err = protojson.Marshal(req); err == nil {
+			data, err = convertIds(data, false)
+		}
+	} else {
+		data, err = proto.Marshal(req)
+	}
+	if err != nil {
+		return err
+	}
+	if oo.compression == "gzip" {
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		zw.Write(data)
+		zw.Close()
+		data = buf.Bytes()
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, oo.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	if oo.compression != "" {
+		httpReq.Header.Set("Content-Encoding", oo.compression)
+	}
+	for k, v := range oo.headers {
+		httpReq.Header.Set(k, v)
+	}
+	rsp, err := oo.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return fmt.Errorf("%s %s", rsp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, rsp.Body)
+	return nil
+}
+
+// recordsMetrics converts the records to the metrics.
+// The record that has "name" and "value" fields is a data point of the metric,
+// it is a monotonic cumulative sum if the "type" field is "sum" or "counter", otherwise a gauge.
+// For the other records, each numeric field is a gauge of the field name.
+func recordsMetrics(recs []engine.Record) []*metricspb.Metric {
+	ret := []*metricspb.Metric{}
+	metric := func(name string, unit string, sum bool) *metricspb.Metric {
+		for _, m := range ret {
+			if m.Name != name {
+				continue
+			}
+			if _, ok := m.Data.(*metricspb.Metric_Sum); ok == sum {
+				return m
+			}
+		}
+		m := &metricspb.Metric{Name: name, Unit: unit}
+		if sum {
+			m.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}}
+		} else {
+			m.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}}
+		}
+		ret = append(ret, m)
+		return m
+	}
+	addPoint := func(m *metricspb.Metric, dp *metricspb.NumberDataPoint) {
+		switch d := m.Data.(type) {
+		case *metricspb.Metric_Sum:
+			d.Sum.DataPoints = append(d.Sum.DataPoints, dp)
+		case *metricspb.Metric_Gauge:
+			d.Gauge.DataPoints = append(d.Gauge.DataPoints, dp)
+		}
+	}
+
+	for _, r := range recs {
+		ts := unixNano(recordTime(r))
+		attrs := tagAttributes(r.Tags())
+		nameField, valueField := r.Field("name"), r.Field("value")
+		if nameField != nil && valueField != nil && !nameField.IsNull() {
+			name, _ := nameField.Value.String()
+			dp := numberDataPoint(valueField.Value, ts, attrs)
+			if name == "" || dp == nil {
+				continue
+			}
+			typ, unit := "", ""
+			if f := r.Field("type"); f != nil && !f.IsNull() {
+				typ, _ = f.Value.String()
+			}
+			if f := r.Field("unit"); f != nil && !f.IsNull() {
+				unit, _ = f.Value.String()
+			}
+			addPoint(metric(name, unit, typ == "sum" || typ == "counter"), dp)
+			continue
+		}
+		for _, f := range r.Fields() {
+			if f == nil || f.IsNull() || f.Name == "timestamp" {
+				continue
+			}
+			if dp := numberDataPoint(f.Value, ts, attrs); dp != nil {
+				addPoint(metric(f.Name, "", false), dp)
+			}
+		}
+	}
+	return ret
+}
+
+// numberDataPoint returns nil if the value is not a number
+func numberDataPoint(v *engine.Value, ts uint64, attrs []*commonpb.KeyValue) *metricspb.NumberDataPoint {
+	dp := &metricspb.NumberDataPoint{TimeUnixNano: ts, Attributes: attrs}
+	switch raw := v.Raw().(type) {
+	case int64:
+		dp.Value = &metricspb.NumberDataPoint_AsInt{AsInt: raw}
+	case uint64:
+		dp.Value = &metricspb.NumberDataPoint_AsInt{AsInt: int64(raw)}
+	case float64:
+		dp.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: raw}
+	default:
+		return nil
+	}
+	return dp
+}
+
+// log record fields those are not exported as attributes
+var logFields = []string{"timestamp", "severity_number", "severity_text", "trace_id", "span_id"}
+
+// logRecords converts the records to the log records,
+// the body is the "body" or the "message" field, the other fields and the tags are the attributes.
+func logRecords(recs []engine.Record) []*logspb.LogRecord {
+	ret := make([]*logspb.LogRecord, 0, len(recs))
+	now := unixNano(engine.Now())
+	for _, r := range recs {
+		lr := &logspb.LogRecord{
+			TimeUnixNano:         unixNano(recordTime(r)),
+			ObservedTimeUnixNano: now,
+		}
+		bodyName := ""
+		for _, name := range []string{"body", "message"} {
+			if f := r.Field(name); f != nil {
+				lr.Body, bodyName = toAnyValue(f.Value), name
+				break
+			}
+		}
+		if f := r.Field("severity_number"); f != nil && !f.IsNull() {
+			if v, ok := f.Value.Int64(); ok {
+				lr.SeverityNumber = logspb.SeverityNumber(v)
+			}
+		}
+		if f := r.Field("severity_text"); f != nil && !f.IsNull() {
+			lr.SeverityText, _ = f.Value.String()
+		}
+		if f := r.Field("trace_id"); f != nil && !f.IsNull() {
+			if s, ok := f.Value.String(); ok {
+				lr.TraceId, _ = hex.DecodeString(s)
+			}
+		}
+		if f := r.Field("span_id"); f != nil && !f.IsNull() {
+			if s, ok := f.Value.String(); ok {
+				lr.SpanId, _ = hex.DecodeString(s)
+			}
+		}
+		for _, f := range r.Fields() {
+			if f == nil || f.Name == bodyName || slices.Contains(logFields, f.Name) {
+				continue
+			}
+			if av := toAnyValue(f.Value); av != nil {
+				lr.Attributes = append(lr.Attributes, &commonpb.KeyValue{Key: f.Name, Value: av})
+			}
+		}
+		lr.Attributes = append(lr.Attributes, tagAttributes(r.Tags())...)
+		ret = append(ret, lr)
+	}
+	return ret
+}
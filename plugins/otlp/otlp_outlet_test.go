@@ -0,0 +1,133 @@
+package otlp_test
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/OutOfBedlam/tine/plugins/otlp"
+	"github.com/stretchr/testify/require"
+)
+
+func outletContext(conf engine.Config) *engine.Context {
+	return (&engine.Context{}).WithConfig(conf).WithLogger(slog.Default())
+}
+
+func TestOtlpOutlet(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	metricRecs := func() []engine.Record {
+		r1 := engine.NewRecord(
+			engine.NewField("name", "requests"),
+			engine.NewField("type", "counter"),
+			engine.NewField("value", int64(10)),
+			engine.NewField("timestamp", ts),
+		)
+		r1.Tags().Set("host", engine.NewValue("a"))
+		// each numeric field is a gauge
+		r2 := engine.NewRecord(
+			engine.NewField("load1", 0.5),
+			engine.NewField("procs", uint64(3)),
+			engine.NewField("os", "linux"),
+		)
+		r2.Tags().Set("host", engine.NewValue("b"))
+		return []engine.Record{r1, r2}
+	}
+	logRecs := func() []engine.Record {
+		r := engine.NewRecord(
+			engine.NewField("timestamp", ts),
+			engine.NewField("severity_number", int64(17)),
+			engine.NewField("severity_text", "ERROR"),
+			engine.NewField("message", "failed"),
+			engine.NewField("trace_id", "5b8efff798038103d269b633813fc60c"),
+			engine.NewField("code", int64(500)),
+		)
+		r.Tags().Set("host", engine.NewValue("c"))
+		return []engine.Record{r}
+	}
+
+	tests := []struct {
+		name   string
+		scheme string
+		conf   engine.Config
+		recs   []engine.Record
+		expect string
+	}{
+		{
+			name:   "grpc_metrics",
+			scheme: "grpc",
+			conf:   engine.NewConfig().Set("compression", "gzip"),
+			recs:   metricRecs(),
+			expect: `{"_in":"otlp","body":null,"code":null,"host":"a","name":"requests","service.name":"tine","severity_text":null,"trace_id":null,"type":"sum","value":10}` + "\n" +
+				`{"_in":"otlp","body":null,"code":null,"host":"b","name":"load1","service.name":"tine","severity_text":null,"trace_id":null,"type":"gauge","value":0.5}` + "\n" +
+				`{"_in":"otlp","body":null,"code":null,"host":"b","name":"procs","service.name":"tine","severity_text":null,"trace_id":null,"type":"gauge","value":3}` + "\n",
+		},
+		{
+			name:   "http_protobuf_logs",
+			scheme: "http",
+			conf: engine.NewConfig().
+				Set("signal", "logs").
+				Set("resource", map[string]any{"service.name": "app", "service.version": "1.0"}),
+			recs:   logRecs(),
+			expect: `{"_in":"otlp","body":"failed","code":500,"host":"c","name":null,"service.name":"app","severity_text":"ERROR","trace_id":"5b8efff798038103d269b633813fc60c","type":null,"value":null}` + "\n",
+		},
+		{
+			name:   "http_json_logs",
+			scheme: "http",
+			conf: engine.NewConfig().
+				Set("signal", "logs").
+				Set("encoding", "json").
+				Set("compression", "gzip"),
+			recs:   logRecs(),
+			expect: `{"_in":"otlp","body":"failed","code":500,"host":"c","name":null,"service.name":"tine","severity_text":"ERROR","trace_id":"5b8efff798038103d269b633813fc60c","type":null,"value":null}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grpcAddr, httpAddr := freeAddr(t), freeAddr(t)
+			dsl := fmt.Sprintf(`
+				[[inlets.otlp]]
+					grpc_address = "%s"
+					http_address = "%s"
+				[[flows.select]]
+					includes = ["#service.name", "#host", "#_in", "#code", "name", "type", "value", "body", "severity_text", "trace_id"]
+				[[outlets.file]]
+					format = "json"
+				`, grpcAddr, httpAddr)
+			out := &syncBuffer{}
+			pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+			require.NoError(t, err)
+			go pipeline.Run()
+			waitListen(t, grpcAddr)
+			waitListen(t, httpAddr)
+
+			addr := grpcAddr
+			if tt.scheme == "http" {
+				addr = httpAddr
+			}
+			outlet := otlp.OtlpOutlet(outletContext(tt.conf.Set("address", tt.scheme+"://"+addr)))
+			require.NoError(t, outlet.Open())
+			require.NoError(t, outlet.Handle(tt.recs))
+			require.NoError(t, outlet.Close())
+
+			pipeline.Stop()
+			require.Equal(t, tt.expect, out.String())
+		})
+	}
+}
+
+func TestOtlpOutletError(t *testing.T) {
+	addr := freeAddr(t)
+	outlet := otlp.OtlpOutlet(outletContext(engine.NewConfig().
+		Set("address", "http://"+addr).
+		Set("timeout", "1s")))
+	require.NoError(t, outlet.Open())
+	err := outlet.Handle([]engine.Record{engine.NewRecord(engine.NewField("value", 1.0))})
+	require.ErrorContains(t, err, "outlet.otlp metrics export error")
+	require.NoError(t, outlet.Close())
+
+	outlet = otlp.OtlpOutlet(outletContext(engine.NewConfig().Set("address", "tcp://"+addr)))
+	require.ErrorContains(t, outlet.Open(), "unsupported protocol")
+}
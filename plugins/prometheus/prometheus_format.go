@@ -0,0 +1,364 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+func init() {
+	engine.RegisterEncoder(&engine.EncoderReg{
+		Name:        "prometheus",
+		Factory:     NewPrometheusEncoder,
+		ContentType: "text/plain; version=0.0.4; charset=utf-8",
+	})
+	engine.RegisterDecoder(&engine.DecoderReg{
+		Name:         "prometheus",
+		Factory:      NewPrometheusDecoder,
+		ContentTypes: []string{"text/plain; version=0.0.4"},
+	})
+}
+
+// NewPrometheusDecoder returns the decoder of the prometheus text exposition format.
+//
+// Each sample becomes a record of the fields "name", "type", "value" and "timestamp"
+// (only if the sample has it), the labels of the sample become the tags of the record.
+// The samples of histograms and summaries are kept as they are exposed,
+// e.g. "http_duration_seconds_bucket" with "le" tag, "http_duration_seconds_sum"
+// and "http_duration_seconds_count", their type is "histogram" or "summary".
+func NewPrometheusDecoder(conf engine.DecoderConfig) engine.Decoder {
+	return &PrometheusDecoder{DecoderConfig: conf}
+}
+
+type PrometheusDecoder struct {
+	engine.DecoderConfig
+	types map[string]string
+}
+
+func (pd *PrometheusDecoder) Decode() ([]engine.Record, error) {
+	if pd.types == nil {
+		pd.types = map[string]string{}
+	}
+	ret := []engine.Record{}
+	sc := bufio.NewScanner(pd.Reader)
+	sc.Buffer(make([]byte, 0, 4096), 1024*1024)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			// # TYPE <name> <type>
+			toks := strings.Fields(line)
+			if len(toks) >= 4 && toks[1] == "TYPE" {
+				pd.types[toks[2]] = toks[3]
+			}
+			continue
+		}
+		rec, err := pd.parseSample(line)
+		if err != nil {
+			return ret, fmt.Errorf("prometheus line %d, %w", lineNo, err)
+		}
+		ret = append(ret, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return ret, err
+	}
+	return ret, io.EOF
+}
+
+// parseSample parses `name{label="value",...} value [timestamp]`
+func (pd *PrometheusDecoder) parseSample(line string) (engine.Record, error) {
+	idx := strings.IndexAny(line, "{ \t")
+	if idx <= 0 {
+		return nil, fmt.Errorf("invalid sample %q", line)
+	}
+	name := line[:idx]
+	rest := line[idx:]
+	labels := [][2]string{}
+	if rest[0] == '{' {
+		var err error
+		if labels, rest, err = parseLabels(rest[1:]); err != nil {
+			return nil, err
+		}
+	}
+	toks := strings.Fields(rest)
+	if len(toks) < 1 || len(toks) > 2 {
+		return nil, fmt.Errorf("invalid sample %q", line)
+	}
+	value, err := parseFloat(toks[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", toks[0])
+	}
+
+	rec := engine.NewRecord(
+		engine.NewField("name", name),
+		engine.NewField("type", pd.typeOf(name)),
+		engine.NewField("value", value),
+	)
+	if len(toks) == 2 {
+		ms, err := strconv.ParseInt(toks[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", toks[1])
+		}
+		rec = rec.Append(engine.NewField("timestamp", time.UnixMilli(ms)))
+	}
+	tags := rec.Tags()
+	for _, l := range labels {
+		tags.Set(l[0], engine.NewValue(l[1]))
+	}
+	return rec, nil
+}
+
+// typeOf returns the type of the metric family that the sample belongs to
+func (pd *PrometheusDecoder) typeOf(name string) string {
+	if typ, ok := pd.types[name]; ok {
+		return typ
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			if typ, ok := pd.types[base]; ok && (typ == "histogram" || typ == "summary") {
+				return typ
+			}
+		}
+	}
+	return "untyped"
+}
+
+// parseLabels parses the labels after '{', and returns the rest after '}'
+func parseLabels(s string) ([][2]string, string, error) {
+	ret := [][2]string{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return nil, "", fmt.Errorf("unterminated labels")
+		}
+		if s[0] == '}' {
+			return ret, s[1:], nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("invalid label %q", s)
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if s == "" || s[0] != '"' {
+			return nil, "", fmt.Errorf("invalid label value of %q", name)
+		}
+		sb := strings.Builder{}
+		closed := false
+		i := 1
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					sb.WriteByte('\n')
+				default:
+					sb.WriteByte(s[i])
+				}
+			} else if c == '"' {
+				closed = true
+				break
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("unterminated label value of %q", name)
+		}
+		ret = append(ret, [2]string{name, sb.String()})
+		s = s[i+1:]
+	}
+}
+
+func parseFloat(s string) (float64, error) {
+	switch s {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// NewPrometheusEncoder returns the encoder of the prometheus text exposition format.
+//
+// A record that has the "name" and "value" fields, like the records of the prometheus decoder,
+// is written as a sample, its "type" field is used for the TYPE line.
+// Otherwise, each numeric or boolean field of the record is written as an untyped sample
+// named by the field name, and the string fields become the labels.
+// The tags of the record, except the ones start with "_", become the labels too.
+// The samples of a batch are grouped by the metric family in the order of the first appearance,
+// and the TYPE line of a family is written only once.
+func NewPrometheusEncoder(conf engine.EncoderConfig) engine.Encoder {
+	return &PrometheusEncoder{EncoderConfig: conf, typed: map[string]bool{}}
+}
+
+type PrometheusEncoder struct {
+	engine.EncoderConfig
+	// the families those TYPE lines are written
+	typed map[string]bool
+}
+
+// promSample is a sample to be written
+type promSample struct {
+	name   string
+	typ    string
+	labels map[string]string
+	value  float64
+	ts     string
+}
+
+func (pe *PrometheusEncoder) Encode(recs []engine.Record) error {
+	families := []string{}
+	samples := map[string][]promSample{}
+	add := func(s promSample) {
+		family := familyOf(s.name, s.typ)
+		if _, ok := samples[family]; !ok {
+			families = append(families, family)
+		}
+		samples[family] = append(samples[family], s)
+	}
+	for _, rec := range recs {
+		labels := map[string]string{}
+		for _, name := range rec.Tags().Names() {
+			if strings.HasPrefix(name, "_") {
+				continue
+			}
+			if v := rec.Tags().Get(name); v != nil && !v.IsNull() {
+				labels[metricName(name)] = v.Format(pe.FormatOption)
+			}
+		}
+
+		nameField, valueField := rec.Field("name"), rec.Field("value")
+		if nameField != nil && valueField != nil && nameField.Type() == engine.STRING {
+			name, _ := nameField.Value.String()
+			value, ok := valueField.Value.Float64()
+			if !ok {
+				continue
+			}
+			typ := "untyped"
+			if f := rec.Field("type"); f != nil {
+				typ, _ = f.Value.String()
+			}
+			ts := ""
+			if f := rec.Field("timestamp"); f != nil {
+				if tm, ok := f.Value.Time(); ok {
+					ts = strconv.FormatInt(tm.UnixMilli(), 10)
+				}
+			}
+			add(promSample{name: metricName(name), typ: typ, labels: labels, value: value, ts: ts})
+			continue
+		}
+
+		values := [][2]any{}
+		for _, f := range rec.Fields(pe.Fields...) {
+			if f == nil || f.IsNull() {
+				continue
+			}
+			switch f.Type() {
+			case engine.INT, engine.UINT, engine.FLOAT, engine.BOOL:
+				v, _ := f.Value.Float64()
+				values = append(values, [2]any{metricName(f.Name), v})
+			case engine.STRING:
+				labels[metricName(f.Name)] = f.Value.Format(pe.FormatOption)
+			}
+		}
+		for _, v := range values {
+			add(promSample{name: v[0].(string), typ: "untyped", labels: labels, value: v[1].(float64)})
+		}
+	}
+
+	w := bufio.NewWriter(pe.Writer)
+	for _, family := range families {
+		list := samples[family]
+		if !pe.typed[family] {
+			fmt.Fprintf(w, "# TYPE %s %s\n", family, list[0].typ)
+			pe.typed[family] = true
+		}
+		for _, s := range list {
+			writeSample(w, s)
+		}
+	}
+	return w.Flush()
+}
+
+// familyOf returns the metric family name of the sample
+func familyOf(name string, typ string) string {
+	if typ == "histogram" || typ == "summary" {
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			if base, ok := strings.CutSuffix(name, suffix); ok {
+				return base
+			}
+		}
+	}
+	return name
+}
+
+func writeSample(w *bufio.Writer, s promSample) {
+	name, labels, value, ts := s.name, s.labels, s.value, s.ts
+	w.WriteString(name)
+	if len(labels) > 0 {
+		names := make([]string, 0, len(labels))
+		for k := range labels {
+			names = append(names, k)
+		}
+		slices.Sort(names)
+		w.WriteByte('{')
+		for i, k := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(k)
+			w.WriteString(`="`)
+			w.WriteString(labelEscaper.Replace(labels[k]))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	if ts != "" {
+		w.WriteByte(' ')
+		w.WriteString(ts)
+	}
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// metricName replaces the characters those are not allowed in the metric and label names
+func metricName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || (c >= '0' && c <= '9' && i > 0)) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
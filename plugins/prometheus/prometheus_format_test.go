@@ -0,0 +1,151 @@
+package prometheus_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func ExamplePrometheusDecoder() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"# HELP http_requests_total The total number of HTTP requests.",
+			"# TYPE http_requests_total counter",
+			'http_requests_total{method="post",code="200"} 1027 1395066363000',
+			'http_requests_total{method="post",code="400"}    3 1395066363000',
+			"# TYPE rpc_duration_seconds histogram",
+			'rpc_duration_seconds_bucket{le="0.05"} 24054',
+			'rpc_duration_seconds_bucket{le="+Inf"} 144320',
+			"rpc_duration_seconds_sum 53423",
+			"rpc_duration_seconds_count 144320",
+			"temperature -3.5e-1",
+		]
+		format = "prometheus"
+	[[flows.select]]
+		includes = ["#method", "#code", "#le", "name", "type", "value", "timestamp"]
+	[[outlets.file]]
+		path = "-"
+		format = "json"
+		timeformat = "ms"
+	`
+	// Make the output timestamp deterministic, so we can compare it
+	// This line is required only for testing
+	engine.Now = func() time.Time { return time.Unix(1721954797, 0) }
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// {"code":"200","le":null,"method":"post","name":"http_requests_total","timestamp":1395066363000,"type":"counter","value":1027}
+	// {"code":"400","le":null,"method":"post","name":"http_requests_total","timestamp":1395066363000,"type":"counter","value":3}
+	// {"code":null,"le":"0.05","method":null,"name":"rpc_duration_seconds_bucket","timestamp":null,"type":"histogram","value":24054}
+	// {"code":null,"le":"+Inf","method":null,"name":"rpc_duration_seconds_bucket","timestamp":null,"type":"histogram","value":144320}
+	// {"code":null,"le":null,"method":null,"name":"rpc_duration_seconds_sum","timestamp":null,"type":"histogram","value":53423}
+	// {"code":null,"le":null,"method":null,"name":"rpc_duration_seconds_count","timestamp":null,"type":"histogram","value":144320}
+	// {"code":null,"le":null,"method":null,"name":"temperature","timestamp":null,"type":"untyped","value":-0.35}
+}
+
+func ExamplePrometheusEncoder() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"# TYPE rpc_duration_seconds histogram",
+			'rpc_duration_seconds_bucket{le="0.05",service="a\"b"} 24054',
+			'rpc_duration_seconds_bucket{le="+Inf",service="a\"b"} 144320',
+			'rpc_duration_seconds_sum{service="a\"b"} 53423',
+			'rpc_duration_seconds_count{service="a\"b"} 144320',
+		]
+		format = "prometheus"
+	[[outlets.file]]
+		path = "-"
+		format = "prometheus"
+	`
+	engine.Now = func() time.Time { return time.Unix(1721954797, 0) }
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// # TYPE rpc_duration_seconds histogram
+	// rpc_duration_seconds_bucket{le="0.05",service="a\"b"} 24054
+	// rpc_duration_seconds_bucket{le="+Inf",service="a\"b"} 144320
+	// rpc_duration_seconds_sum{service="a\"b"} 53423
+	// rpc_duration_seconds_count{service="a\"b"} 144320
+}
+
+func ExamplePrometheusEncoder_fields() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"host1,cpu0,12.5,3",
+		]
+		format = "csv"
+		fields = ["host", "cpu", "usage", "procs"]
+		types  = ["string", "string", "float", "int"]
+	[[outlets.file]]
+		path = "-"
+		format = "prometheus"
+	`
+	engine.Now = func() time.Time { return time.Unix(1721954797, 0) }
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// # TYPE usage untyped
+	// usage{cpu="cpu0",host="host1"} 12.5
+	// # TYPE procs untyped
+	// procs{cpu="cpu0",host="host1"} 3
+}
+
+func ExamplePrometheusEncoder_grouped() {
+	dsl := `
+	[[inlets.file]]
+		data = [
+			"host1,12.5,3",
+			"host2,7.5,5",
+		]
+		format = "csv"
+		fields = ["host", "usage", "procs"]
+		types  = ["string", "float", "int"]
+	[[outlets.file]]
+		path = "-"
+		format = "prometheus"
+	`
+	engine.Now = func() time.Time { return time.Unix(1721954797, 0) }
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	if err != nil {
+		panic(err)
+	}
+	if err := pipeline.Run(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// # TYPE usage untyped
+	// usage{host="host1"} 12.5
+	// usage{host="host2"} 7.5
+	// # TYPE procs untyped
+	// procs{host="host1"} 3
+	// procs{host="host2"} 5
+}
+
+func TestPrometheusContentType(t *testing.T) {
+	reg := engine.GetDecoderByContentType("text/plain; version=0.0.4; charset=utf-8")
+	require.NotNil(t, reg)
+	require.Equal(t, "prometheus", reg.Name)
+	require.Nil(t, engine.GetDecoderByContentType("text/plain"))
+}
@@ -0,0 +1,157 @@
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "prometheus",
+		Factory: PrometheusInlet,
+	})
+}
+
+func PrometheusInlet(ctx *engine.Context) engine.Inlet {
+	return &prometheusInlet{ctx: ctx}
+}
+
+type prometheusInlet struct {
+	ctx      *engine.Context
+	conf     engine.Config
+	client   *http.Client
+	runCount int64
+
+	targets       []string
+	instanceTag   string
+	username      string
+	password      string
+	token         string
+	runCountLimit int64
+}
+
+var _ = engine.PeriodicInlet((*prometheusInlet)(nil))
+
+func (pi *prometheusInlet) Open() error {
+	conf := pi.ctx.Config()
+	pi.targets = conf.GetStringSlice("targets", nil)
+	pi.instanceTag = conf.GetString("instance_tag", "instance")
+	pi.username = conf.GetString("username", "")
+	pi.password = conf.GetString("password", "")
+	pi.token = conf.GetString("token", "")
+	pi.runCountLimit = int64(conf.GetInt("count", 0))
+	pi.conf = maps.Clone(conf).Set("format", "prometheus").Unset("compress")
+
+	if len(pi.targets) == 0 {
+		return fmt.Errorf("inlet.prometheus targets are required")
+	}
+	for _, t := range pi.targets {
+		if _, err := url.Parse(t); err != nil {
+			return fmt.Errorf("inlet.prometheus invalid target %q, %w", t, err)
+		}
+	}
+	pi.client = &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+		Timeout:   conf.GetDuration("timeout", 3*time.Second),
+	}
+	pi.ctx.LogDebug("inlet.prometheus", "targets", pi.targets)
+	return nil
+}
+
+func (pi *prometheusInlet) Close() error {
+	return nil
+}
+
+func (pi *prometheusInlet) Interval() time.Duration {
+	return pi.ctx.Config().GetDuration("interval", 10*time.Second)
+}
+
+// Process scrapes the targets concurrently, and sends the records of each target
+func (pi *prometheusInlet) Process(next engine.InletNextFunc) {
+	runCount := atomic.AddInt64(&pi.runCount, 1)
+	if pi.runCountLimit > 0 && runCount > pi.runCountLimit {
+		next(nil, io.EOF)
+		return
+	}
+
+	results := make([][]engine.Record, len(pi.targets))
+	wg := sync.WaitGroup{}
+	for i, target := range pi.targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			recs, err := pi.scrape(target)
+			if err != nil {
+				pi.ctx.LogWarn("inlet.prometheus", "target", target, "error", err.Error())
+				return
+			}
+			results[i] = recs
+		}(i, target)
+	}
+	wg.Wait()
+
+	var resultErr error
+	if pi.runCountLimit > 0 && runCount >= pi.runCountLimit {
+		resultErr = io.EOF
+	}
+	ret := []engine.Record{}
+	for _, recs := range results {
+		ret = append(ret, recs...)
+	}
+	next(ret, resultErr)
+}
+
+func (pi *prometheusInlet) scrape(target string) ([]engine.Record, error) {
+	req, err := http.NewRequestWithContext(pi.ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4;q=1,*/*;q=0.1")
+	if pi.token != "" {
+		req.Header.Set("Authorization", "Bearer "+pi.token)
+	} else if pi.username != "" {
+		req.SetBasicAuth(pi.username, pi.password)
+	}
+	rsp, err := pi.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", rsp.StatusCode)
+	}
+
+	reader, err := engine.NewReader(rsp.Body, pi.conf)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if pi.instanceTag != "" {
+		instance := target
+		if u, err := url.Parse(target); err == nil && u.Host != "" {
+			instance = u.Host
+		}
+		for _, r := range ret {
+			r.Tags().Set(pi.instanceTag, engine.NewValue(instance))
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,68 @@
+package prometheus_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusInlet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/secure" && r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, "# TYPE up gauge")
+		fmt.Fprintf(w, "up{path=%q} 1\n", r.URL.Path)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		name   string
+		conf   string
+		expect string
+	}{
+		{
+			name: "targets",
+			conf: fmt.Sprintf(`targets = ["%s/metrics", "%s/other"]`, server.URL, server.URL),
+			expect: fmt.Sprintf(`{"instance":%q,"name":"up","path":"/metrics","type":"gauge","value":1}`, u.Host) + "\n" +
+				fmt.Sprintf(`{"instance":%q,"name":"up","path":"/other","type":"gauge","value":1}`, u.Host) + "\n",
+		},
+		{
+			name:   "token",
+			conf:   fmt.Sprintf(`targets = ["%s/secure"]`+"\n"+`token = "secret"`, server.URL),
+			expect: fmt.Sprintf(`{"instance":%q,"name":"up","path":"/secure","type":"gauge","value":1}`, u.Host) + "\n",
+		},
+		{
+			name:   "unauthorized",
+			conf:   fmt.Sprintf(`targets = ["%s/secure"]`, server.URL),
+			expect: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsl := fmt.Sprintf(`
+			[[inlets.prometheus]]
+				%s
+				count = 1
+			[[flows.select]]
+				includes = ["#instance", "#path", "*"]
+			[[outlets.file]]
+				format = "json"
+			`, tt.conf)
+			out := &bytes.Buffer{}
+			pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+			require.NoError(t, err)
+			require.NoError(t, pipeline.Run())
+			require.Equal(t, tt.expect, out.String())
+		})
+	}
+}
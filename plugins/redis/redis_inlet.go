@@ -0,0 +1,375 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "redis",
+		Factory: RedisInlet,
+	})
+}
+
+func RedisInlet(ctx *engine.Context) engine.Inlet {
+	return &redisInlet{
+		ctx:     ctx,
+		pushCh:  make(chan redisData),
+		closeCh: make(chan struct{}),
+	}
+}
+
+type redisInlet struct {
+	ctx     *engine.Context
+	conf    engine.Config
+	client  *redis.Client
+	timeout time.Duration
+
+	// streams
+	streams  []string
+	group    string
+	consumer string
+	field    string
+	count    int64
+	block    time.Duration
+	// acknowledge the messages those can not be decoded
+	dropUndecodable bool
+
+	// pub/sub
+	pubsub *redis.PubSub
+
+	readCtx    context.Context
+	readCancel context.CancelFunc
+	pushCh     chan redisData
+	closeCh    chan struct{}
+	closeOnce  sync.Once
+	closeWg    sync.WaitGroup
+}
+
+// redisData is the records of the messages,
+// ack is not nil if the messages should be acknowledged after the records are handled by the outlets.
+type redisData struct {
+	records []engine.Record
+	ack     func()
+}
+
+var _ = engine.Inlet((*redisInlet)(nil))
+
+func (ri *redisInlet) Open() error {
+	conf := ri.ctx.Config()
+	ri.streams = conf.GetStringSlice("streams", nil)
+	ri.group = conf.GetString("group", "tine")
+	ri.consumer = conf.GetString("consumer", "tine")
+	ri.field = conf.GetString("field", "payload")
+	ri.count = conf.GetInt64("count", 100)
+	ri.timeout = conf.GetDuration("timeout", 3*time.Second)
+	ri.block = conf.GetDuration("block", time.Second)
+	ri.dropUndecodable = conf.GetBool("drop_undecodable", true)
+	if ri.block <= 0 {
+		// BLOCK 0 waits forever
+		ri.block = time.Second
+	}
+	channels := conf.GetStringSlice("channels", nil)
+	patterns := conf.GetStringSlice("patterns", nil)
+	if len(ri.streams) == 0 && len(channels) == 0 && len(patterns) == 0 {
+		return fmt.Errorf("inlet.redis streams, channels or patterns is required")
+	}
+
+	format := conf.GetString("format", "json")
+	if engine.GetDecoder(format) == nil {
+		return fmt.Errorf("inlet.redis format %q not found", format)
+	}
+	ri.conf = maps.Clone(conf).Set("format", format)
+
+	client, err := redisConnect(ri.ctx, "inlet.redis")
+	if err != nil {
+		return err
+	}
+	ri.client = client
+	ri.readCtx, ri.readCancel = context.WithCancel(ri.ctx)
+	if len(ri.streams) > 0 {
+		var start string
+		switch s := conf.GetString("start", "latest"); s {
+		case "earliest":
+			start = "0"
+		case "latest":
+			start = "$"
+		default:
+			ri.close()
+			return fmt.Errorf("inlet.redis unknown start %q", s)
+		}
+		for _, stream := range ri.streams {
+			ctx, cancel := context.WithTimeout(ri.ctx, ri.timeout)
+			err := client.XGroupCreateMkStream(ctx, stream, ri.group, start).Err()
+			cancel()
+			// the group exists already
+			if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+				ri.close()
+				return fmt.Errorf("inlet.redis group %q of stream %q, %w", ri.group, stream, err)
+			}
+		}
+		ri.closeWg.Add(1)
+		go ri.readStreams()
+	}
+	if len(channels) > 0 || len(patterns) > 0 {
+		ctx, cancel := context.WithTimeout(ri.ctx, ri.timeout)
+		defer cancel()
+		ri.pubsub = client.Subscribe(ctx)
+		if len(channels) > 0 {
+			err = ri.pubsub.Subscribe(ctx, channels...)
+		}
+		if err == nil && len(patterns) > 0 {
+			err = ri.pubsub.PSubscribe(ctx, patterns...)
+		}
+		// wait for the confirmations, not to miss the messages published right after Open
+		for i := 0; err == nil && i < len(channels)+len(patterns); i++ {
+			_, err = ri.pubsub.Receive(ctx)
+		}
+		if err != nil {
+			ri.close()
+			return fmt.Errorf("inlet.redis subscribe, %w", err)
+		}
+		ri.closeWg.Add(1)
+		go ri.readPubSub()
+	}
+	ri.ctx.LogDebug("inlet.redis", "streams", ri.streams, "group", ri.group, "consumer", ri.consumer,
+		"channels", channels, "patterns", patterns)
+	return nil
+}
+
+func (ri *redisInlet) Close() error {
+	ri.closeOnce.Do(func() {
+		close(ri.closeCh)
+		ri.close()
+		ri.closeWg.Wait()
+	})
+	return nil
+}
+
+func (ri *redisInlet) close() {
+	if ri.readCancel != nil {
+		ri.readCancel()
+	}
+	if ri.pubsub != nil {
+		ri.pubsub.Close()
+	}
+	if ri.client != nil {
+		ri.client.Close()
+	}
+}
+
+func (ri *redisInlet) Process(next engine.InletNextFunc) {
+	for {
+		select {
+		case <-ri.closeCh:
+			return
+		case d := <-ri.pushCh:
+			select {
+			case <-ri.closeCh:
+				// closed while waiting, the messages remain pending in the group
+				return
+			default:
+				if d.ack == nil {
+					next(d.records, nil)
+					continue
+				}
+				ack := d.ack
+				next(engine.WithAck(d.records, func(err error) {
+					// the messages remain pending in the group if an outlet failed,
+					// those are read again when the consumer restarts.
+					if err == nil {
+						ack()
+					}
+				}), nil)
+			}
+		}
+	}
+}
+
+// readStreams reads the messages of the streams by the consumer group.
+// The pending messages of the consumer, those were delivered but not acknowledged before,
+// are read first, then the new messages.
+func (ri *redisInlet) readStreams() {
+	defer ri.closeWg.Done()
+	ids := make([]string, len(ri.streams))
+	for i := range ids {
+		ids[i] = "0"
+	}
+	for {
+		select {
+		case <-ri.closeCh:
+			return
+		default:
+		}
+		res, err := ri.client.XReadGroup(ri.readCtx, &redis.XReadGroupArgs{
+			Group:    ri.group,
+			Consumer: ri.consumer,
+			Streams:  append(append([]string{}, ri.streams...), ids...),
+			Count:    ri.count,
+			Block:    ri.block,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			select {
+			case <-ri.closeCh:
+				return
+			default:
+			}
+			ri.ctx.LogWarn("inlet.redis", "group", ri.group, "error", err.Error())
+			// wait before retrying, the server might be restarting
+			select {
+			case <-ri.closeCh:
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, xs := range res {
+			idx := -1
+			for i, s := range ri.streams {
+				if s == xs.Stream {
+					idx = i
+				}
+			}
+			if idx < 0 {
+				continue
+			}
+			if ids[idx] != ">" {
+				if len(xs.Messages) == 0 {
+					// no more pending messages
+					ids[idx] = ">"
+					continue
+				}
+				ids[idx] = xs.Messages[len(xs.Messages)-1].ID
+			}
+			if !ri.pushStream(xs) {
+				return
+			}
+		}
+	}
+}
+
+// pushStream decodes the messages of the stream and sends the records to the pipeline,
+// it returns false if the inlet is closed.
+// The messages those can not be decoded are acknowledged if drop_undecodable is true,
+// otherwise they remain pending in the group.
+func (ri *redisInlet) pushStream(xs redis.XStream) bool {
+	ret := []engine.Record{}
+	ids := make([]string, 0, len(xs.Messages))
+	for _, msg := range xs.Messages {
+		ids = append(ids, msg.ID)
+		var payload []byte
+		switch v := msg.Values[ri.field].(type) {
+		case string:
+			payload = []byte(v)
+		case []byte:
+			payload = v
+		default:
+			// the message is deleted or has no payload, acknowledge it not to be read again
+			ri.ctx.LogWarn("inlet.redis", "stream", xs.Stream, "id", msg.ID, "no field", ri.field)
+			continue
+		}
+		recs, err := ri.decode(payload)
+		if err != nil {
+			ri.ctx.LogWarn("inlet.redis", "stream", xs.Stream, "id", msg.ID, "drop", ri.dropUndecodable, "error", err.Error())
+			if !ri.dropUndecodable {
+				ids = ids[:len(ids)-1]
+			}
+			continue
+		}
+		for _, r := range recs {
+			r.Tags().Set("stream", engine.NewValue(xs.Stream))
+			r.Tags().Set("id", engine.NewValue(msg.ID))
+		}
+		ret = append(ret, recs...)
+	}
+	ack := func() {
+		if len(ids) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(ri.ctx, ri.timeout)
+		defer cancel()
+		if err := ri.client.XAck(ctx, xs.Stream, ri.group, ids...).Err(); err != nil {
+			ri.ctx.LogWarn("inlet.redis", "stream", xs.Stream, "ack error", err.Error())
+		}
+	}
+	if len(ret) == 0 {
+		ack()
+		return true
+	}
+	select {
+	case <-ri.closeCh:
+		return false
+	case ri.pushCh <- redisData{records: ret, ack: ack}:
+		return true
+	}
+}
+
+// readPubSub receives the messages of the subscribed channels and patterns,
+// the messages published while the inlet is not subscribing are lost.
+func (ri *redisInlet) readPubSub() {
+	defer ri.closeWg.Done()
+	ch := ri.pubsub.Channel()
+	for {
+		select {
+		case <-ri.closeCh:
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			recs, err := ri.decode([]byte(msg.Payload))
+			if err != nil {
+				ri.ctx.LogWarn("inlet.redis", "channel", msg.Channel, "error", err.Error())
+				continue
+			}
+			if len(recs) == 0 {
+				continue
+			}
+			for _, r := range recs {
+				r.Tags().Set("channel", engine.NewValue(msg.Channel))
+				if msg.Pattern != "" {
+					r.Tags().Set("pattern", engine.NewValue(msg.Pattern))
+				}
+			}
+			select {
+			case <-ri.closeCh:
+				return
+			case ri.pushCh <- redisData{records: recs}:
+			}
+		}
+	}
+}
+
+func (ri *redisInlet) decode(payload []byte) ([]engine.Record, error) {
+	reader, err := engine.NewReader(bytes.NewReader(payload), ri.conf)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decode, %w", err)
+		}
+	}
+	return ret, nil
+}
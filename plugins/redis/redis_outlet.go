@@ -0,0 +1,204 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name:    "redis",
+		Factory: RedisOutlet,
+	})
+}
+
+func RedisOutlet(ctx *engine.Context) engine.Outlet {
+	return &redisOutlet{ctx: ctx}
+}
+
+type redisOutlet struct {
+	ctx     *engine.Context
+	conf    engine.Config
+	client  *redis.Client
+	command string
+	timeout time.Duration
+
+	// xadd
+	stream string
+	maxLen int64
+	approx bool
+	field  string
+	// publish
+	channel string
+	// set, hset
+	key      string
+	keyField string
+	ttl      time.Duration
+	vf       engine.ValueFormat
+}
+
+var _ = engine.Outlet((*redisOutlet)(nil))
+
+func (ro *redisOutlet) Open() error {
+	conf := ro.ctx.Config()
+	ro.command = conf.GetString("command", "xadd")
+	ro.timeout = conf.GetDuration("timeout", 3*time.Second)
+	ro.stream = conf.GetString("stream", "")
+	ro.maxLen = conf.GetInt64("maxlen", 0)
+	ro.approx = conf.GetBool("approx", true)
+	ro.field = conf.GetString("field", "payload")
+	ro.channel = conf.GetString("channel", "")
+	ro.key = conf.GetString("key", "")
+	ro.keyField = conf.GetString("key_field", "")
+	ro.ttl = conf.GetDuration("ttl", 0)
+
+	switch ro.command {
+	case "xadd":
+		if ro.stream == "" {
+			return fmt.Errorf("outlet.redis stream is required")
+		}
+	case "publish":
+		if ro.channel == "" {
+			return fmt.Errorf("outlet.redis channel is required")
+		}
+	case "set", "hset":
+		if ro.key == "" && ro.keyField == "" {
+			return fmt.Errorf("outlet.redis key or key_field is required")
+		}
+	default:
+		return fmt.Errorf("outlet.redis unknown command %q", ro.command)
+	}
+
+	// the fields of hset and the value of key_field are formatted as the strings
+	tz := conf.GetString("tz", "Local")
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return fmt.Errorf("outlet.redis invalid timezone %q, %w", tz, err)
+	}
+	ro.vf = engine.ValueFormat{
+		Timeformat: engine.NewTimeformatterWithLocation(conf.GetString("timeformat", "s"), loc),
+		Decimal:    conf.GetInt("decimal", -1),
+	}
+	if ro.command != "hset" {
+		format := conf.GetString("format", "json")
+		if engine.GetEncoder(format) == nil {
+			return fmt.Errorf("outlet.redis format %q not found", format)
+		}
+		ro.conf = maps.Clone(conf).Set("format", format)
+	}
+
+	client, err := redisConnect(ro.ctx, "outlet.redis")
+	if err != nil {
+		return err
+	}
+	ro.client = client
+	ro.ctx.LogDebug("outlet.redis", "command", ro.command, "stream", ro.stream, "channel", ro.channel, "key", ro.key)
+	return nil
+}
+
+func (ro *redisOutlet) Close() error {
+	if ro.client != nil {
+		ro.client.Close()
+	}
+	return nil
+}
+
+// Handle sends a command for each record in a pipeline, and waits for the replies.
+func (ro *redisOutlet) Handle(recs []engine.Record) error {
+	ctx, cancel := context.WithTimeout(ro.ctx, ro.timeout)
+	defer cancel()
+	pipe := ro.client.Pipeline()
+	for _, r := range recs {
+		if r.Empty() {
+			continue
+		}
+		if ro.command == "hset" {
+			key := ro.recordKey(r)
+			if key == "" {
+				ro.ctx.LogWarn("outlet.redis", "no key_field", ro.keyField)
+				continue
+			}
+			values := []any{}
+			for _, f := range r.Fields() {
+				if f == nil || f.IsNull() {
+					continue
+				}
+				if b, ok := f.Value.Raw().([]byte); ok {
+					values = append(values, f.Name, b)
+				} else {
+					values = append(values, f.Name, f.Value.Format(ro.vf))
+				}
+			}
+			if len(values) == 0 {
+				continue
+			}
+			pipe.HSet(ctx, key, values...)
+			if ro.ttl > 0 {
+				pipe.Expire(ctx, key, ro.ttl)
+			}
+			continue
+		}
+
+		payload, err := ro.encode(r)
+		if err != nil {
+			return err
+		}
+		switch ro.command {
+		case "xadd":
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: ro.stream,
+				MaxLen: ro.maxLen,
+				Approx: ro.approx,
+				Values: []any{ro.field, payload},
+			})
+		case "publish":
+			pipe.Publish(ctx, ro.channel, payload)
+		case "set":
+			key := ro.recordKey(r)
+			if key == "" {
+				ro.ctx.LogWarn("outlet.redis", "no key_field", ro.keyField)
+				continue
+			}
+			pipe.Set(ctx, key, payload, ro.ttl)
+		}
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("outlet.redis %s, %w", ro.command, err)
+	}
+	return nil
+}
+
+func (ro *redisOutlet) encode(r engine.Record) ([]byte, error) {
+	data := &bytes.Buffer{}
+	w, err := engine.NewWriter(data, ro.conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Write([]engine.Record{r}); err != nil {
+		return nil, err
+	}
+	w.Close()
+	return bytes.TrimSuffix(data.Bytes(), []byte("\n")), nil
+}
+
+// recordKey returns the key of the record, the value of key_field is appended to the key,
+// it returns empty string if the record does not have key_field.
+func (ro *redisOutlet) recordKey(r engine.Record) string {
+	if ro.keyField == "" {
+		return ro.key
+	}
+	f := r.Field(ro.keyField)
+	if f == nil || f.IsNull() {
+		return ""
+	}
+	return ro.key + f.Value.Format(ro.vf)
+}
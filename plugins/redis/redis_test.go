@@ -0,0 +1,245 @@
+package redis_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/redis"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+// waitFor waits until the output has the expected length
+func waitFor(out *syncBuffer, expect string) {
+	for i := 0; i < 100 && len(out.String()) < len(expect); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRedisStream(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	outDsl := fmt.Sprintf(`
+		[[inlets.file]]
+			data = ["a,1", "b,2", "c,3"]
+			format = "csv"
+			fields = ["name", "value"]
+			types = ["string", "int"]
+		[[outlets.redis]]
+			address = %q
+			command = "xadd"
+			stream = "events"
+			maxlen = 2
+			approx = false
+		`, mr.Addr())
+	outPipeline, err := engine.New(engine.WithConfig(outDsl))
+	require.NoError(t, err)
+	require.NoError(t, outPipeline.Run())
+
+	// trimmed by maxlen
+	entries, err := mr.Stream("events")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, []string{"payload", `{"name":"b","value":2}`}, entries[0].Values)
+
+	inDsl := fmt.Sprintf(`
+		[[inlets.redis]]
+			address = %q
+			streams = ["events"]
+			group = "workers"
+			start = "earliest"
+			block = "100ms"
+		[[flows.select]]
+			includes = ["#stream", "*"]
+		[[outlets.file]]
+			format = "json"
+		`, mr.Addr())
+	out := &syncBuffer{}
+	inPipeline, err := engine.New(engine.WithConfig(inDsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go inPipeline.Run()
+
+	expect := `{"name":"b","stream":"events","value":2}` + "\n" +
+		`{"name":"c","stream":"events","value":3}` + "\n"
+	waitFor(out, expect)
+	require.Equal(t, expect, out.String())
+
+	// the messages should be acknowledged
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	var pending *redis.XPending
+	for i := 0; i < 100; i++ {
+		if pending, err = client.XPending(ctx, "events", "workers").Result(); err == nil && pending.Count == 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	require.Equal(t, int64(0), pending.Count)
+	inPipeline.Stop()
+}
+
+func TestRedisStreamUndecodable(t *testing.T) {
+	tests := []struct {
+		drop    bool
+		pending int64
+	}{
+		{drop: true, pending: 0},
+		{drop: false, pending: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("drop_%v", tt.drop), func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer client.Close()
+			ctx := context.Background()
+			for _, v := range []string{`{"name":"a"}`, `not json`, `{"name":"b"}`} {
+				require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{Stream: "events", Values: []any{"payload", v}}).Err())
+			}
+
+			inDsl := fmt.Sprintf(`
+				[[inlets.redis]]
+					address = %q
+					streams = ["events"]
+					start = "earliest"
+					block = "100ms"
+					drop_undecodable = %v
+				[[outlets.file]]
+					format = "json"
+				`, mr.Addr(), tt.drop)
+			out := &syncBuffer{}
+			inPipeline, err := engine.New(engine.WithConfig(inDsl), engine.WithWriter(out))
+			require.NoError(t, err)
+			go inPipeline.Run()
+
+			expect := `{"name":"a"}` + "\n" + `{"name":"b"}` + "\n"
+			waitFor(out, expect)
+			require.Equal(t, expect, out.String())
+
+			// the message that can not be decoded remains pending if it is not dropped
+			var pending *redis.XPending
+			for i := 0; i < 100; i++ {
+				if pending, err = client.XPending(ctx, "events", "tine").Result(); err == nil && pending.Count == tt.pending {
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.pending, pending.Count)
+			inPipeline.Stop()
+		})
+	}
+}
+
+func TestRedisPubSub(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	inDsl := fmt.Sprintf(`
+		[[inlets.redis]]
+			address = %q
+			channels = ["metrics"]
+			patterns = ["alerts.*"]
+		[[flows.select]]
+			includes = ["#channel", "#pattern", "*"]
+		[[outlets.file]]
+			format = "json"
+		`, mr.Addr())
+	out := &syncBuffer{}
+	inPipeline, err := engine.New(engine.WithConfig(inDsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go inPipeline.Run()
+	for i := 0; i < 100 && (mr.PubSubNumSub("metrics")["metrics"] == 0 || mr.PubSubNumPat() == 0); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	outDsl := fmt.Sprintf(`
+		[[inlets.file]]
+			data = ["a,1"]
+			format = "csv"
+			fields = ["name", "value"]
+			types = ["string", "int"]
+		[[outlets.redis]]
+			address = %q
+			command = "publish"
+			channel = "metrics"
+		[[outlets.redis]]
+			address = %q
+			command = "publish"
+			channel = "alerts.disk"
+		`, mr.Addr(), mr.Addr())
+	outPipeline, err := engine.New(engine.WithConfig(outDsl))
+	require.NoError(t, err)
+	require.NoError(t, outPipeline.Run())
+
+	expect1 := `{"channel":"metrics","name":"a","pattern":null,"value":1}` + "\n"
+	expect2 := `{"channel":"alerts.disk","name":"a","pattern":"alerts.*","value":1}` + "\n"
+	waitFor(out, expect1+expect2)
+	inPipeline.Stop()
+	// the order of the outlets is not deterministic
+	require.Contains(t, out.String(), expect1)
+	require.Contains(t, out.String(), expect2)
+	require.Len(t, out.String(), len(expect1+expect2))
+}
+
+func TestRedisKeys(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	dsl := fmt.Sprintf(`
+		[[inlets.file]]
+			data = ["a,1", "b,2"]
+			format = "csv"
+			fields = ["name", "value"]
+			types = ["string", "int"]
+		[[outlets.redis]]
+			address = %q
+			command = "set"
+			key = "metric:"
+			key_field = "name"
+			ttl = "1m"
+		[[outlets.redis]]
+			address = %q
+			command = "hset"
+			key = "hash:"
+			key_field = "name"
+		`, mr.Addr(), mr.Addr())
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	require.NoError(t, err)
+	require.NoError(t, pipeline.Run())
+
+	v, err := mr.Get("metric:a")
+	require.NoError(t, err)
+	require.Equal(t, `{"name":"a","value":1}`, v)
+	require.Equal(t, time.Minute, mr.TTL("metric:a"))
+	v, err = mr.Get("metric:b")
+	require.NoError(t, err)
+	require.Equal(t, `{"name":"b","value":2}`, v)
+
+	require.Equal(t, "b", mr.HGet("hash:b", "name"))
+	require.Equal(t, "2", mr.HGet("hash:b", "value"))
+	require.Equal(t, time.Duration(0), mr.TTL("hash:b"))
+}
@@ -13,6 +13,7 @@ type Conn interface {
 	Host() string
 	Walk(string, gosnmp.WalkFunc) error
 	Get(oids []string) (*gosnmp.SnmpPacket, error)
+	Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error)
 	Reconnect() error
 }
 
@@ -44,6 +45,10 @@ func (gs GosnmpWrapper) Get(oids []string) (*gosnmp.SnmpPacket, error) {
 	return gs.GoSNMP.Get(oids)
 }
 
+func (gs GosnmpWrapper) Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error) {
+	return gs.GoSNMP.Set(pdus)
+}
+
 // SetAgent sets the target agent for the connection.
 // sheme://host:port
 func (gs GosnmpWrapper) SetAgent(agent string) error {
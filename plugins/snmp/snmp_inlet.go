@@ -3,6 +3,7 @@ package snmp
 import (
 	"fmt"
 	"io"
+	"slices"
 	"sync"
 	"time"
 
@@ -29,6 +30,7 @@ type snmpInlet struct {
 	name            string
 	nameInfix       string
 	agents          []string
+	agentTags       []engine.Tags
 	tables          []Table
 	fields          []Field
 	tags            engine.Tags
@@ -46,48 +48,42 @@ func (si *snmpInlet) Open() error {
 	si.nameInfix = conf.GetString("name_infix", "_")
 	si.tags = engine.Tags{}
 
-	si.clientConf.Timeout = conf.GetDuration("timeout", 5*time.Second)
-	si.clientConf.Retries = conf.GetInt("retries", 3)
-	si.clientConf.Version = conf.GetInt("version", 2)
-	si.clientConf.UseUnconnectedUDPSocket = conf.GetBool("unconnected_udp_socket", false)
-	// SNMPv1 and SNMPv2
-	si.clientConf.Community = conf.GetString("community", "public")
-	// SNMPv2 and SNMPv3
-	si.clientConf.MaxRepetitions = conf.GetUint32("max_repetitions", 10)
-	// SNMPv3
-	si.clientConf.ContextName = conf.GetString("context_name", "")
-	si.clientConf.SecLevel = conf.GetString("sec_level", "authNoPriv")
-	si.clientConf.SecName = conf.GetString("sec_name", "username")
-	si.clientConf.AuthProtocol = conf.GetString("auth_protocol", "MD5")
-	si.clientConf.AuthPassword = conf.GetString("auth_password", "password")
-	si.clientConf.PrivProtocol = conf.GetString("priv_protocol", "")
-	si.clientConf.PrivPassword = conf.GetString("priv_password", "")
-	si.clientConf.EngineID = conf.GetString("engine_id", "")
-	si.clientConf.EngineBoots = conf.GetUint32("engine_boots", 0)
-	si.clientConf.EngineTime = conf.GetUint32("engine_time", 0)
+	si.clientConf = newClientConfig(conf)
 
 	si.agents = conf.GetStringSlice("agents", nil)
 	if len(si.agents) == 0 {
 		return fmt.Errorf("no SNMP agent specified")
 	}
 
-	mibPath := conf.GetStringSlice("mib_paths", []string{})
-	mibTranslator := conf.GetString("translator", "gosmi")
-	switch mibTranslator {
-	case "gosmi":
-		if trans, err := NewGosmiTranslator(mibPath); err != nil {
-			return err
-		} else {
-			si.translator = trans
-		}
-	case "netsnmp":
-		si.translator = NewNetsnmpTranslator()
-	default:
-		return fmt.Errorf("invalid translator %q", mibTranslator)
+	if trans, err := newTranslator(conf); err != nil {
+		return err
+	} else {
+		si.translator = trans
 	}
 
 	si.connectionCache = make([]Conn, len(si.agents))
 
+	// static tags of each agent, e.g. { agent = "udp://10.0.0.1:161", site = "seoul" }
+	si.agentTags = make([]engine.Tags, len(si.agents))
+	for _, c := range conf.GetConfigSlice("agent_tags", nil) {
+		agent := c.GetString("agent", "")
+		idx := slices.Index(si.agents, agent)
+		if idx < 0 {
+			return fmt.Errorf("agent_tags, unknown agent %q", agent)
+		}
+		if si.agentTags[idx] == nil {
+			si.agentTags[idx] = engine.Tags{}
+		}
+		for k := range c {
+			if k == "agent" {
+				continue
+			}
+			if value := c.GetValue(k); value.IsNotNull() {
+				si.agentTags[idx][k] = value
+			}
+		}
+	}
+
 	tables := conf.GetConfigSlice("tables", nil)
 	for _, c := range tables {
 		t := Table{}
@@ -95,6 +91,11 @@ func (si *snmpInlet) Open() error {
 		t.Oid = c.GetString("oid", "")
 		t.InheritTags = c.GetStringSlice("inherit_tags", nil)
 		t.IndexAsTag = c.GetBool("index_as_tag", false)
+		t.Discover = c.GetBool("discover", false)
+		t.DiscoverInterval = c.GetDuration("discover_interval", 10*time.Minute)
+		t.JoinIndex = c.GetString("join_index", "")
+		t.JoinIndexAsTag = c.GetBool("join_index_as_tag", true)
+		t.JoinTags = c.GetStringSlice("join_tags", nil)
 		si.tables = append(si.tables, t)
 	}
 	for i := range si.tables {
@@ -177,6 +178,7 @@ func (si *snmpInlet) Gather() ([]engine.Record, error) {
 			if recs, err := si.gatherTable(gs, t, false); err != nil {
 				si.ctx.LogWarn("inlets.snmp", "gathering table", si.name, "error", err)
 			} else {
+				si.setTags(idx, recs)
 				resultLock.Lock()
 				result = append(result, recs...)
 				resultLock.Unlock()
@@ -186,6 +188,7 @@ func (si *snmpInlet) Gather() ([]engine.Record, error) {
 				if recs, err := si.gatherTable(gs, table, true); err != nil {
 					si.ctx.LogWarn("inlets.snmp", "gathering table", table.Name, "error", err)
 				} else {
+					si.setTags(idx, recs)
 					resultLock.Lock()
 					result = append(result, recs...)
 					resultLock.Unlock()
@@ -217,6 +220,16 @@ func (si *snmpInlet) gatherTable(gs Conn, table Table, walk bool) ([]engine.Reco
 					rec.Tags().Set(k, engine.NewValue(v))
 				}
 			}
+			if table.JoinIndexAsTag && table.joinIndexName != "" {
+				if v, ok := tr.Tags[table.joinIndexName]; ok {
+					rec.Tags().Set(table.joinIndexName, engine.NewValue(v))
+				}
+			}
+			for _, k := range table.joinTagNames {
+				if v, ok := tr.Tags[k]; ok {
+					rec.Tags().Set(k, engine.NewValue(v))
+				}
+			}
 		}
 
 		for name, value := range tr.Fields {
@@ -227,26 +240,12 @@ func (si *snmpInlet) gatherTable(gs Conn, table Table, walk bool) ([]engine.Reco
 				si.ctx.LogDebug("snmp ifIndex not found", "tags", tr.Tags, "fields", tr.Fields)
 			}
 			recName = recName + si.nameInfix + name
-			switch v := value.(type) {
-			case string:
-				rec.Append(engine.NewField(recName, v))
-			case float64:
-				rec.Append(engine.NewField(recName, v))
-			case int64:
-				rec.Append(engine.NewField(recName, v))
-			case bool:
-				rec.Append(engine.NewField(recName, v))
-			case float32:
-				rec.Append(engine.NewField(recName, float64(v)))
-			case int:
-				rec.Append(engine.NewField(recName, int64(v)))
-			case uint32:
-				rec.Append(engine.NewField(recName, uint64(v)))
-			case uint:
-				rec.Append(engine.NewField(recName, uint64(v)))
-			default:
-				si.ctx.LogWarn("inlet_snmp drop record", "name", recName, "type", fmt.Sprintf("%T", v), "value", fmt.Sprintf("%v", v))
+			if f := newField(recName, value); f != nil {
+				rec.Append(f)
+			} else {
+				si.ctx.LogWarn("inlet_snmp drop record", "name", recName, "type", fmt.Sprintf("%T", value), "value", fmt.Sprintf("%v", value))
 			}
+
 		}
 		if len(rec.Names()) > 0 {
 			ret = append(ret, rec)
@@ -255,6 +254,18 @@ func (si *snmpInlet) gatherTable(gs Conn, table Table, walk bool) ([]engine.Reco
 	return ret, nil
 }
 
+// setTags sets the tags of the inlet and the tags of the agent to the records
+func (si *snmpInlet) setTags(idx int, recs []engine.Record) {
+	for _, rec := range recs {
+		for k, v := range si.tags {
+			rec.Tags().Set(k, v)
+		}
+		for k, v := range si.agentTags[idx] {
+			rec.Tags().Set(k, v)
+		}
+	}
+}
+
 func (si *snmpInlet) getConnection(idx int) (Conn, error) {
 	if gs := si.connectionCache[idx]; gs != nil {
 		if err := gs.Reconnect(); err != nil {
@@ -280,6 +291,72 @@ func (si *snmpInlet) getConnection(idx int) (Conn, error) {
 	return gs, nil
 }
 
+// newField returns the field of the converted SNMP value,
+// it returns nil if the type of the value is not supported.
+func newField(name string, value any) *engine.Field {
+	switch v := value.(type) {
+	case string:
+		return engine.NewField(name, v)
+	case float64:
+		return engine.NewField(name, v)
+	case int64:
+		return engine.NewField(name, v)
+	case uint64:
+		return engine.NewField(name, v)
+	case bool:
+		return engine.NewField(name, v)
+	case float32:
+		return engine.NewField(name, float64(v))
+	case int:
+		return engine.NewField(name, int64(v))
+	case uint32:
+		return engine.NewField(name, uint64(v))
+	case uint:
+		return engine.NewField(name, uint64(v))
+	default:
+		return nil
+	}
+}
+
+// newClientConfig returns the client configuration of the connections to the agents
+func newClientConfig(conf engine.Config) ClientConfig {
+	cc := ClientConfig{}
+	cc.Timeout = conf.GetDuration("timeout", 5*time.Second)
+	cc.Retries = conf.GetInt("retries", 3)
+	cc.Version = conf.GetInt("version", 2)
+	cc.UseUnconnectedUDPSocket = conf.GetBool("unconnected_udp_socket", false)
+	// SNMPv1 and SNMPv2
+	cc.Community = conf.GetString("community", "public")
+	// SNMPv2 and SNMPv3
+	cc.MaxRepetitions = conf.GetUint32("max_repetitions", 10)
+	// SNMPv3
+	cc.ContextName = conf.GetString("context_name", "")
+	cc.SecLevel = conf.GetString("sec_level", "authNoPriv")
+	cc.SecName = conf.GetString("sec_name", "username")
+	cc.AuthProtocol = conf.GetString("auth_protocol", "MD5")
+	cc.AuthPassword = conf.GetString("auth_password", "password")
+	cc.PrivProtocol = conf.GetString("priv_protocol", "")
+	cc.PrivPassword = conf.GetString("priv_password", "")
+	cc.EngineID = conf.GetString("engine_id", "")
+	cc.EngineBoots = conf.GetUint32("engine_boots", 0)
+	cc.EngineTime = conf.GetUint32("engine_time", 0)
+	return cc
+}
+
+// newTranslator returns the MIB translator that is specified by "translator" and "mib_paths"
+func newTranslator(conf engine.Config) (Translator, error) {
+	mibPath := conf.GetStringSlice("mib_paths", []string{})
+	mibTranslator := conf.GetString("translator", "gosmi")
+	switch mibTranslator {
+	case "gosmi":
+		return NewGosmiTranslator(mibPath)
+	case "netsnmp":
+		return NewNetsnmpTranslator(), nil
+	default:
+		return nil, fmt.Errorf("invalid translator %q", mibTranslator)
+	}
+}
+
 type ClientConfig struct {
 	Timeout                 time.Duration
 	Retries                 int
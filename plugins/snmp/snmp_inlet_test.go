@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -39,7 +40,7 @@ func TestMain(m *testing.M) {
 		SubAgents: []*GoSNMPServer.SubAgent{
 			{
 				CommunityIDs: []string{"public"},
-				OIDs:         mibImps.All(),
+				OIDs:         append(mibImps.All(), testWritableOIDs()...),
 			},
 		},
 	}
@@ -886,3 +887,171 @@ func TestGatherSysUptime(t *testing.T) {
 		}
 	}
 }
+
+func TestGatherDiscoverJoin(t *testing.T) {
+	testDataPath, err := filepath.Abs("./testdata/gosmi")
+	require.NoError(t, err)
+
+	inlet := SnmpInlet(DummyContext(
+		engine.NewConfig().
+			Set("agents", []string{"udp://10.0.0.1:161", "udp://10.0.0.2:161"}).
+			Set("translator", "gosmi").
+			Set("mib_paths", testDataPath).
+			Set("tags", []map[string]any{{"name": "env", "value": "test"}}).
+			Set("agent_tags", []map[string]any{
+				{"agent": "udp://10.0.0.2:161", "site": "seoul", "rack": int64(7)},
+			}).
+			Set("tables", []map[string]any{
+				{
+					"oid":        "RFC1213-MIB::atTable",
+					"discover":   true,
+					"join_index": "RFC1213-MIB::atIfIndex",
+					"join_tags":  []string{"RFC1213-MIB::ifDescr"},
+				},
+			}),
+	))
+	require.NoError(t, inlet.Open())
+	s := inlet.(*snmpInlet)
+	require.Equal(t, []string{"ifDescr"}, s.tables[0].joinTagNames)
+	require.Equal(t, ".1.3.6.1.2.1.3.1", s.tables[0].oidNum)
+
+	conn := &testSNMPConnection{
+		host: "tsc",
+		values: map[string]any{
+			// atIfIndex
+			".1.3.6.1.2.1.3.1.1.1.1.10.0.0.1": 1,
+			".1.3.6.1.2.1.3.1.1.1.2.10.0.0.2": 2,
+			// atPhysAddress
+			".1.3.6.1.2.1.3.1.1.2.1.10.0.0.1": []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+			".1.3.6.1.2.1.3.1.1.2.2.10.0.0.2": []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x66},
+			// the column that is not in the MIB
+			".1.3.6.1.2.1.3.1.1.9.1.10.0.0.1": 100,
+			".1.3.6.1.2.1.3.1.1.9.2.10.0.0.2": 200,
+			// ifDescr
+			".1.3.6.1.2.1.2.2.1.2.1": []byte("eth0"),
+			".1.3.6.1.2.1.2.2.1.2.2": []byte("eth1"),
+		},
+	}
+	s.connectionCache = []Conn{conn, conn}
+
+	recs, err := s.Gather()
+	require.NoError(t, err)
+	require.Len(t, recs, 4)
+
+	result := map[string]map[string]any{}
+	for _, r := range recs {
+		m := map[string]any{}
+		for _, f := range r.Fields() {
+			m[f.Name] = f.Value.Raw()
+		}
+		for k, v := range r.Tags() {
+			m["#"+k] = v.Raw()
+		}
+		key, _ := r.Tags()["ifDescr"].String()
+		if site := r.Tags()["site"]; site != nil {
+			key += "@seoul"
+		}
+		result[key] = m
+	}
+	require.Equal(t, map[string]map[string]any{
+		"eth0": {
+			"atTable_atPhysAddress": "00:11:22:33:44:55",
+			"atTable_atEntry.9":     int64(100),
+			"#ifDescr":              "eth0",
+			"#atIfIndex":            "1",
+			"#env":                  "test",
+		},
+		"eth1": {
+			"atTable_atPhysAddress": "00:11:22:33:44:66",
+			"atTable_atEntry.9":     int64(200),
+			"#ifDescr":              "eth1",
+			"#atIfIndex":            "2",
+			"#env":                  "test",
+		},
+		"eth0@seoul": {
+			"atTable_atPhysAddress": "00:11:22:33:44:55",
+			"atTable_atEntry.9":     int64(100),
+			"#ifDescr":              "eth0",
+			"#atIfIndex":            "1",
+			"#env":                  "test",
+			"#site":                 "seoul",
+			"#rack":                 int64(7),
+		},
+		"eth1@seoul": {
+			"atTable_atPhysAddress": "00:11:22:33:44:66",
+			"atTable_atEntry.9":     int64(200),
+			"#ifDescr":              "eth1",
+			"#atIfIndex":            "2",
+			"#env":                  "test",
+			"#site":                 "seoul",
+			"#rack":                 int64(7),
+		},
+	}, result)
+}
+
+func TestGatherDiscoverInterval(t *testing.T) {
+	testDataPath, err := filepath.Abs("./testdata/gosmi")
+	require.NoError(t, err)
+
+	inlet := SnmpInlet(DummyContext(
+		engine.NewConfig().
+			Set("agents", []string{"udp://10.0.0.1:161"}).
+			Set("translator", "gosmi").
+			Set("mib_paths", testDataPath).
+			Set("tables", []map[string]any{
+				{
+					"oid":               "RFC1213-MIB::atTable",
+					"discover":          true,
+					"join_index":        "RFC1213-MIB::atIfIndex",
+					"join_index_as_tag": false,
+				},
+			}),
+	))
+	require.NoError(t, inlet.Open())
+	s := inlet.(*snmpInlet)
+	require.Equal(t, 10*time.Minute, s.tables[0].DiscoverInterval)
+
+	conn := &testSNMPConnection{
+		host: "tsc",
+		values: map[string]any{
+			".1.3.6.1.2.1.3.1.1.1.1.10.0.0.1": 1,
+			".1.3.6.1.2.1.3.1.1.9.1.10.0.0.1": 100,
+		},
+	}
+	s.connectionCache = []Conn{conn}
+
+	fieldNames := func() []string {
+		recs, err := s.Gather()
+		require.NoError(t, err)
+		require.Len(t, recs, 1)
+		require.Nil(t, recs[0].Tags()["atIfIndex"])
+		names := []string{}
+		for _, f := range recs[0].Fields() {
+			names = append(names, f.Name)
+		}
+		slices.Sort(names)
+		return names
+	}
+	require.Equal(t, []string{"atTable_atEntry.9"}, fieldNames())
+
+	// the column added after the discovery is not gathered until the interval passes
+	conn.values[".1.3.6.1.2.1.3.1.1.8.1.10.0.0.1"] = 80
+	require.Equal(t, []string{"atTable_atEntry.9"}, fieldNames())
+
+	s.tables[0].DiscoverInterval = 0
+	require.Equal(t, []string{"atTable_atEntry.8", "atTable_atEntry.9"}, fieldNames())
+}
+
+func TestSnmpAgentTags_unknownAgent(t *testing.T) {
+	inlet := SnmpInlet(DummyContext(
+		engine.NewConfig().
+			Set("agents", []string{"udp://10.0.0.1:161"}).
+			Set("agent_tags", []map[string]any{{"agent": "udp://10.0.0.9:161", "site": "seoul"}}),
+	))
+	require.Error(t, inlet.Open())
+}
+
+func TestTableDiscover_noOid(t *testing.T) {
+	tbl := Table{Name: "noOid", Discover: true}
+	require.Error(t, tbl.init(NewNetsnmpTranslator()))
+}
@@ -0,0 +1,292 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/gosnmp/gosnmp"
+)
+
+func init() {
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name:    "snmp_set",
+		Factory: SnmpSetOutlet,
+	})
+}
+
+func SnmpSetOutlet(ctx *engine.Context) engine.Outlet {
+	return &snmpSetOutlet{ctx: ctx}
+}
+
+type snmpSetOutlet struct {
+	ctx             *engine.Context
+	clientConf      ClientConfig
+	agents          []string
+	fields          []setField
+	connectionCache []Conn
+	translator      Translator
+	failOnError     bool
+}
+
+var _ = engine.Outlet((*snmpSetOutlet)(nil))
+
+// setField maps the field of the record to the OID to set
+type setField struct {
+	// name of the record field that has the value
+	name string
+	// numeric OID
+	oid string
+	// name of the record field that has the index appended to the OID, e.g. ifIndex
+	indexField string
+	// type hint, UnknownType if it is decided by the value
+	typ gosnmp.Asn1BER
+}
+
+var setTypes = map[string]gosnmp.Asn1BER{
+	"integer":    gosnmp.Integer,
+	"string":     gosnmp.OctetString,
+	"oid":        gosnmp.ObjectIdentifier,
+	"ipaddress":  gosnmp.IPAddress,
+	"counter32":  gosnmp.Counter32,
+	"gauge32":    gosnmp.Gauge32,
+	"timeticks":  gosnmp.TimeTicks,
+	"uinteger32": gosnmp.Uinteger32,
+	"counter64":  gosnmp.Counter64,
+	"float":      gosnmp.OpaqueFloat,
+	"double":     gosnmp.OpaqueDouble,
+}
+
+func (so *snmpSetOutlet) Open() error {
+	conf := so.ctx.Config()
+	so.clientConf = newClientConfig(conf)
+	so.agents = conf.GetStringSlice("agents", nil)
+	so.failOnError = conf.GetBool("fail_on_error", false)
+	if len(so.agents) == 0 {
+		return fmt.Errorf("no SNMP agent specified")
+	}
+	if trans, err := newTranslator(conf); err != nil {
+		return err
+	} else {
+		so.translator = trans
+	}
+	so.connectionCache = make([]Conn, len(so.agents))
+
+	for _, c := range conf.GetConfigSlice("fields", nil) {
+		f := setField{
+			name:       c.GetString("name", ""),
+			indexField: c.GetString("index_field", ""),
+		}
+		if f.name == "" {
+			return fmt.Errorf("outlet.snmp_set field name is required")
+		}
+		if typ := c.GetString("type", ""); typ != "" {
+			if t, ok := setTypes[strings.ToLower(typ)]; ok {
+				f.typ = t
+			} else {
+				return fmt.Errorf("outlet.snmp_set field %s, invalid type %q", f.name, typ)
+			}
+		}
+		oid := c.GetString("oid", "")
+		if oid == "" {
+			return fmt.Errorf("outlet.snmp_set field %s, oid is required", f.name)
+		}
+		oidNum, err := so.translateOid(oid)
+		if err != nil {
+			return fmt.Errorf("outlet.snmp_set field %s, %w", f.name, err)
+		}
+		f.oid = oidNum
+		so.fields = append(so.fields, f)
+	}
+	if len(so.fields) == 0 {
+		return fmt.Errorf("outlet.snmp_set fields are required")
+	}
+	return nil
+}
+
+func (so *snmpSetOutlet) Close() error {
+	for _, gs := range so.connectionCache {
+		if w, ok := gs.(GosnmpWrapper); ok && w.Conn != nil {
+			w.Conn.Close()
+		}
+	}
+	return nil
+}
+
+// Handle sends a SET request of the fields of each record to every agent.
+// A record that can not be converted is skipped with a warning,
+// the failures of the agents do not stop the other records and agents,
+// those are logged and, if fail_on_error is set, returned as a combined error
+// after all records are handled.
+func (so *snmpSetOutlet) Handle(recs []engine.Record) error {
+	var errs []error
+	for i, rec := range recs {
+		pdus, err := so.pdus(rec)
+		if err != nil {
+			so.ctx.LogWarn("outlet.snmp_set", "record", i, "error", err.Error())
+			continue
+		}
+		if len(pdus) == 0 {
+			continue
+		}
+		for idx, agent := range so.agents {
+			if err := so.set(idx, pdus); err != nil {
+				so.ctx.LogWarn("outlet.snmp_set", "agent", agent, "record", i, "error", err.Error())
+				if so.failOnError {
+					errs = append(errs, fmt.Errorf("agent %s, record %d: %w", agent, i, err))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (so *snmpSetOutlet) set(idx int, pdus []gosnmp.SnmpPDU) error {
+	gs, err := so.getConnection(idx)
+	if err != nil {
+		return err
+	}
+	pkt, err := gs.Set(pdus)
+	if err != nil {
+		return err
+	}
+	if pkt.Error != gosnmp.NoError {
+		// error-index starts from 1
+		oid := ""
+		if i := int(pkt.ErrorIndex) - 1; i >= 0 && i < len(pdus) {
+			oid = pdus[i].Name
+		}
+		return fmt.Errorf("%s, oid %q", pkt.Error.String(), oid)
+	}
+	return nil
+}
+
+// pdus returns the variable bindings of the record
+func (so *snmpSetOutlet) pdus(rec engine.Record) ([]gosnmp.SnmpPDU, error) {
+	ret := []gosnmp.SnmpPDU{}
+	for _, f := range so.fields {
+		field := rec.Field(f.name)
+		if field == nil || field.IsNull() {
+			continue
+		}
+		pdu := gosnmp.SnmpPDU{Name: f.oid, Type: f.typ}
+		if f.indexField != "" {
+			idxField := rec.Field(f.indexField)
+			if idxField == nil || idxField.IsNull() {
+				return nil, fmt.Errorf("field %s, index field %s not found", f.name, f.indexField)
+			}
+			idx, _ := idxField.Value.String()
+			pdu.Name += "." + strings.TrimPrefix(idx, ".")
+		}
+		if err := so.setValue(&pdu, field.Value); err != nil {
+			return nil, fmt.Errorf("field %s, %w", f.name, err)
+		}
+		ret = append(ret, pdu)
+	}
+	return ret, nil
+}
+
+// setValue converts the value for the type of the pdu,
+// if the type is not specified, it is decided by the type of the value.
+func (so *snmpSetOutlet) setValue(pdu *gosnmp.SnmpPDU, value *engine.Value) error {
+	if pdu.Type == gosnmp.UnknownType {
+		switch value.Type() {
+		case engine.INT, engine.UINT, engine.BOOL:
+			pdu.Type = gosnmp.Integer
+		case engine.STRING, engine.BINARY:
+			pdu.Type = gosnmp.OctetString
+		default:
+			return fmt.Errorf("type is required for %s value", value.Type())
+		}
+	}
+	var ok = true
+	switch pdu.Type {
+	case gosnmp.Integer:
+		if value.Type() == engine.BOOL {
+			// TruthValue of SNMPv2-TC, true(1) and false(2)
+			var b bool
+			b, ok = value.Bool()
+			pdu.Value = 2
+			if b {
+				pdu.Value = 1
+			}
+			break
+		}
+		var v int64
+		v, ok = value.Int64()
+		pdu.Value = int(v)
+	case gosnmp.OctetString:
+		if value.Type() == engine.BINARY {
+			pdu.Value, ok = value.Bytes()
+		} else {
+			pdu.Value, ok = value.String()
+		}
+	case gosnmp.ObjectIdentifier:
+		var v string
+		if v, ok = value.String(); ok {
+			oid, err := so.translateOid(v)
+			if err != nil {
+				return err
+			}
+			pdu.Value = oid
+		}
+	case gosnmp.IPAddress:
+		pdu.Value, ok = value.String()
+	case gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Uinteger32:
+		var v uint64
+		v, ok = value.Uint64()
+		pdu.Value = uint32(v)
+	case gosnmp.Counter64:
+		pdu.Value, ok = value.Uint64()
+	case gosnmp.OpaqueFloat:
+		var v float64
+		v, ok = value.Float64()
+		pdu.Value = float32(v)
+	case gosnmp.OpaqueDouble:
+		pdu.Value, ok = value.Float64()
+	}
+	if !ok {
+		return fmt.Errorf("can not convert %s value to %s", value.Type(), pdu.Type)
+	}
+	return nil
+}
+
+// translateOid returns the numeric OID of the OID that may have the MIB name, e.g. "IF-MIB::ifAdminStatus.3"
+func (so *snmpSetOutlet) translateOid(oid string) (string, error) {
+	if !strings.ContainsAny(oid, ":abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		if !strings.HasPrefix(oid, ".") {
+			oid = "." + oid
+		}
+		return oid, nil
+	}
+	_, oidNum, _, _, err := so.translator.SnmpTranslate(oid)
+	if err != nil {
+		return "", fmt.Errorf("translating %q: %w", oid, err)
+	}
+	if oidNum == oid {
+		return "", fmt.Errorf("translating %q: not found", oid)
+	}
+	return oidNum, nil
+}
+
+func (so *snmpSetOutlet) getConnection(idx int) (Conn, error) {
+	if gs := so.connectionCache[idx]; gs != nil {
+		if err := gs.Reconnect(); err != nil {
+			return gs, fmt.Errorf("reconnecting: %w", err)
+		}
+		return gs, nil
+	}
+	gs, err := NewWrapper(so.clientConf)
+	if err != nil {
+		return nil, err
+	}
+	if err = gs.SetAgent(so.agents[idx]); err != nil {
+		return nil, err
+	}
+	so.connectionCache[idx] = gs
+	if err := gs.Connect(); err != nil {
+		return gs, fmt.Errorf("set up connecting: %w", err)
+	}
+	return gs, nil
+}
@@ -0,0 +1,169 @@
+package snmp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/gosnmp/gosnmp"
+	"github.com/slayercat/GoSNMPServer"
+	"github.com/stretchr/testify/require"
+)
+
+var testWritten = map[string]any{}
+var testWrittenLock sync.Mutex
+
+// testWritableOIDs returns the writable OIDs of the test agent,
+// the values are stored in testWritten.
+func testWritableOIDs() []*GoSNMPServer.PDUValueControlItem {
+	ret := []*GoSNMPServer.PDUValueControlItem{}
+	for _, idx := range []int{1, 2, 3} {
+		for _, item := range []struct {
+			oid string
+			typ gosnmp.Asn1BER
+		}{
+			{oid: fmt.Sprintf("1.3.6.1.4.1.99999.2.1.%d", idx), typ: gosnmp.Integer},
+			{oid: fmt.Sprintf("1.3.6.1.4.1.99999.2.2.%d", idx), typ: gosnmp.OctetString},
+		} {
+			oid, typ := item.oid, item.typ
+			ret = append(ret, &GoSNMPServer.PDUValueControlItem{
+				OID:  oid,
+				Type: typ,
+				OnGet: func() (any, error) {
+					testWrittenLock.Lock()
+					defer testWrittenLock.Unlock()
+					if typ == gosnmp.Integer {
+						v, _ := testWritten[oid].(int)
+						return GoSNMPServer.Asn1IntegerWrap(v), nil
+					}
+					v, _ := testWritten[oid].(string)
+					return GoSNMPServer.Asn1OctetStringWrap(v), nil
+				},
+				OnSet: func(value any) error {
+					testWrittenLock.Lock()
+					defer testWrittenLock.Unlock()
+					if typ == gosnmp.Integer {
+						testWritten[oid] = GoSNMPServer.Asn1IntegerUnwrap(value)
+					} else {
+						testWritten[oid] = GoSNMPServer.Asn1OctetStringUnwrap(value)
+					}
+					return nil
+				},
+			})
+		}
+	}
+	return ret
+}
+
+func TestSnmpSetOutlet(t *testing.T) {
+	dsl := fmt.Sprintf(`
+	[[inlets.file]]
+		data = [
+			"1,2,down",
+			"9,1,up",
+			"3,1,up",
+		]
+		format = "csv"
+		fields = ["index", "status", "alias"]
+		types = ["int", "int", "string"]
+	[[outlets.snmp_set]]
+		agents = ["udp://%s"]
+		fields = [
+			{name="status", oid="1.3.6.1.4.1.99999.2.1", index_field="index", type="integer"},
+			{name="alias", oid="1.3.6.1.4.1.99999.2.2", index_field="index"},
+		]
+	`, udpTestAgent)
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	require.NoError(t, err)
+	require.NoError(t, pipeline.Run())
+
+	testWrittenLock.Lock()
+	defer testWrittenLock.Unlock()
+	require.Equal(t, 2, testWritten["1.3.6.1.4.1.99999.2.1.1"])
+	require.Equal(t, "down", testWritten["1.3.6.1.4.1.99999.2.2.1"])
+	// the failure of the second record does not stop the others
+	require.Equal(t, 1, testWritten["1.3.6.1.4.1.99999.2.1.3"])
+	require.Equal(t, "up", testWritten["1.3.6.1.4.1.99999.2.2.3"])
+}
+
+func TestSnmpSetOutletPdus(t *testing.T) {
+	outlet := SnmpSetOutlet(DummyContext(
+		engine.NewConfig().
+			Set("agents", []string{"udp://" + udpTestAgent}).
+			Set("fields", []map[string]any{
+				{"name": "a", "oid": ".1.3.6.1.4.1.99999.3.1.0"},
+				{"name": "b", "oid": "1.3.6.1.4.1.99999.3.2.0", "type": "gauge32"},
+				{"name": "c", "oid": ".1.3.6.1.4.1.99999.3.3", "type": "ipaddress", "index_field": "idx"},
+				{"name": "d", "oid": ".1.3.6.1.4.1.99999.3.4.0", "type": "oid"},
+				{"name": "e", "oid": ".1.3.6.1.4.1.99999.3.5.0", "type": "counter64"},
+			}),
+	))
+	require.NoError(t, outlet.Open())
+	so := outlet.(*snmpSetOutlet)
+
+	pdus, err := so.pdus(engine.NewRecord(
+		engine.NewField("a", "text"),
+		engine.NewField("b", int64(10)),
+		engine.NewField("c", "10.0.0.1"),
+		engine.NewField("idx", int64(7)),
+		engine.NewField("d", "1.3.6.1.4.1.99999"),
+		engine.NewField("e", uint64(1<<40)),
+	))
+	require.NoError(t, err)
+	require.Equal(t, []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.4.1.99999.3.1.0", Type: gosnmp.OctetString, Value: "text"},
+		{Name: ".1.3.6.1.4.1.99999.3.2.0", Type: gosnmp.Gauge32, Value: uint32(10)},
+		{Name: ".1.3.6.1.4.1.99999.3.3.7", Type: gosnmp.IPAddress, Value: "10.0.0.1"},
+		{Name: ".1.3.6.1.4.1.99999.3.4.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.99999"},
+		{Name: ".1.3.6.1.4.1.99999.3.5.0", Type: gosnmp.Counter64, Value: uint64(1 << 40)},
+	}, pdus)
+
+	// the missing fields are not set
+	pdus, err = so.pdus(engine.NewRecord(engine.NewField("a", int64(1))))
+	require.NoError(t, err)
+	require.Equal(t, []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.4.1.99999.3.1.0", Type: gosnmp.Integer, Value: 1},
+	}, pdus)
+
+	// bool is TruthValue, true(1) and false(2)
+	pdus, err = so.pdus(engine.NewRecord(engine.NewField("a", true)))
+	require.NoError(t, err)
+	require.Equal(t, []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.4.1.99999.3.1.0", Type: gosnmp.Integer, Value: 1},
+	}, pdus)
+	pdus, err = so.pdus(engine.NewRecord(engine.NewField("a", false)))
+	require.NoError(t, err)
+	require.Equal(t, []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.4.1.99999.3.1.0", Type: gosnmp.Integer, Value: 2},
+	}, pdus)
+
+	// the index field is required
+	_, err = so.pdus(engine.NewRecord(engine.NewField("c", "10.0.0.1")))
+	require.Error(t, err)
+
+	// float value requires the type
+	_, err = so.pdus(engine.NewRecord(engine.NewField("a", 1.5)))
+	require.Error(t, err)
+
+	// the response error of the agent
+	so.connectionCache = []Conn{&testSNMPConnection{host: "tsc", values: map[string]any{}}}
+	err = so.set(0, []gosnmp.SnmpPDU{{Name: ".1.3.6.1.4.1.99999.3.1.0", Type: gosnmp.Integer, Value: 1}})
+	require.EqualError(t, err, `NoCreation, oid ".1.3.6.1.4.1.99999.3.1.0"`)
+
+	// the failures of the agents are logged only
+	recs := []engine.Record{
+		engine.NewRecord(engine.NewField("a", int64(1))),
+		engine.NewRecord(engine.NewField("b", int64(2))),
+	}
+	require.NoError(t, so.Handle(recs))
+
+	// the failures of the agents are returned after all records are handled
+	so.failOnError = true
+	err = so.Handle(recs)
+	require.EqualError(t, err, strings.Join([]string{
+		`agent udp://` + udpTestAgent + `, record 0: NoCreation, oid ".1.3.6.1.4.1.99999.3.1.0"`,
+		`agent udp://` + udpTestAgent + `, record 1: NoCreation, oid ".1.3.6.1.4.1.99999.3.2.0"`,
+	}, "\n"))
+}
@@ -44,6 +44,22 @@ func (tsc *testSNMPConnection) Walk(oid string, wf gosnmp.WalkFunc) error {
 	return nil
 }
 
+func (tsc *testSNMPConnection) Set(pdus []gosnmp.SnmpPDU) (*gosnmp.SnmpPacket, error) {
+	sp := &gosnmp.SnmpPacket{}
+	for i, pdu := range pdus {
+		if _, ok := tsc.values[pdu.Name]; !ok {
+			sp.Error = gosnmp.NoCreation
+			sp.ErrorIndex = uint8(i + 1)
+			return sp, nil
+		}
+	}
+	for _, pdu := range pdus {
+		tsc.values[pdu.Name] = pdu.Value
+	}
+	sp.Variables = pdus
+	return sp, nil
+}
+
 func (tsc *testSNMPConnection) Reconnect() error {
 	return nil
 }
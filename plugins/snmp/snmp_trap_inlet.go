@@ -0,0 +1,269 @@
+package snmp
+
+import (
+	"container/list"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/gosnmp/gosnmp"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "snmp_trap",
+		Factory: SnmpTrapInlet,
+	})
+}
+
+func SnmpTrapInlet(ctx *engine.Context) engine.Inlet {
+	return &snmpTrapInlet{
+		ctx:     ctx,
+		pushCh:  make(chan []engine.Record),
+		closeCh: make(chan struct{}),
+	}
+}
+
+type snmpTrapInlet struct {
+	ctx        *engine.Context
+	version    int
+	community  string
+	sourceTag  string
+	trapOidTag string
+	translator Translator
+	listener   *gosnmp.TrapListener
+
+	// cache of the translated names of the OIDs
+	names     *oidNameLRU
+	namesLock sync.Mutex
+
+	pushCh    chan []engine.Record
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+var _ = engine.Inlet((*snmpTrapInlet)(nil))
+
+type oidName struct {
+	name       string
+	conversion string
+}
+
+const (
+	// sysUpTime.0
+	oidSysUpTime = ".1.3.6.1.2.1.1.3.0"
+	// snmpTrapOID.0
+	oidSnmpTrapOID = ".1.3.6.1.6.3.1.1.4.1.0"
+	// snmpTraps, the prefix of the generic traps
+	oidSnmpTraps = ".1.3.6.1.6.3.1.1.5"
+)
+
+func (ti *snmpTrapInlet) Open() error {
+	conf := ti.ctx.Config()
+	address := conf.GetString("address", "udp://0.0.0.0:162")
+	ti.community = conf.GetString("community", "")
+	ti.sourceTag = conf.GetString("source_tag", "source")
+	ti.trapOidTag = conf.GetString("trap_oid_tag", "trap_oid")
+	ti.names = newOidNameLRU(conf.GetInt("name_cache_size", 10000))
+
+	protoAddr := strings.SplitN(address, "://", 2)
+	if len(protoAddr) != 2 {
+		return fmt.Errorf("inlet.snmp_trap invalid address %q", address)
+	}
+	if protoAddr[0] != "udp" {
+		return fmt.Errorf("inlet.snmp_trap unsupported protocol: %s in %s", protoAddr[0], address)
+	}
+
+	cc := newClientConfig(conf)
+	// the community of the received traps is checked by the inlet, not by the listener
+	cc.Community = ""
+	ti.version = cc.Version
+	gs, err := NewWrapper(cc)
+	if err != nil {
+		return fmt.Errorf("inlet.snmp_trap %w", err)
+	}
+	if ti.translator, err = newTranslator(conf); err != nil {
+		return fmt.Errorf("inlet.snmp_trap %w", err)
+	}
+
+	ti.listener = gosnmp.NewTrapListener()
+	ti.listener.Params = gs.GoSNMP
+	ti.listener.OnNewTrap = ti.handleTrap
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := ti.listener.Listen(protoAddr[1]); err != nil {
+			errCh <- err
+		}
+	}()
+	select {
+	case <-ti.listener.Listening():
+	case err := <-errCh:
+		return fmt.Errorf("inlet.snmp_trap %w", err)
+	}
+	ti.ctx.LogDebug("inlet.snmp_trap", "address", address, "version", ti.version)
+	return nil
+}
+
+func (ti *snmpTrapInlet) Close() error {
+	ti.closeOnce.Do(func() {
+		close(ti.closeCh)
+		if ti.listener != nil {
+			ti.listener.Close()
+		}
+	})
+	return nil
+}
+
+func (ti *snmpTrapInlet) Process(next engine.InletNextFunc) {
+	for {
+		select {
+		case <-ti.closeCh:
+			return
+		case recs := <-ti.pushCh:
+			select {
+			case <-ti.closeCh:
+				return
+			default:
+				next(recs, nil)
+			}
+		}
+	}
+}
+
+// handleTrap is called by the listener for every trap and inform,
+// the listener responds to the inform after it returns.
+func (ti *snmpTrapInlet) handleTrap(pkt *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+	source := ""
+	if addr != nil {
+		source = addr.IP.String()
+	}
+	// SNMPv3 is not accepted if it is not configured, and vice versa
+	if (pkt.Version == gosnmp.Version3) != (ti.version == 3) {
+		ti.ctx.LogWarn("inlet.snmp_trap", "source", source, "drop version", pkt.Version.String())
+		return
+	}
+	if pkt.Version != gosnmp.Version3 && ti.community != "" && pkt.Community != ti.community {
+		ti.ctx.LogWarn("inlet.snmp_trap", "source", source, "drop community", pkt.Community)
+		return
+	}
+
+	rec := engine.NewRecord()
+	trapOid := ""
+	if pkt.Version == gosnmp.Version1 {
+		trapOid = v1TrapOid(pkt.SnmpTrap)
+		rec = rec.Append(
+			engine.NewField(ti.lookup(oidSysUpTime).name, uint64(pkt.Timestamp)),
+			engine.NewField("agent_address", pkt.AgentAddress),
+		)
+	}
+	for _, pdu := range pkt.Variables {
+		if pdu.Name == oidSnmpTrapOID {
+			if oid, ok := pdu.Value.(string); ok {
+				trapOid = oid
+			}
+			continue
+		}
+		on := ti.lookup(pdu.Name)
+		fd := Field{Name: on.name, Oid: pdu.Name, Conversion: on.conversion, translator: ti.translator}
+		value, err := fd.Convert(pdu)
+		if err != nil {
+			ti.ctx.LogWarn("inlet.snmp_trap", "source", source, "oid", pdu.Name, "error", err.Error())
+			continue
+		}
+		if f := newField(on.name, value); f != nil {
+			rec = rec.Append(f)
+		} else {
+			ti.ctx.LogWarn("inlet.snmp_trap", "source", source, "oid", pdu.Name, "drop type", fmt.Sprintf("%T", value))
+		}
+	}
+	if trapOid != "" {
+		rec = rec.Append(engine.NewField("trap_name", ti.lookup(trapOid).name))
+	}
+	if ti.sourceTag != "" {
+		rec.Tags().Set(ti.sourceTag, engine.NewValue(source))
+	}
+	if ti.trapOidTag != "" && trapOid != "" {
+		rec.Tags().Set(ti.trapOidTag, engine.NewValue(trapOid))
+	}
+
+	select {
+	case <-ti.closeCh:
+	case ti.pushCh <- []engine.Record{rec}:
+	}
+}
+
+// lookup returns the name of the OID translated by the MIB, it caches the result
+func (ti *snmpTrapInlet) lookup(oid string) oidName {
+	ti.namesLock.Lock()
+	defer ti.namesLock.Unlock()
+	if on, ok := ti.names.Get(oid); ok {
+		return on
+	}
+	on := oidName{}
+	on.name, on.conversion = translateName(ti.translator, oid)
+	ti.names.Put(oid, on)
+	return on
+}
+
+// oidNameLRU is a bounded cache of the names of the OIDs
+// that evicts the least recently used OID when it exceeds the limit,
+// the traps may have the OIDs of the indexes that are not bounded.
+type oidNameLRU struct {
+	limit int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type oidNameEntry struct {
+	oid  string
+	name oidName
+}
+
+func newOidNameLRU(limit int) *oidNameLRU {
+	return &oidNameLRU{
+		limit: limit,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *oidNameLRU) Get(oid string) (oidName, bool) {
+	if e, ok := c.items[oid]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*oidNameEntry).name, true
+	}
+	return oidName{}, false
+}
+
+func (c *oidNameLRU) Put(oid string, name oidName) {
+	if e, ok := c.items[oid]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*oidNameEntry).name = name
+		return
+	}
+	c.items[oid] = c.ll.PushFront(&oidNameEntry{oid: oid, name: name})
+	for c.limit > 0 && c.ll.Len() > c.limit {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*oidNameEntry).oid)
+	}
+}
+
+func (c *oidNameLRU) Len() int {
+	return c.ll.Len()
+}
+
+// v1TrapOid returns the trap OID of the SNMPv1 trap as RFC3584 section 3.1 describes
+func v1TrapOid(trap gosnmp.SnmpTrap) string {
+	if trap.GenericTrap >= 0 && trap.GenericTrap < 6 {
+		return fmt.Sprintf("%s.%d", oidSnmpTraps, trap.GenericTrap+1)
+	}
+	enterprise := trap.Enterprise
+	if !strings.HasPrefix(enterprise, ".") {
+		enterprise = "." + enterprise
+	}
+	return fmt.Sprintf("%s.0.%d", enterprise, trap.SpecificTrap)
+}
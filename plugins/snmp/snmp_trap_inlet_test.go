@@ -0,0 +1,187 @@
+package snmp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	buf   bytes.Buffer
+	mutex sync.Mutex
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.buf.String()
+}
+
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// waitUDPListen waits until the port is bound by the inlet
+func waitUDPListen(t *testing.T, port int) {
+	for i := 0; i < 250; i++ {
+		conn, err := net.ListenPacket("udp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			return
+		}
+		conn.Close()
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("port %d is not listening", port)
+}
+
+func TestSnmpTrapInlet(t *testing.T) {
+	mibPath, err := filepath.Abs("./testdata/gosmi")
+	require.NoError(t, err)
+
+	usm := &gosnmp.UsmSecurityParameters{
+		UserName:                 "user",
+		AuthenticationProtocol:   gosnmp.SHA,
+		AuthenticationPassphrase: "auth_password",
+		PrivacyProtocol:          gosnmp.AES,
+		PrivacyPassphrase:        "priv_password",
+		AuthoritativeEngineBoots: 1,
+		AuthoritativeEngineTime:  1,
+		AuthoritativeEngineID:    string([]byte{0x80, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04}),
+	}
+	varbinds := []gosnmp.SnmpPDU{
+		{Name: "1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(1234)},
+		// BRIDGE-MIB::topologyChange
+		{Name: "1.3.6.1.6.3.1.1.4.1.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.2.1.17.0.2"},
+		{Name: "1.3.6.1.2.1.2.2.1.1.3", Type: gosnmp.Integer, Value: 3},
+		{Name: "1.3.6.1.2.1.2.2.1.6.3", Type: gosnmp.OctetString, Value: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+		{Name: "1.3.6.1.4.1.99999.1.0", Type: gosnmp.OctetString, Value: "hello"},
+	}
+	expectV2 := `{"_in":"snmp_trap","_ts":1234,"enterprises.99999.1.0":"hello","ifIndex.3":3,"ifPhysAddress.3":"00:11:22:33:44:55","source":"127.0.0.1","sysUpTime.0":1234,"trap_name":"topologyChange","trap_oid":".1.3.6.1.2.1.17.0.2"}`
+
+	tests := []struct {
+		name   string
+		conf   string
+		client *gosnmp.GoSNMP
+		trap   gosnmp.SnmpTrap
+		expect string
+	}{
+		{
+			name:   "v2c",
+			client: &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "public"},
+			trap:   gosnmp.SnmpTrap{Variables: varbinds},
+			expect: expectV2,
+		},
+		{
+			name:   "v2c_inform",
+			client: &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "public"},
+			trap:   gosnmp.SnmpTrap{Variables: varbinds, IsInform: true},
+			expect: expectV2,
+		},
+		{
+			name: "v3",
+			conf: `
+				version = 3
+				sec_name = "user"
+				sec_level = "authPriv"
+				auth_protocol = "SHA"
+				auth_password = "auth_password"
+				priv_protocol = "AES"
+				priv_password = "priv_password"
+				engine_id = "\u0080\u0000\u0000\u0000\u0001\u0002\u0003\u0004"`,
+			client: &gosnmp.GoSNMP{
+				Version:            gosnmp.Version3,
+				SecurityModel:      gosnmp.UserSecurityModel,
+				MsgFlags:           gosnmp.AuthPriv,
+				SecurityParameters: usm,
+			},
+			trap:   gosnmp.SnmpTrap{Variables: varbinds},
+			expect: expectV2,
+		},
+		{
+			name:   "v1",
+			client: &gosnmp.GoSNMP{Version: gosnmp.Version1, Community: "public"},
+			trap: gosnmp.SnmpTrap{
+				Variables:    varbinds[2:3],
+				Enterprise:   ".1.3.6.1.4.1.99999",
+				AgentAddress: "10.0.0.1",
+				GenericTrap:  2,
+				Timestamp:    1234,
+			},
+			expect: `{"_in":"snmp_trap","_ts":1234,"agent_address":"10.0.0.1","ifIndex.3":3,"source":"127.0.0.1","sysUpTime.0":1234,"trap_name":"snmpModules.1.1.5.3","trap_oid":".1.3.6.1.6.3.1.1.5.3"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := freeUDPPort(t)
+			dsl := fmt.Sprintf(`
+			[[inlets.snmp_trap]]
+				address = "udp://127.0.0.1:%d"
+				mib_paths = [%q]
+				%s
+			[[flows.select]]
+				includes = ["#_in", "#_ts", "#source", "#trap_oid", "*"]
+			[[outlets.file]]
+				format = "json"
+			`, port, mibPath, tt.conf)
+			engine.Now = func() time.Time { return time.Unix(1234, 0) }
+			out := &syncBuffer{}
+			pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+			require.NoError(t, err)
+			go pipeline.Run()
+			waitUDPListen(t, port)
+
+			tt.client.Target = "127.0.0.1"
+			tt.client.Port = uint16(port)
+			tt.client.Timeout = time.Second
+			tt.client.Retries = 1
+			require.NoError(t, tt.client.Connect())
+			defer tt.client.Conn.Close()
+			_, err = tt.client.SendTrap(tt.trap)
+			require.NoError(t, err)
+
+			for i := 0; i < 100 && out.String() == ""; i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+			pipeline.Stop()
+			require.JSONEq(t, tt.expect, out.String())
+		})
+	}
+}
+
+func TestOidNameLRU(t *testing.T) {
+	c := newOidNameLRU(2)
+	c.Put(".1.1", oidName{name: "a"})
+	c.Put(".1.2", oidName{name: "b"})
+	_, ok := c.Get(".1.1")
+	require.True(t, ok)
+	// ".1.2" is the least recently used
+	c.Put(".1.3", oidName{name: "c"})
+	require.Equal(t, 2, c.Len())
+	_, ok = c.Get(".1.2")
+	require.False(t, ok)
+	on, ok := c.Get(".1.1")
+	require.True(t, ok)
+	require.Equal(t, "a", on.name)
+	on, ok = c.Get(".1.3")
+	require.True(t, ok)
+	require.Equal(t, "c", on.name)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gosnmp/gosnmp"
@@ -20,8 +21,66 @@ type Table struct {
 
 	Oid string
 
+	// Discover walks the whole table and adds the columns that are not in Fields,
+	// the columns are named by the MIB, or by the numeric OID if not found.
+	Discover bool
+	// DiscoverInterval is the interval to walk the whole table again,
+	// the columns discovered are reused for each agent until it passes.
+	// Zero discovers the columns on every gather.
+	DiscoverInterval time.Duration
+	// JoinIndex is the column whose value is the index of another table,
+	// e.g. "IP-MIB::ipAdEntIfIndex" for the ifTable.
+	JoinIndex string
+	// JoinIndexAsTag adds the value of JoinIndex to the rows as a tag.
+	JoinIndexAsTag bool
+	// JoinTags are the columns of another table that are added to the rows as tags,
+	// they are joined by JoinIndex, or by the same index if JoinIndex is empty.
+	// e.g. "IF-MIB::ifName"
+	JoinTags []string
+
 	initialized bool
 	translator  Translator
+	// numeric OID of the table with the leading "."
+	oidNum string
+	// name of the field of JoinIndex
+	joinIndexName string
+	// names of the fields of JoinTags
+	joinTagNames []string
+	// columns discovered for each agent, shared by the copies of the table
+	discovered *discoverCache
+}
+
+type discoverCache struct {
+	sync.Mutex
+	agents map[string]discoveredFields
+}
+
+type discoveredFields struct {
+	fields []Field
+	time   time.Time
+}
+
+// get returns a copy of the fields discovered for the agent within the interval.
+func (dc *discoverCache) get(host string, interval time.Duration) ([]Field, bool) {
+	if dc == nil || interval <= 0 {
+		return nil, false
+	}
+	dc.Lock()
+	defer dc.Unlock()
+	df, ok := dc.agents[host]
+	if !ok || time.Since(df.time) >= interval {
+		return nil, false
+	}
+	return append([]Field(nil), df.fields...), true
+}
+
+func (dc *discoverCache) put(host string, fields []Field) {
+	if dc == nil {
+		return
+	}
+	dc.Lock()
+	defer dc.Unlock()
+	dc.agents[host] = discoveredFields{fields: append([]Field(nil), fields...), time: time.Now()}
 }
 
 // RTable is the resulting table built from a Table.
@@ -59,12 +118,21 @@ func (t *Table) init(tr Translator) error {
 		return err
 	}
 
-	secondaryIndexTablePresent := false
 	// initialize all the nested fields
 	for i := range t.Fields {
 		if err := t.Fields[i].Init(t.translator); err != nil {
 			return fmt.Errorf("initializing field %s: %w", t.Fields[i].Name, err)
 		}
+	}
+	if err := t.initJoin(); err != nil {
+		return err
+	}
+	if t.Discover {
+		t.discovered = &discoverCache{agents: map[string]discoveredFields{}}
+	}
+
+	secondaryIndexTablePresent := false
+	for i := range t.Fields {
 		if t.Fields[i].SecondaryIndexTable {
 			if secondaryIndexTablePresent {
 				return errors.New("only one field can be SecondaryIndexTable")
@@ -79,12 +147,16 @@ func (t *Table) init(tr Translator) error {
 
 func (t *Table) initBuild() error {
 	if t.Oid == "" {
+		if t.Discover {
+			return errors.New("discover requires the oid of the table")
+		}
 		return nil
 	}
-	_, _, oidText, fields, err := t.translator.SnmpTable(t.Oid)
+	_, oidNum, oidText, fields, err := t.translator.SnmpTable(t.Oid)
 	if err != nil {
 		return err
 	}
+	t.oidNum = dotOid(oidNum)
 	if t.Name == "" {
 		t.Name = oidText
 	}
@@ -100,9 +172,101 @@ func (t *Table) initBuild() error {
 	return nil
 }
 
+// initJoin adds the fields of JoinIndex and JoinTags
+func (t *Table) initJoin() error {
+	if t.JoinIndex != "" {
+		idx := Field{Oid: t.JoinIndex, IsTag: true}
+		if err := idx.Init(t.translator); err != nil {
+			return fmt.Errorf("initializing join index %s: %w", t.JoinIndex, err)
+		}
+		found := false
+		for i := range t.Fields {
+			if dotOid(t.Fields[i].Oid) == dotOid(idx.Oid) {
+				t.Fields[i].SecondaryIndexTable = true
+				t.joinIndexName = t.Fields[i].Name
+				found = true
+				break
+			}
+		}
+		if !found {
+			idx.SecondaryIndexTable = true
+			t.Fields = append(t.Fields, idx)
+			t.joinIndexName = idx.Name
+		}
+	}
+	for _, tag := range t.JoinTags {
+		f := Field{Oid: tag, IsTag: true, SecondaryIndexUse: t.JoinIndex != ""}
+		if err := f.Init(t.translator); err != nil {
+			return fmt.Errorf("initializing join tag %s: %w", tag, err)
+		}
+		t.Fields = append(t.Fields, f)
+		t.joinTagNames = append(t.joinTagNames, f.Name)
+	}
+	return nil
+}
+
+// discover walks the table and adds the fields of the columns that are not configured,
+// it returns the connection that replays the walked values for the fields of the table.
+func (t *Table) discover(gs Conn) (Conn, error) {
+	prefix := t.oidNum + ".1."
+	pdus := []gosnmp.SnmpPDU{}
+	err := gs.Walk(t.oidNum, func(ent gosnmp.SnmpPDU) error {
+		if !strings.HasPrefix(ent.Name, prefix) {
+			return &walkError{} // break the walk
+		}
+		pdus = append(pdus, ent)
+		return nil
+	})
+	if err != nil {
+		var walkErr *walkError
+		if !errors.As(err, &walkErr) {
+			return nil, fmt.Errorf("performing bulk walk for table %s: %w", t.Name, err)
+		}
+	}
+
+	known := map[string]bool{}
+	for _, f := range t.Fields {
+		known[dotOid(f.Oid)] = true
+	}
+	// do not modify the fields that are shared with the other agents
+	fields := append([]Field(nil), t.Fields...)
+	for _, ent := range pdus {
+		col, _, ok := strings.Cut(ent.Name[len(prefix):], ".")
+		if !ok {
+			continue
+		}
+		colOid := prefix + col
+		if known[colOid] {
+			continue
+		}
+		known[colOid] = true
+		f := Field{Oid: colOid}
+		f.Name, f.Conversion = translateName(t.translator, colOid)
+		if err := f.Init(t.translator); err != nil {
+			return nil, fmt.Errorf("initializing field %s: %w", f.Name, err)
+		}
+		fields = append(fields, f)
+	}
+	t.Fields = fields
+	return &walkedConn{Conn: gs, oid: t.oidNum, pdus: pdus}, nil
+}
+
 func (t Table) Build(gs Conn, walk bool) (*RTable, error) {
 	rows := map[string]RTableRow{}
 
+	if walk && t.Discover {
+		if fields, ok := t.discovered.get(gs.Host(), t.DiscoverInterval); ok {
+			t.Fields = fields
+		} else {
+			conn, err := t.discover(gs)
+			if err != nil {
+				return nil, err
+			}
+			t.discovered.put(gs.Host(), t.Fields)
+			gs = conn
+		}
+	}
+
 	//translation table for secondary index (when performing join on two tables)
 	secIdxTab := make(map[string]string)
 	secGlobalOuterJoin := false
@@ -296,3 +460,34 @@ func (e *walkError) Error() string {
 func (e *walkError) Unwrap() error {
 	return e.err
 }
+
+// walkedConn replays the values of the table that is walked already,
+// the OIDs out of the table are walked by the connection.
+type walkedConn struct {
+	Conn
+	oid  string
+	pdus []gosnmp.SnmpPDU
+}
+
+func (wc *walkedConn) Walk(oid string, fn gosnmp.WalkFunc) error {
+	if !strings.HasPrefix(oid, wc.oid+".") {
+		return wc.Conn.Walk(oid, fn)
+	}
+	for _, ent := range wc.pdus {
+		if !strings.HasPrefix(ent.Name, oid+".") {
+			continue
+		}
+		if err := fn(ent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dotOid returns the numeric OID with the leading "."
+func dotOid(oid string) string {
+	if oid != "" && oid[0] != '.' {
+		return "." + oid
+	}
+	return oid
+}
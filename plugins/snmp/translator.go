@@ -1,5 +1,7 @@
 package snmp
 
+import "strings"
+
 type Translator interface {
 	SnmpTranslate(string) (
 		mibName string, oidNum string, oidText string,
@@ -19,3 +21,21 @@ type Translator interface {
 
 var _ Translator = ((*gosmiTranslator)(nil))
 var _ Translator = ((*netsnmpTranslator)(nil))
+
+// translateName returns the name of the numeric OID translated by the MIB,
+// e.g. ".1.3.6.1.2.1.2.2.1.1.3" to "ifIndex.3".
+// The translator may drop the part of the OID that is not in the MIB, like the instance suffix,
+// it is appended to the name. If the OID is not found in the MIB, the numeric OID is returned.
+func translateName(tr Translator, oid string) (name string, conversion string) {
+	mibName, _, oidText, conversion, err := tr.SnmpTranslate(oid)
+	if err != nil || mibName == "" || oidText == "" {
+		return oid, ""
+	}
+	name = oidText
+	if _, nodeNum, _, _, err := tr.SnmpTranslate(mibName + "::" + oidText); err == nil {
+		if suffix, ok := strings.CutPrefix(oid, nodeNum); ok && strings.HasPrefix(suffix, ".") {
+			name += suffix
+		}
+	}
+	return name, conversion
+}
@@ -0,0 +1,149 @@
+package socket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/OutOfBedlam/tine/util"
+)
+
+// address is the parsed address url, e.g. "tcp://127.0.0.1:5000", "unix:///tmp/tine.sock"
+type address struct {
+	network string
+	addr    string
+	tls     bool
+}
+
+func (a address) String() string {
+	if a.tls {
+		return "tls://" + a.addr
+	}
+	return a.network + "://" + a.addr
+}
+
+// isPacket returns true if the network is datagram oriented
+func (a address) isPacket() bool {
+	switch a.network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return true
+	}
+	return false
+}
+
+func parseAddress(s string) (address, error) {
+	protoAddr := strings.SplitN(s, "://", 2)
+	if len(protoAddr) != 2 || protoAddr[1] == "" {
+		return address{}, fmt.Errorf("invalid address %q", s)
+	}
+	ret := address{network: protoAddr[0], addr: protoAddr[1]}
+	switch ret.network {
+	case "tls":
+		ret.network, ret.tls = "tcp", true
+	case "tcp", "tcp4", "tcp6", "unix", "unixpacket":
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return address{}, fmt.Errorf("unsupported protocol %q in %q", ret.network, s)
+	}
+	return ret, nil
+}
+
+// parseFraming returns the framing of the config,
+// "datagram" for the datagram oriented networks and "newline" for the others by default.
+func parseFraming(conf engine.Config, addr address) (string, error) {
+	def := "newline"
+	if addr.isPacket() {
+		def = "datagram"
+	}
+	framing := conf.GetString("framing", def)
+	switch framing {
+	case "newline", "length":
+		if addr.isPacket() {
+			return "", fmt.Errorf("framing %q is not available for %q", framing, addr.network)
+		}
+	case "datagram":
+		if !addr.isPacket() {
+			return "", fmt.Errorf("framing %q is not available for %q", framing, addr.network)
+		}
+	default:
+		return "", fmt.Errorf("unknown framing %q", framing)
+	}
+	return framing, nil
+}
+
+// tlsConfig returns the TLS options of the config
+func tlsConfig(conf engine.Config) util.TLSConfig {
+	return util.TLSConfig{
+		CertFile:           conf.GetString("tls_cert", ""),
+		KeyFile:            conf.GetString("tls_key", ""),
+		CAFile:             conf.GetString("tls_ca", ""),
+		InsecureSkipVerify: conf.GetBool("tls_insecure_skip_verify", false),
+		ServerName:         conf.GetString("tls_server_name", ""),
+	}
+}
+
+// readFrames reads the frames from the stream and calls fn for each frame,
+// it stops when the stream is closed or fn returns false.
+// The newline frame includes the trailing newline,
+// the length frame is prefixed by 4 bytes length in big endian.
+func readFrames(r io.Reader, framing string, maxSize int, fn func([]byte) bool) error {
+	switch framing {
+	case "length":
+		br := bufio.NewReader(r)
+		var hdr [4]byte
+		for {
+			if _, err := io.ReadFull(br, hdr[:]); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			size := binary.BigEndian.Uint32(hdr[:])
+			if int64(size) > int64(maxSize) {
+				return fmt.Errorf("frame size %d exceeds max_frame_size %d", size, maxSize)
+			}
+			frame := make([]byte, size)
+			if _, err := io.ReadFull(br, frame); err != nil {
+				return err
+			}
+			if !fn(frame) {
+				return nil
+			}
+		}
+	default:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 4096), maxSize)
+		sc.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.IndexByte(data, '\n'); i >= 0 {
+				return i + 1, data[:i+1], nil
+			}
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		})
+		for sc.Scan() {
+			if !fn(sc.Bytes()) {
+				return nil
+			}
+		}
+		return sc.Err()
+	}
+}
+
+// writeFrame writes the data with the framing
+func writeFrame(w io.Writer, framing string, data []byte) error {
+	if framing == "length" {
+		var hdr [4]byte
+		binary.BigEndian.PutUint32(hdr[:], uint32(len(data)))
+		if _, err := w.Write(hdr[:]); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,253 @@
+package socket
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/OutOfBedlam/tine/util"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "socket",
+		Factory: SocketInlet,
+	})
+}
+
+func SocketInlet(ctx *engine.Context) engine.Inlet {
+	return &socketInlet{
+		ctx:     ctx,
+		conns:   map[net.Conn]struct{}{},
+		pushCh:  make(chan []engine.Record),
+		closeCh: make(chan struct{}),
+	}
+}
+
+type socketInlet struct {
+	ctx          *engine.Context
+	conf         engine.Config
+	addr         address
+	framing      string
+	maxFrameSize int
+	readTimeout  time.Duration
+	remoteTag    string
+
+	lsnr    net.Listener
+	pktConn net.PacketConn
+	conns   map[net.Conn]struct{}
+	mutex   sync.Mutex
+
+	pushCh    chan []engine.Record
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	closeWg   sync.WaitGroup
+}
+
+var _ = engine.Inlet((*socketInlet)(nil))
+
+func (si *socketInlet) Open() error {
+	conf := si.ctx.Config()
+	addr, err := parseAddress(conf.GetString("address", "tcp://127.0.0.1:5000"))
+	if err != nil {
+		return fmt.Errorf("inlet.socket %w", err)
+	}
+	si.addr = addr
+	if si.framing, err = parseFraming(conf, addr); err != nil {
+		return fmt.Errorf("inlet.socket %w", err)
+	}
+	si.maxFrameSize = conf.GetInt("max_frame_size", 64*1024)
+	si.readTimeout = conf.GetDuration("read_timeout", 0)
+	si.remoteTag = conf.GetString("remote_tag", "remote")
+	si.conf = conf
+	if _, err := engine.NewReader(bytes.NewReader(nil), conf); err != nil {
+		return fmt.Errorf("inlet.socket %w", err)
+	}
+
+	lc := &net.ListenConfig{KeepAlive: conf.GetDuration("keepalive", 15*time.Second)}
+	if addr.isPacket() {
+		if si.pktConn, err = lc.ListenPacket(si.ctx, addr.network, addr.addr); err != nil {
+			return fmt.Errorf("inlet.socket %w", err)
+		}
+		si.closeWg.Add(1)
+		go si.handleDatagram()
+	} else {
+		if si.lsnr, err = lc.Listen(si.ctx, addr.network, addr.addr); err != nil {
+			return fmt.Errorf("inlet.socket %w", err)
+		}
+		if addr.tls {
+			tlsConf, err := util.NewServerTLSConfig(tlsConfig(conf))
+			if err != nil {
+				si.lsnr.Close()
+				return fmt.Errorf("inlet.socket %w", err)
+			}
+			si.lsnr = tls.NewListener(si.lsnr, tlsConf)
+		}
+		si.closeWg.Add(1)
+		go si.handleStream()
+	}
+	si.ctx.LogDebug("inlet.socket", "address", addr.String(), "framing", si.framing)
+	return nil
+}
+
+func (si *socketInlet) Close() error {
+	si.closeOnce.Do(func() {
+		close(si.closeCh)
+		if si.lsnr != nil {
+			si.lsnr.Close()
+		}
+		if si.pktConn != nil {
+			si.pktConn.Close()
+		}
+		si.mutex.Lock()
+		for conn := range si.conns {
+			conn.Close()
+		}
+		si.mutex.Unlock()
+		si.closeWg.Wait()
+	})
+	return nil
+}
+
+func (si *socketInlet) Process(next engine.InletNextFunc) {
+	for {
+		select {
+		case <-si.closeCh:
+			return
+		case recs := <-si.pushCh:
+			select {
+			case <-si.closeCh:
+				return
+			default:
+				next(recs, nil)
+			}
+		}
+	}
+}
+
+func (si *socketInlet) handleStream() {
+	defer si.closeWg.Done()
+	for {
+		conn, err := si.lsnr.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			si.ctx.LogWarn("inlet.socket", "accept error", err.Error())
+			continue
+		}
+		si.mutex.Lock()
+		select {
+		case <-si.closeCh:
+			si.mutex.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		si.conns[conn] = struct{}{}
+		si.mutex.Unlock()
+
+		si.closeWg.Add(1)
+		go si.handleConn(conn)
+	}
+}
+
+func (si *socketInlet) handleConn(conn net.Conn) {
+	defer func() {
+		si.mutex.Lock()
+		delete(si.conns, conn)
+		si.mutex.Unlock()
+		conn.Close()
+		si.closeWg.Done()
+	}()
+	remote := conn.RemoteAddr().String()
+	si.ctx.LogDebug("inlet.socket", "connected", remote)
+
+	var r io.Reader = conn
+	if si.readTimeout > 0 {
+		r = &timeoutReader{conn: conn, timeout: si.readTimeout}
+	}
+	err := readFrames(r, si.framing, si.maxFrameSize, func(frame []byte) bool {
+		return si.push(frame, remote)
+	})
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		si.ctx.LogWarn("inlet.socket", "remote", remote, "error", err.Error())
+	}
+	si.ctx.LogDebug("inlet.socket", "disconnected", remote)
+}
+
+func (si *socketInlet) handleDatagram() {
+	defer si.closeWg.Done()
+	buf := make([]byte, si.maxFrameSize)
+	for {
+		n, addr, err := si.pktConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			si.ctx.LogWarn("inlet.socket", "read error", err.Error())
+			continue
+		}
+		remote := ""
+		if addr != nil {
+			remote = addr.String()
+		}
+		if !si.push(buf[:n], remote) {
+			return
+		}
+	}
+}
+
+// push decodes the frame and sends the records to the pipeline,
+// it returns false if the inlet is closed.
+func (si *socketInlet) push(frame []byte, remote string) bool {
+	reader, err := engine.NewReader(bytes.NewReader(frame), si.conf)
+	if err != nil {
+		// drop the frame and keep reading the next ones
+		si.ctx.LogWarn("inlet.socket", "remote", remote, "reader error", err.Error())
+		return true
+	}
+	defer reader.Close()
+	ret := []engine.Record{}
+	for {
+		recs, err := reader.Read()
+		ret = append(ret, recs...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			si.ctx.LogWarn("inlet.socket", "remote", remote, "decode error", err.Error())
+			return true
+		}
+	}
+	if len(ret) == 0 {
+		return true
+	}
+	if si.remoteTag != "" && remote != "" {
+		for _, r := range ret {
+			r.Tags().Set(si.remoteTag, engine.NewValue(remote))
+		}
+	}
+	select {
+	case <-si.closeCh:
+		return false
+	case si.pushCh <- ret:
+		return true
+	}
+}
+
+// timeoutReader closes the idle connection by the read deadline
+type timeoutReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (tr *timeoutReader) Read(p []byte) (int, error) {
+	tr.conn.SetReadDeadline(time.Now().Add(tr.timeout))
+	return tr.conn.Read(p)
+}
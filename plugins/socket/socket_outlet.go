@@ -0,0 +1,164 @@
+package socket
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/OutOfBedlam/tine/util"
+)
+
+func init() {
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name:    "socket",
+		Factory: SocketOutlet,
+	})
+}
+
+func SocketOutlet(ctx *engine.Context) engine.Outlet {
+	return &socketOutlet{ctx: ctx}
+}
+
+type socketOutlet struct {
+	ctx               *engine.Context
+	conf              engine.Config
+	addr              address
+	framing           string
+	timeout           time.Duration
+	retries           int
+	reconnectInterval time.Duration
+
+	dialer  *net.Dialer
+	tlsConf *tls.Config
+	conn    net.Conn
+}
+
+var _ = engine.Outlet((*socketOutlet)(nil))
+
+func (so *socketOutlet) Open() error {
+	conf := so.ctx.Config()
+	addr, err := parseAddress(conf.GetString("address", "tcp://127.0.0.1:5000"))
+	if err != nil {
+		return fmt.Errorf("outlet.socket %w", err)
+	}
+	so.addr = addr
+	if so.framing, err = parseFraming(conf, addr); err != nil {
+		return fmt.Errorf("outlet.socket %w", err)
+	}
+	so.timeout = conf.GetDuration("timeout", 3*time.Second)
+	so.retries = conf.GetInt("retries", 3)
+	so.reconnectInterval = conf.GetDuration("reconnect_interval", time.Second)
+	so.conf = conf
+	if _, err := engine.NewWriter(&bytes.Buffer{}, conf); err != nil {
+		return fmt.Errorf("outlet.socket %w", err)
+	}
+	so.dialer = &net.Dialer{
+		Timeout:   so.timeout,
+		KeepAlive: conf.GetDuration("keepalive", 15*time.Second),
+	}
+	if addr.tls {
+		if so.tlsConf, err = util.NewClientTLSConfig(tlsConfig(conf)); err != nil {
+			return fmt.Errorf("outlet.socket %w", err)
+		}
+	}
+	// if the peer is not available yet, it connects again when it writes
+	if err := so.connect(); err != nil {
+		so.ctx.LogWarn("outlet.socket", "address", addr.String(), "connect error", err.Error())
+	}
+	so.ctx.LogDebug("outlet.socket", "address", addr.String(), "framing", so.framing)
+	return nil
+}
+
+func (so *socketOutlet) Close() error {
+	if so.conn != nil {
+		so.conn.Close()
+		so.conn = nil
+	}
+	return nil
+}
+
+func (so *socketOutlet) connect() error {
+	var conn net.Conn
+	var err error
+	if so.tlsConf != nil {
+		conn, err = tls.DialWithDialer(so.dialer, so.addr.network, so.addr.addr, so.tlsConf)
+	} else {
+		conn, err = so.dialer.Dial(so.addr.network, so.addr.addr)
+	}
+	if err != nil {
+		return err
+	}
+	so.conn = conn
+	return nil
+}
+
+// Handle writes the records, newline framing writes the records in a batch,
+// length and datagram framing write a frame for each record.
+// If it fails to write, it reconnects and retries.
+func (so *socketOutlet) Handle(recs []engine.Record) error {
+	frames := [][]byte{}
+	if so.framing == "newline" {
+		data, err := so.encode(recs)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, data)
+	} else {
+		for _, r := range recs {
+			data, err := so.encode([]engine.Record{r})
+			if err != nil {
+				return err
+			}
+			frames = append(frames, data)
+		}
+	}
+
+	var err error
+	for i := 0; i <= so.retries; i++ {
+		if i > 0 {
+			time.Sleep(so.reconnectInterval)
+		}
+		if so.conn == nil {
+			if err = so.connect(); err != nil {
+				so.ctx.LogWarn("outlet.socket", "address", so.addr.String(), "reconnect error", err.Error())
+				continue
+			}
+		}
+		// the frames those are written already are not written again
+		if frames, err = so.write(frames); err == nil {
+			return nil
+		}
+		so.ctx.LogWarn("outlet.socket", "address", so.addr.String(), "write error", err.Error())
+		so.conn.Close()
+		so.conn = nil
+	}
+	return fmt.Errorf("outlet.socket %q, %w", so.addr.String(), err)
+}
+
+// write returns the frames those are not written yet
+func (so *socketOutlet) write(frames [][]byte) ([][]byte, error) {
+	for len(frames) > 0 {
+		so.conn.SetWriteDeadline(time.Now().Add(so.timeout))
+		if err := writeFrame(so.conn, so.framing, frames[0]); err != nil {
+			return frames, err
+		}
+		frames = frames[1:]
+	}
+	return nil, nil
+}
+
+func (so *socketOutlet) encode(recs []engine.Record) ([]byte, error) {
+	data := &bytes.Buffer{}
+	w, err := engine.NewWriter(data, so.conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Write(recs); err != nil {
+		return nil, err
+	}
+	w.Close()
+	return data.Bytes(), nil
+}
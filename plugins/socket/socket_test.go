@@ -0,0 +1,172 @@
+package socket_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/socket"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key,
+// the certificate is also used as the CA.
+func writeCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tine"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func TestSocket(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+	tlsConf := fmt.Sprintf("tls_cert = %q\ntls_key = %q\ntls_ca = %q", certFile, keyFile, certFile)
+
+	tests := []struct {
+		name    string
+		address string
+		conf    string
+	}{
+		{name: "tcp_newline", address: fmt.Sprintf("tcp://127.0.0.1:%d", freePort(t))},
+		{name: "tcp_length", address: fmt.Sprintf("tcp://127.0.0.1:%d", freePort(t)), conf: `framing = "length"`},
+		{name: "udp", address: fmt.Sprintf("udp://127.0.0.1:%d", freePort(t))},
+		{name: "unix", address: "unix://" + filepath.Join(dir, "tine.sock"), conf: `format = "json"`},
+		{name: "tls", address: fmt.Sprintf("tls://127.0.0.1:%d", freePort(t)), conf: tlsConf},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dslIn := fmt.Sprintf(`
+			[[inlets.socket]]
+				address = %q
+				fields = ["name", "value"]
+				types = ["string", "int"]
+				%s
+			[[outlets.file]]
+				format = "json"
+			`, tt.address, tt.conf)
+			out := &syncBuffer{}
+			pipeIn, err := engine.New(engine.WithConfig(dslIn), engine.WithWriter(out))
+			require.NoError(t, err)
+			go pipeIn.Run()
+			// wait until the inlet listens
+			time.Sleep(100 * time.Millisecond)
+
+			dslOut := fmt.Sprintf(`
+			[[inlets.file]]
+				data = ["a,1", "b,2", "c,3"]
+				format = "csv"
+				fields = ["name", "value"]
+				types = ["string", "int"]
+			[[outlets.socket]]
+				address = %q
+				%s
+			`, tt.address, tt.conf)
+			pipeOut, err := engine.New(engine.WithConfig(dslOut))
+			require.NoError(t, err)
+			require.NoError(t, pipeOut.Run())
+
+			expect := `{"name":"a","value":1}` + "\n" + `{"name":"b","value":2}` + "\n" + `{"name":"c","value":3}` + "\n"
+			for i := 0; i < 100 && len(out.String()) < len(expect); i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+			pipeIn.Stop()
+			require.Equal(t, expect, out.String())
+		})
+	}
+}
+
+func TestSocketOutletReconnect(t *testing.T) {
+	address := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	received := make(chan string, 1)
+	go func() {
+		// the receiver is not available at the start
+		time.Sleep(300 * time.Millisecond)
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+		buf := &bytes.Buffer{}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf.ReadFrom(conn)
+		received <- buf.String()
+	}()
+
+	dsl := fmt.Sprintf(`
+	[[inlets.file]]
+		data = ["a,1", "b,2"]
+		format = "csv"
+	[[outlets.socket]]
+		address = "tcp://%s"
+		retries = 10
+		reconnect_interval = "100ms"
+		format = "csv"
+	`, address)
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	require.NoError(t, err)
+	require.NoError(t, pipeline.Run())
+	require.Equal(t, "a,1\nb,2\n", <-received)
+}
@@ -0,0 +1,164 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+// openDB opens the database of the driver and the dsn, and executes the inits.
+// The driver should be compiled in, e.g. import _ "github.com/lib/pq"
+func openDB(ctx *engine.Context, name string) (*sql.DB, error) {
+	conf := ctx.Config()
+	driver := conf.GetString("driver", "")
+	dsn := conf.GetString("dsn", "")
+	if driver == "" {
+		return nil, fmt.Errorf("%s driver is not specified", name)
+	}
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("%s driver %q is not compiled in, available drivers are %v", name, driver, sql.Drivers())
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", name, err)
+	}
+	if n := conf.GetInt("max_open_conns", 0); n > 0 {
+		db.SetMaxOpenConns(n)
+	}
+	if d := conf.GetDuration("conn_max_lifetime", 0); d > 0 {
+		db.SetConnMaxLifetime(d)
+	}
+	for _, sqlText := range conf.GetStringSlice("inits", []string{}) {
+		if _, err := db.ExecContext(ctx, sqlText); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s init %w", name, err)
+		}
+	}
+	ctx.LogDebug(name, "driver", driver)
+	return db, nil
+}
+
+// rowRecord converts the scanned values of a row into a record
+func rowRecord(cols []*sql.ColumnType, values []any) engine.Record {
+	rec := engine.NewRecord()
+	for i, col := range cols {
+		dbType := strings.ToUpper(col.DatabaseTypeName())
+		var v *engine.Value
+		switch raw := values[i].(type) {
+		case nil:
+			v = engine.NewNullValue(columnType(dbType))
+		case int64:
+			v = engine.NewValue(raw)
+		case int32:
+			v = engine.NewValue(int64(raw))
+		case uint64:
+			v = engine.NewValue(raw)
+		case float64:
+			v = engine.NewValue(raw)
+		case float32:
+			v = engine.NewValue(float64(raw))
+		case bool:
+			v = engine.NewValue(raw)
+		case time.Time:
+			v = engine.NewValue(raw)
+		case string:
+			v = engine.NewValue(raw)
+		case []byte:
+			// drivers return []byte for the text columns also
+			if columnType(dbType) == engine.BINARY {
+				v = engine.NewValue(slices.Clone(raw))
+			} else {
+				v = engine.NewValue(string(raw))
+			}
+		default:
+			v = engine.NewValue(fmt.Sprint(raw))
+		}
+		rec = rec.Append(engine.NewFieldWithValue(col.Name(), v))
+	}
+	return rec
+}
+
+// columnType returns the type of the field for the database type name of the column
+func columnType(dbType string) engine.Type {
+	switch {
+	case strings.Contains(dbType, "INT"):
+		return engine.INT
+	case strings.Contains(dbType, "REAL"), strings.Contains(dbType, "FLOAT"), strings.Contains(dbType, "DOUBLE"),
+		strings.Contains(dbType, "NUMERIC"), strings.Contains(dbType, "DECIMAL"):
+		return engine.FLOAT
+	case strings.Contains(dbType, "BOOL"):
+		return engine.BOOL
+	case strings.Contains(dbType, "TIME"), strings.Contains(dbType, "DATE"):
+		return engine.TIME
+	case strings.Contains(dbType, "BLOB"), strings.Contains(dbType, "BINARY"), dbType == "BYTEA":
+		return engine.BINARY
+	default:
+		return engine.STRING
+	}
+}
+
+// readMark reads the high-water mark from the file,
+// it is saved as the type of the value and the value, e.g. "i 123", "t 2024-07-26T10:00:00Z"
+func readMark(path string) (*engine.Value, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	str := strings.TrimRight(string(b), "\r\n")
+	typ, val, ok := strings.Cut(str, " ")
+	if !ok || len(typ) != 1 {
+		return nil, fmt.Errorf("invalid high-water mark %q", str)
+	}
+	switch engine.Type(typ[0]) {
+	case engine.INT:
+		if v, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return engine.NewValue(v), nil
+		}
+	case engine.UINT:
+		if v, err := strconv.ParseUint(val, 10, 64); err == nil {
+			return engine.NewValue(v), nil
+		}
+	case engine.FLOAT:
+		if v, err := strconv.ParseFloat(val, 64); err == nil {
+			return engine.NewValue(v), nil
+		}
+	case engine.TIME:
+		if v, err := time.Parse(time.RFC3339Nano, val); err == nil {
+			return engine.NewValue(v), nil
+		}
+	case engine.STRING:
+		return engine.NewValue(val), nil
+	}
+	return nil, fmt.Errorf("invalid high-water mark %q", str)
+}
+
+// writeMark writes the high-water mark to the file,
+// it writes a temporary file and renames it not to leave the broken file.
+func writeMark(path string, v *engine.Value) error {
+	var str string
+	switch raw := v.Raw().(type) {
+	case int64:
+		str = strconv.FormatInt(raw, 10)
+	case uint64:
+		str = strconv.FormatUint(raw, 10)
+	case float64:
+		str = strconv.FormatFloat(raw, 'g', -1, 64)
+	case time.Time:
+		str = raw.Format(time.RFC3339Nano)
+	case string:
+		str = raw
+	default:
+		return fmt.Errorf("unsupported high-water mark type %s", v.Type())
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(string(v.Type())+" "+str+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,179 @@
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync/atomic"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "sql",
+		Factory: SqlInlet,
+	})
+}
+
+func SqlInlet(ctx *engine.Context) engine.Inlet {
+	interval := ctx.Config().GetDuration("interval", 0)
+	if interval <= 0 {
+		interval = 0
+	} else if interval < time.Second {
+		interval = time.Second
+	}
+	return &sqlInlet{ctx: ctx, interval: interval}
+}
+
+type sqlInlet struct {
+	ctx *engine.Context
+	db  *sql.DB
+
+	query      string
+	yieldRows  int
+	interval   time.Duration
+	countLimit int64
+	runCount   int64
+
+	// high-water mark
+	markColumn string
+	markFile   string
+	mark       *engine.Value
+}
+
+var _ = (engine.PeriodicInlet)((*sqlInlet)(nil))
+
+func (si *sqlInlet) Open() error {
+	conf := si.ctx.Config()
+	si.query = conf.GetString("query", "")
+	si.yieldRows = conf.GetInt("yield_rows", 0)
+	si.countLimit = conf.GetInt64("count", 0)
+	si.markColumn = conf.GetString("high_water_column", "")
+	si.markFile = conf.GetString("high_water_file", "")
+	if si.query == "" {
+		return errors.New("inlet.sql query is not specified")
+	}
+	if si.markColumn != "" {
+		si.mark = conf.GetValue("high_water_start")
+		if si.mark.IsNull() {
+			si.mark = engine.NewValue(int64(0))
+		}
+		if si.markFile != "" {
+			if v, err := readMark(si.markFile); err == nil {
+				si.mark = v
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("inlet.sql high_water_file %w", err)
+			}
+		}
+		si.ctx.LogDebug("inlet.sql", "high_water_column", si.markColumn, "high_water_mark", si.mark.Raw())
+	}
+
+	db, err := openDB(si.ctx, "inlet.sql")
+	if err != nil {
+		return err
+	}
+	si.db = db
+	return nil
+}
+
+func (si *sqlInlet) Close() error {
+	if si.db != nil {
+		si.db.Close()
+	}
+	return nil
+}
+
+func (si *sqlInlet) Interval() time.Duration {
+	return si.interval
+}
+
+func (si *sqlInlet) Process(next engine.InletNextFunc) {
+	runCount := atomic.AddInt64(&si.runCount, 1)
+	if si.countLimit > 0 && runCount > si.countLimit {
+		next(nil, io.EOF)
+		return
+	}
+	if err := si.poll(next); err != nil {
+		si.ctx.LogWarn("inlet.sql", "sql", si.query, "error", err)
+		next(nil, err)
+		return
+	}
+	if si.countLimit > 0 && runCount >= si.countLimit {
+		next(nil, io.EOF)
+	}
+}
+
+// poll queries the rows with the high-water mark as the argument if it is set,
+// the mark is updated with the greatest value of the column after the rows are yielded.
+func (si *sqlInlet) poll(next engine.InletNextFunc) error {
+	var args []any
+	if si.mark != nil {
+		args = append(args, si.mark.Raw())
+	}
+	rows, err := si.db.QueryContext(si.ctx, si.query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	markIdx := -1
+	for i, c := range cols {
+		if si.markColumn != "" && c.Name() == si.markColumn {
+			markIdx = i
+		}
+	}
+	if si.markColumn != "" && markIdx < 0 {
+		return fmt.Errorf("high_water_column %q is not in the result", si.markColumn)
+	}
+
+	mark := si.mark
+	yield := func(rset []engine.Record) error {
+		next(rset, nil)
+		if mark == si.mark {
+			return nil
+		}
+		si.mark = mark
+		if si.markFile != "" {
+			return writeMark(si.markFile, mark)
+		}
+		return nil
+	}
+	rset := []engine.Record{}
+	values := make([]any, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		rec := rowRecord(cols, values)
+		if markIdx >= 0 {
+			if v := rec.FieldAt(markIdx).Value; !v.IsNull() && (mark.Type() != v.Type() || v.Gt(mark)) {
+				mark = v
+			}
+		}
+		rset = append(rset, rec)
+		if si.yieldRows > 0 && len(rset) >= si.yieldRows {
+			if err := yield(rset); err != nil {
+				return err
+			}
+			rset = []engine.Record{}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(rset) > 0 {
+		return yield(rset)
+	}
+	return nil
+}
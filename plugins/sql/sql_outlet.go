@@ -0,0 +1,166 @@
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+func init() {
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name:    "sql",
+		Factory: SqlOutlet,
+	})
+}
+
+func SqlOutlet(ctx *engine.Context) engine.Outlet {
+	return &sqlOutlet{ctx: ctx}
+}
+
+type sqlOutlet struct {
+	ctx *engine.Context
+	db  *sql.DB
+
+	actions    []action
+	batchSize  int
+	timeformat *engine.Timeformatter
+}
+
+// action is the sql statement and the names of the fields those are bound to the parameters,
+// the name that starts with "#" is the tag.
+type action struct {
+	sqlText string
+	params  []string
+}
+
+var _ = (engine.Outlet)((*sqlOutlet)(nil))
+
+func (so *sqlOutlet) Open() error {
+	conf := so.ctx.Config()
+	so.batchSize = conf.GetInt("batch_size", 0)
+	if tf := conf.GetString("timeformat", ""); tf != "" {
+		so.timeformat = engine.NewTimeformatter(tf)
+	}
+	if list, ok := conf["actions"].([]any); ok {
+		for _, item := range list {
+			strs, ok := item.([]any)
+			if !ok || len(strs) == 0 {
+				return fmt.Errorf("outlet.sql invalid action %v", item)
+			}
+			act := action{}
+			for i, s := range strs {
+				str, ok := s.(string)
+				if !ok {
+					return fmt.Errorf("outlet.sql invalid action %v", item)
+				}
+				if i == 0 {
+					act.sqlText = str
+				} else {
+					act.params = append(act.params, str)
+				}
+			}
+			so.actions = append(so.actions, act)
+		}
+	}
+	if len(so.actions) == 0 {
+		return errors.New("outlet.sql actions are not specified")
+	}
+
+	db, err := openDB(so.ctx, "outlet.sql")
+	if err != nil {
+		return err
+	}
+	so.db = db
+	return nil
+}
+
+func (so *sqlOutlet) Close() error {
+	if so.db != nil {
+		so.db.Close()
+	}
+	return nil
+}
+
+// Handle executes the actions for each record in a transaction,
+// the records are split into the transactions of batch_size if it is set.
+func (so *sqlOutlet) Handle(recs []engine.Record) error {
+	for len(recs) > 0 {
+		n := len(recs)
+		if so.batchSize > 0 && n > so.batchSize {
+			n = so.batchSize
+		}
+		if err := so.exec(recs[:n]); err != nil {
+			return err
+		}
+		recs = recs[n:]
+	}
+	return nil
+}
+
+func (so *sqlOutlet) exec(recs []engine.Record) error {
+	tx, err := so.db.BeginTx(so.ctx, nil)
+	if err != nil {
+		return fmt.Errorf("outlet.sql %w", err)
+	}
+	// Rollback does nothing after Commit
+	defer tx.Rollback()
+
+	stmts := make([]*sql.Stmt, len(so.actions))
+	for i, act := range so.actions {
+		stmt, err := tx.PrepareContext(so.ctx, act.sqlText)
+		if err != nil {
+			return fmt.Errorf("outlet.sql prepare %w", err)
+		}
+		defer stmt.Close()
+		stmts[i] = stmt
+	}
+	rowsAffected := int64(0)
+	for _, rec := range recs {
+		for i, act := range so.actions {
+			args := so.args(rec, act.params)
+			result, err := stmts[i].ExecContext(so.ctx, args...)
+			if err != nil {
+				so.ctx.LogWarn("outlet.sql", "error", err, "sql", act.sqlText, "args", args)
+				return fmt.Errorf("outlet.sql exec %w", err)
+			}
+			if n, err := result.RowsAffected(); err == nil {
+				rowsAffected += n
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("outlet.sql commit %w", err)
+	}
+	so.ctx.LogDebug("outlet.sql", "records", len(recs), "rowsAffected", rowsAffected)
+	return nil
+}
+
+// args returns the values of the fields or the tags, nil for the missing one
+func (so *sqlOutlet) args(rec engine.Record, params []string) []any {
+	ret := make([]any, len(params))
+	for i, name := range params {
+		var v *engine.Value
+		if tag, ok := strings.CutPrefix(name, "#"); ok {
+			v = rec.Tags().Get(tag)
+		} else if f := rec.Field(name); f != nil {
+			v = f.Value
+		}
+		if v == nil || v.IsNull() {
+			continue
+		}
+		if t, ok := v.Raw().(time.Time); ok && so.timeformat != nil {
+			if so.timeformat.IsEpoch() {
+				ret[i] = so.timeformat.Epoch(t)
+			} else {
+				ret[i] = so.timeformat.Format(t)
+			}
+			continue
+		}
+		ret[i] = v.Raw()
+	}
+	return ret
+}
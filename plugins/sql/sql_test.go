@@ -0,0 +1,126 @@
+package sql_test
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	tinesql "github.com/OutOfBedlam/tine/plugins/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func testContext(conf engine.Config) *engine.Context {
+	return (&engine.Context{}).WithConfig(conf).WithLogger(slog.Default())
+}
+
+func TestSqlOutlet(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	dsl := fmt.Sprintf(`
+		[log]
+			level = "error"
+		[[inlets.file]]
+			data = ["a,1.5", "b,2.5", "c,3.5", "a,4.5"]
+			fields = ["name", "value"]
+			types = ["string", "float"]
+		[[outlets.sql]]
+			driver = "sqlite3"
+			dsn = %q
+			inits = ["CREATE TABLE metrics (ts INTEGER, inlet TEXT, name TEXT UNIQUE, value REAL)"]
+			actions = [
+				["INSERT INTO metrics (ts, inlet, name, value) VALUES (?, ?, ?, ?)", "#_ts", "#_in", "name", "value"],
+			]
+			batch_size = 2
+			timeformat = "s"
+		`, dsn)
+	seq := int64(0)
+	engine.Now = func() time.Time { seq++; return time.Unix(1721954797+seq, 0) }
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	require.NoError(t, err)
+	require.NoError(t, pipeline.Run())
+
+	db, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	rows, err := db.Query("SELECT ts, inlet, name, value FROM metrics ORDER BY name")
+	require.NoError(t, err)
+	defer rows.Close()
+	result := []string{}
+	for rows.Next() {
+		var ts int64
+		var inlet, name string
+		var value float64
+		require.NoError(t, rows.Scan(&ts, &inlet, &name, &value))
+		result = append(result, fmt.Sprintf("%d %s %s %v", ts, inlet, name, value))
+	}
+	// the second batch is rolled back by the unique constraint violation of the last record
+	require.Equal(t, []string{"1721954798 file a 1.5", "1721954799 file b 2.5"}, result)
+}
+
+func TestSqlInlet(t *testing.T) {
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "test.db")
+	markFile := filepath.Join(dir, "events.mark")
+
+	db, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT, value REAL, data BLOB)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO events (id, name, value) VALUES (1, 'a', 1.5), (2, 'b', NULL), (3, NULL, 3.5)")
+	require.NoError(t, err)
+
+	dsl := fmt.Sprintf(`
+		[[inlets.sql]]
+			driver = "sqlite3"
+			dsn = %q
+			query = "SELECT id, name, value FROM events WHERE id > ? ORDER BY id"
+			high_water_column = "id"
+			high_water_file = %q
+			yield_rows = 2
+			count = 1
+		[[outlets.file]]
+			format = "json"
+		`, dsn, markFile)
+	run := func() string {
+		out := &bytes.Buffer{}
+		pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+		require.NoError(t, err)
+		require.NoError(t, pipeline.Run())
+		return out.String()
+	}
+
+	require.Equal(t, ``+
+		`{"id":1,"name":"a","value":1.5}`+"\n"+
+		`{"id":2,"name":"b","value":null}`+"\n"+
+		`{"id":3,"name":null,"value":3.5}`+"\n",
+		run())
+	mark, err := os.ReadFile(markFile)
+	require.NoError(t, err)
+	require.Equal(t, "i 3\n", string(mark))
+
+	// the rows after the high-water mark
+	_, err = db.Exec("INSERT INTO events (id, name, value) VALUES (4, 'd', 4.5)")
+	require.NoError(t, err)
+	require.Equal(t, `{"id":4,"name":"d","value":4.5}`+"\n", run())
+	require.Equal(t, "", run())
+}
+
+func TestSqlDriver(t *testing.T) {
+	conf := engine.NewConfig().
+		Set("driver", "nodriver").
+		Set("query", "SELECT 1")
+	inlet := tinesql.SqlInlet(testContext(conf))
+	require.ErrorContains(t, inlet.Open(), `inlet.sql driver "nodriver" is not compiled in`)
+
+	conf = engine.NewConfig().Set("driver", "sqlite3").Set("dsn", ":memory:")
+	outlet := tinesql.SqlOutlet(testContext(conf))
+	require.ErrorContains(t, outlet.Open(), "outlet.sql actions are not specified")
+}
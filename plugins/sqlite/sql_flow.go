@@ -0,0 +1,305 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+func init() {
+	engine.RegisterFlow(&engine.FlowReg{
+		Name:    "sql",
+		Factory: SqlFlow,
+	})
+}
+
+func SqlFlow(ctx *engine.Context) engine.Flow {
+	conf := ctx.Config()
+	return &sqlFlow{
+		ctx:        ctx,
+		table:      conf.GetString("table", "records"),
+		query:      conf.GetString("query", ""),
+		tags:       conf.GetStringSlice("tags", nil),
+		window:     conf.GetDuration("window", 0),
+		windowSize: conf.GetInt("window_size", 0),
+		lastFlush:  time.Now(),
+	}
+}
+
+// sqlFlow loads the records into the table of the in-memory database,
+// and emits the result rows of the query as the records.
+type sqlFlow struct {
+	ctx *engine.Context
+	db  *sql.DB
+
+	table      string
+	query      string
+	tags       []string
+	window     time.Duration
+	windowSize int
+
+	mutex     sync.Mutex
+	buffer    []engine.Record
+	received  uint64
+	lastFlush time.Time
+	nextFunc  engine.FlowNextFunc
+	flushed   bool
+	closeCh   chan struct{}
+	closeWg   sync.WaitGroup
+}
+
+var _ = engine.Flow((*sqlFlow)(nil))
+var _ = engine.BufferedFlow((*sqlFlow)(nil))
+var _ = engine.PartialFlow((*sqlFlow)(nil))
+
+func (sf *sqlFlow) Open() error {
+	if sf.query == "" {
+		return errors.New("flows.sql query is not specified")
+	}
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return err
+	}
+	// every connection has its own in-memory database
+	db.SetMaxOpenConns(1)
+	sf.db = db
+	if sf.window > 0 {
+		sf.closeCh = make(chan struct{})
+		sf.closeWg.Add(1)
+		go sf.watch()
+	}
+	return nil
+}
+
+func (sf *sqlFlow) Close() error {
+	if sf.closeCh != nil {
+		close(sf.closeCh)
+		sf.closeWg.Wait()
+		sf.closeCh = nil
+	}
+	if sf.db != nil {
+		sf.db.Close()
+	}
+	return nil
+}
+
+func (sf *sqlFlow) Parallelism() int { return 1 }
+
+// watch runs the query for the records of the window when the window is passed,
+// even if no more records arrive.
+func (sf *sqlFlow) watch() {
+	defer sf.closeWg.Done()
+	ticker := time.NewTicker(sf.window / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sf.closeCh:
+			return
+		case now := <-ticker.C:
+			sf.mutex.Lock()
+			if !sf.flushed && sf.nextFunc != nil && len(sf.buffer) > 0 && now.Sub(sf.lastFlush) >= sf.window {
+				sf.flush(sf.nextFunc)
+			}
+			sf.mutex.Unlock()
+		}
+	}
+}
+
+// Process runs the query for each batch of records,
+// or for the records of the window if window or window_size is set.
+func (sf *sqlFlow) Process(recs []engine.Record, nextFunc engine.FlowNextFunc) {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+	sf.nextFunc = nextFunc
+	sf.buffer = append(sf.buffer, recs...)
+	sf.received += uint64(len(recs))
+	if sf.window > 0 || sf.windowSize > 0 {
+		full := sf.windowSize > 0 && len(sf.buffer) >= sf.windowSize
+		expired := sf.window > 0 && time.Since(sf.lastFlush) >= sf.window
+		if !full && !expired {
+			nextFunc(nil, nil)
+			return
+		}
+	}
+	sf.flush(nextFunc)
+}
+
+// Released returns the number of the received records those are queried
+func (sf *sqlFlow) Released() uint64 {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+	return sf.received - uint64(len(sf.buffer))
+}
+
+func (sf *sqlFlow) Flush(nextFunc engine.FlowNextFunc) {
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+	sf.flushed = true
+	sf.flush(nextFunc)
+}
+
+func (sf *sqlFlow) flush(nextFunc engine.FlowNextFunc) {
+	recs := sf.buffer
+	sf.buffer = nil
+	sf.lastFlush = time.Now()
+	if len(recs) == 0 {
+		nextFunc(nil, nil)
+		return
+	}
+	ret, err := sf.run(recs)
+	if err != nil {
+		sf.ctx.LogWarn("flows.sql", "query", sf.query, "error", err)
+	}
+	nextFunc(ret, err)
+}
+
+func (sf *sqlFlow) run(recs []engine.Record) ([]engine.Record, error) {
+	// the types of the columns follow the first non-null values of the fields
+	tb := engine.NewTable[int]()
+	for i, r := range recs {
+		fields := []*engine.Field{}
+		for _, name := range sf.tags {
+			if v := r.Tags().Get(name); v != nil {
+				fields = append(fields, engine.NewFieldWithValue(name, v))
+			}
+		}
+		tb.Set(i, append(fields, r.Fields()...)...)
+	}
+	columns, types := tb.Columns(), tb.Types()
+
+	tx, err := sf.db.BeginTx(sf.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback does nothing after Commit
+	defer tx.Rollback()
+
+	defs := make([]string, len(columns))
+	for i, col := range columns {
+		defs[i] = strings.TrimSpace(quoteIdent(col) + " " + declType(types[i]))
+	}
+	for _, sqlText := range []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdent(sf.table)),
+		fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdent(sf.table), strings.Join(defs, ", ")),
+	} {
+		if _, err := tx.ExecContext(sf.ctx, sqlText); err != nil {
+			return nil, err
+		}
+	}
+	if len(columns) > 0 {
+		cols := make([]string, len(columns))
+		for i, col := range columns {
+			cols[i] = quoteIdent(col)
+		}
+		stmt, err := tx.PrepareContext(sf.ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			quoteIdent(sf.table), strings.Join(cols, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")))
+		if err != nil {
+			return nil, err
+		}
+		defer stmt.Close()
+		for _, row := range tb.Rows() {
+			args := make([]any, len(columns))
+			for i, f := range row {
+				if f != nil && !f.IsNull() {
+					args[i] = sqlValue(f.Value)
+				}
+			}
+			if _, err := stmt.ExecContext(sf.ctx, args...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	rows, err := tx.QueryContext(sf.ctx, sf.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	ret := []engine.Record{}
+	values := make([]any, len(colTypes))
+	dest := make([]any, len(colTypes))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		rec := engine.NewRecord()
+		for i, ct := range colTypes {
+			rec = rec.Append(engine.NewFieldWithValue(ct.Name(), scanValue(values[i], ct.DatabaseTypeName())))
+		}
+		ret = append(ret, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ret, tx.Commit()
+}
+
+// sqlValue returns the value that the driver accepts,
+// the driver does not accept uint64 that is greater than math.MaxInt64,
+// it is converted to float64 as SQLite does for the integer literal that overflows.
+func sqlValue(v *engine.Value) any {
+	if u, ok := v.Raw().(uint64); ok {
+		if u > math.MaxInt64 {
+			return float64(u)
+		}
+		return int64(u)
+	}
+	return v.Raw()
+}
+
+// declType returns the declared type of the column,
+// the driver converts the values of BOOLEAN and DATETIME columns to bool and time.Time.
+func declType(typ engine.Type) string {
+	switch typ {
+	case engine.BOOL:
+		return "BOOLEAN"
+	case engine.TIME:
+		return "DATETIME"
+	default:
+		return affinity(typ)
+	}
+}
+
+func scanValue(v any, dbType string) *engine.Value {
+	switch raw := v.(type) {
+	case int64:
+		return engine.NewValue(raw)
+	case float64:
+		return engine.NewValue(raw)
+	case bool:
+		return engine.NewValue(raw)
+	case string:
+		return engine.NewValue(raw)
+	case time.Time:
+		return engine.NewValue(raw)
+	case []byte:
+		return engine.NewValue(append([]byte(nil), raw...))
+	}
+	switch strings.ToUpper(dbType) {
+	case "INTEGER":
+		return engine.NewNullValue(engine.INT)
+	case "REAL":
+		return engine.NewNullValue(engine.FLOAT)
+	case "BOOLEAN":
+		return engine.NewNullValue(engine.BOOL)
+	case "DATETIME":
+		return engine.NewNullValue(engine.TIME)
+	case "BLOB":
+		return engine.NewNullValue(engine.BINARY)
+	default:
+		return engine.NewNullValue(engine.STRING)
+	}
+}
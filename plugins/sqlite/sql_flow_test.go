@@ -0,0 +1,172 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/sqlite"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlFlow(t *testing.T) {
+	tests := []struct {
+		name   string
+		flow   string
+		expect string
+	}{
+		{
+			name: "group_by",
+			flow: `
+				[[flows.sql]]
+					query = """
+						SELECT name, count(*) AS cnt, sum(value) AS total, max(ok) AS ok
+						FROM records GROUP BY name ORDER BY name
+					"""
+			`,
+			expect: `{"cnt":2,"name":"a","ok":1,"total":4}` + "\n" +
+				`{"cnt":1,"name":"b","ok":0,"total":2}` + "\n",
+		},
+		{
+			name: "join_tags",
+			flow: `
+				[[flows.sql]]
+					table = "m"
+					tags = ["_ts", "_in"]
+					query = """
+						SELECT m._ts, m._in, m.name, m.value * 10 AS value10, m.ok, n.value AS next
+						FROM m LEFT JOIN m AS n ON n.name = m.name AND n._ts > m._ts
+						ORDER BY m._ts
+					"""
+			`,
+			expect: `{"_in":"file","_ts":1721954798,"name":"a","next":3,"ok":true,"value10":10}` + "\n" +
+				`{"_in":"file","_ts":1721954799,"name":"b","next":null,"ok":false,"value10":20}` + "\n" +
+				`{"_in":"file","_ts":1721954800,"name":"a","next":null,"ok":null,"value10":30}` + "\n",
+		},
+		{
+			name: "window",
+			flow: `
+				[[flows.sql]]
+					window_size = 10
+					query = "SELECT count(*) AS cnt FROM records"
+			`,
+			// the records are flushed when the pipeline stops
+			expect: `{"cnt":3}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsl := `
+				[log]
+					level = "error"
+				[[inlets.file]]
+					data = ["a,1,true", "b,2,false", "a,3,"]
+					fields = ["name", "value", "ok"]
+					types = ["string", "float", "bool"]
+			` + tt.flow + `
+				[[outlets.file]]
+					format = "json"
+			`
+			seq := int64(0)
+			engine.Now = func() time.Time { seq++; return time.Unix(1721954797+seq, 0) }
+			out := &bytes.Buffer{}
+			pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+			require.NoError(t, err)
+			require.NoError(t, pipeline.Run())
+			require.Equal(t, tt.expect, out.String())
+		})
+	}
+}
+
+func TestSqlFlowUint64(t *testing.T) {
+	engine.RegisterInlet(&engine.InletReg{
+		Name: "test-uint64",
+		Factory: func(ctx *engine.Context) engine.Inlet {
+			return engine.InletWithFunc(func() ([]engine.Record, error) {
+				return []engine.Record{
+					engine.NewRecord(engine.NewField("name", "a"), engine.NewField("value", uint64(math.MaxInt64))),
+					engine.NewRecord(engine.NewField("name", "b"), engine.NewField("value", uint64(math.MaxUint64))),
+				}, nil
+			}, engine.WithRunCountLimit(1))
+		},
+	})
+
+	dsl := `
+		[log]
+			level = "error"
+		[[inlets.test-uint64]]
+		[[flows.sql]]
+			query = "SELECT name, value, typeof(value) AS type FROM records ORDER BY name"
+		[[outlets.file]]
+			format = "json"
+	`
+	out := &bytes.Buffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	require.NoError(t, pipeline.Run())
+	// the value that overflows int64 is converted to REAL
+	require.Equal(t, `{"name":"a","type":"integer","value":9223372036854775807}`+"\n"+
+		`{"name":"b","type":"real","value":18446744073709552000}`+"\n", out.String())
+}
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+func TestSqlFlowWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.csv")
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+	dsl := fmt.Sprintf(`
+	[log]
+		level = "error"
+	[[inlets.tail]]
+		paths = [%q]
+		poll_interval = "50ms"
+		format = "csv"
+		fields = ["name", "value"]
+		types = ["string", "float"]
+	[[flows.sql]]
+		window = "200ms"
+		query = "SELECT count(*) AS cnt, sum(value) AS total FROM records"
+	[[outlets.file]]
+		format = "json"
+	`, path)
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+	defer pipeline.Stop()
+	time.Sleep(300 * time.Millisecond)
+
+	// the window is closed by the timer without the next records
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("a,1\nb,2\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	expect := `{"cnt":2,"total":3}` + "\n"
+	for i := 0; i < 100 && out.String() != expect; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Equal(t, expect, out.String())
+}
@@ -1,6 +1,12 @@
 package sqlite
 
 import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/OutOfBedlam/tine/engine"
 )
 
@@ -12,16 +18,98 @@ func init() {
 }
 
 func SqliteOutlet(ctx *engine.Context) engine.Outlet {
-	return &sqliteOutlet{NewBase(ctx)}
+	return &sqliteOutlet{SqliteBase: NewBase(ctx)}
 }
 
 type sqliteOutlet struct {
 	*SqliteBase
+
+	// auto_table mode
+	autoTable string
+	keys      []string
+	retention time.Duration
+	columns   []string
+	// epoch unit of "_ts" and the time fields
+	timeformat *engine.Timeformatter
 }
 
 var _ = (engine.Outlet)((*sqliteOutlet)(nil))
 
+func (so *sqliteOutlet) Open() error {
+	if err := so.SqliteBase.Open(); err != nil {
+		return err
+	}
+	conf := so.Ctx.Config()
+	so.autoTable = conf.GetString("auto_table", "")
+	so.keys = conf.GetStringSlice("keys", nil)
+	so.retention = conf.GetDuration("retention", 0)
+	so.timeformat = engine.NewTimeformatter(conf.GetString("timeformat", "ms"))
+	if so.autoTable == "" {
+		return nil
+	}
+	if !so.timeformat.IsEpoch() {
+		return fmt.Errorf("sqlite auto_table, timeformat should be one of s, ms, us and ns")
+	}
+	// columns of the table if it exists already
+	rows, err := so.DB.QueryContext(so.Ctx, fmt.Sprintf("PRAGMA table_info(%s)", quoteIdent(so.autoTable)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, _ := rows.Columns()
+	for rows.Next() {
+		values := make([]any, len(cols))
+		dest := make([]any, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if i := slices.Index(cols, "name"); i >= 0 {
+			so.columns = append(so.columns, fmt.Sprint(values[i]))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(so.columns) > 0 && len(so.keys) > 0 {
+		return so.uniqueKeys()
+	}
+	return nil
+}
+
+// uniqueKeys creates the unique index of the keys on the table that exists already,
+// the table may not have the UNIQUE constraint that ON CONFLICT requires.
+func (so *sqliteOutlet) uniqueKeys() error {
+	keys := make([]string, len(so.keys))
+	for i, key := range so.keys {
+		keys[i] = quoteIdent(key)
+		if slices.Contains(so.columns, key) {
+			continue
+		}
+		sqlText := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", quoteIdent(so.autoTable), keys[i])
+		if _, err := so.DB.ExecContext(so.Ctx, sqlText); err != nil {
+			return err
+		}
+		so.columns = append(so.columns, key)
+	}
+	sqlText := fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s)",
+		quoteIdent(so.autoTable+"_"+strings.Join(so.keys, "_")), quoteIdent(so.autoTable), strings.Join(keys, ", "))
+	if _, err := so.DB.ExecContext(so.Ctx, sqlText); err != nil {
+		return fmt.Errorf("sqlite auto_table, unique keys %v, %w", so.keys, err)
+	}
+	so.Ctx.LogDebug("sqlite auto_table", "sql", sqlText)
+	return nil
+}
+
 func (so *sqliteOutlet) Handle(recs []engine.Record) error {
+	if so.autoTable != "" {
+		if err := so.handleAutoTable(recs); err != nil {
+			so.Ctx.LogWarn("sqlite auto_table", "table", so.autoTable, "error", err)
+			return err
+		}
+	}
 	for _, rec := range recs {
 		for _, act := range so.Actions {
 			args := make([]any, len(act.Fields))
@@ -50,3 +138,172 @@ func (so *sqliteOutlet) Handle(recs []engine.Record) error {
 	}
 	return nil
 }
+
+// handleAutoTable inserts the records into the auto_table in a transaction,
+// the table is created or altered by the fields of the records,
+// the rows of the same keys are updated, and the rows older than retention are deleted.
+func (so *sqliteOutlet) handleAutoTable(recs []engine.Record) error {
+	tx, err := so.DB.BeginTx(so.Ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rollback does nothing after Commit
+	defer tx.Rollback()
+
+	columns := so.columns
+	stmts := map[string]*sql.Stmt{}
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+	for _, rec := range recs {
+		names := []string{engine.TAG_TIMESTAMP}
+		args := []any{nil}
+		if ts := rec.Tags().Get(engine.TAG_TIMESTAMP); ts != nil && !ts.IsNull() {
+			if t, ok := ts.Time(); ok {
+				args[0] = so.timeformat.Epoch(t)
+			}
+		}
+		types := []engine.Type{engine.TIME}
+		for _, f := range rec.Fields() {
+			if f == nil || f.Name == engine.TAG_TIMESTAMP {
+				continue
+			}
+			names = append(names, f.Name)
+			types = append(types, f.Type())
+			switch {
+			case f.IsNull():
+				args = append(args, nil)
+			case f.Type() == engine.TIME:
+				// convert to unix epoch of the timeformat
+				v, _ := f.Value.Time()
+				args = append(args, so.timeformat.Epoch(v))
+			default:
+				args = append(args, f.Value.Raw())
+			}
+		}
+		if columns, err = so.alterTable(tx, columns, names, types); err != nil {
+			return err
+		}
+		key := strings.Join(names, "\x00")
+		stmt, ok := stmts[key]
+		if !ok {
+			if stmt, err = tx.PrepareContext(so.Ctx, so.insertSql(names)); err != nil {
+				return err
+			}
+			stmts[key] = stmt
+		}
+		if _, err := stmt.ExecContext(so.Ctx, args...); err != nil {
+			return err
+		}
+	}
+	if so.retention > 0 {
+		deleteSql := fmt.Sprintf("DELETE FROM %s WHERE %s < ?", quoteIdent(so.autoTable), quoteIdent(engine.TAG_TIMESTAMP))
+		result, err := tx.ExecContext(so.Ctx, deleteSql, so.timeformat.Epoch(engine.Now().Add(-so.retention)))
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			so.Ctx.LogDebug("sqlite auto_table", "table", so.autoTable, "retention", so.retention, "deleted", n)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	so.columns = columns
+	return nil
+}
+
+// alterTable creates the table or adds the columns those are not in the table yet,
+// it returns the columns of the table.
+func (so *sqliteOutlet) alterTable(tx *sql.Tx, columns []string, names []string, types []engine.Type) ([]string, error) {
+	if len(columns) == 0 {
+		defs := []string{}
+		for i, name := range names {
+			defs = append(defs, strings.TrimSpace(quoteIdent(name)+" "+affinity(types[i])))
+			columns = append(columns, name)
+		}
+		// the key columns those are not in the record yet
+		for _, key := range so.keys {
+			if !slices.Contains(columns, key) {
+				defs = append(defs, quoteIdent(key))
+				columns = append(columns, key)
+			}
+		}
+		if len(so.keys) > 0 {
+			keys := make([]string, len(so.keys))
+			for i, key := range so.keys {
+				keys[i] = quoteIdent(key)
+			}
+			defs = append(defs, fmt.Sprintf("UNIQUE(%s)", strings.Join(keys, ", ")))
+		}
+		sqlText := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdent(so.autoTable), strings.Join(defs, ", "))
+		if _, err := tx.ExecContext(so.Ctx, sqlText); err != nil {
+			return nil, err
+		}
+		so.Ctx.LogDebug("sqlite auto_table", "sql", sqlText)
+		return columns, nil
+	}
+	for i, name := range names {
+		if slices.Contains(columns, name) {
+			continue
+		}
+		sqlText := strings.TrimSpace(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(so.autoTable), quoteIdent(name), affinity(types[i])))
+		if _, err := tx.ExecContext(so.Ctx, sqlText); err != nil {
+			return nil, err
+		}
+		so.Ctx.LogDebug("sqlite auto_table", "sql", sqlText)
+		// do not modify the columns of the caller, it is rolled back if the transaction fails
+		columns = append(slices.Clip(columns), name)
+	}
+	return columns, nil
+}
+
+// insertSql returns INSERT statement that updates the row of the same keys
+func (so *sqliteOutlet) insertSql(names []string) string {
+	cols := make([]string, len(names))
+	for i, name := range names {
+		cols[i] = quoteIdent(name)
+	}
+	sqlText := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdent(so.autoTable), strings.Join(cols, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "))
+	if len(so.keys) == 0 {
+		return sqlText
+	}
+	keys := make([]string, len(so.keys))
+	for i, key := range so.keys {
+		keys[i] = quoteIdent(key)
+	}
+	updates := []string{}
+	for i, name := range names {
+		if !slices.Contains(so.keys, name) {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", cols[i], cols[i]))
+		}
+	}
+	if len(updates) == 0 {
+		return fmt.Sprintf("%s ON CONFLICT(%s) DO NOTHING", sqlText, strings.Join(keys, ", "))
+	}
+	return fmt.Sprintf("%s ON CONFLICT(%s) DO UPDATE SET %s", sqlText, strings.Join(keys, ", "), strings.Join(updates, ", "))
+}
+
+// affinity returns the SQLite type affinity of the field type,
+// the time is saved as unix epoch.
+func affinity(typ engine.Type) string {
+	switch typ {
+	case engine.BOOL, engine.INT, engine.UINT, engine.TIME:
+		return "INTEGER"
+	case engine.FLOAT:
+		return "REAL"
+	case engine.STRING:
+		return "TEXT"
+	case engine.BINARY:
+		return "BLOB"
+	default:
+		return ""
+	}
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -1,7 +1,10 @@
 package sqlite_test
 
 import (
+	"database/sql"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -9,6 +12,7 @@ import (
 	_ "github.com/OutOfBedlam/tine/plugins/base"
 	_ "github.com/OutOfBedlam/tine/plugins/psutil"
 	_ "github.com/OutOfBedlam/tine/plugins/sqlite"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSqlite(t *testing.T) {
@@ -71,3 +75,94 @@ const output = `
 #  path = "-"
 #  format = "json"
 `
+
+func TestSqliteAutoTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auto.db")
+	dsl := fmt.Sprintf(`
+		[log]
+			level = "error"
+		[[inlets.file]]
+			format = "json"
+			data = [
+				'{"name":"c", "value":1}',
+				'{"name":"a", "value":2}',
+				'{"name":"b", "value":3, "unit":"ms"}',
+				'{"name":"a", "value":4}',
+			]
+		[[outlets.sqlite]]
+			path = %q
+			auto_table = "metrics"
+			keys = ["name"]
+			retention = "25s"
+		`, path)
+	seq := int64(0)
+	engine.Now = func() time.Time { seq++; return time.Unix(1721954790+seq*10, 0) }
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	require.NoError(t, err)
+	require.NoError(t, pipeline.Run())
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var schema string
+	require.NoError(t, db.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'metrics'").Scan(&schema))
+	require.Equal(t, `CREATE TABLE "metrics" ("_ts" INTEGER, "name" TEXT, "value" REAL, "unit" TEXT, UNIQUE("name"))`, schema)
+
+	rows, err := db.Query(`SELECT "_ts", name, value, unit FROM metrics ORDER BY name`)
+	require.NoError(t, err)
+	defer rows.Close()
+	result := []string{}
+	for rows.Next() {
+		var ts int64
+		var name string
+		var value float64
+		var unit sql.NullString
+		require.NoError(t, rows.Scan(&ts, &name, &value, &unit))
+		result = append(result, fmt.Sprintf("%d %s %v %s", ts, name, value, unit.String))
+	}
+	// "c" is deleted by the retention, "a" is updated
+	require.Equal(t, []string{"1721954830000 a 4 ", "1721954820000 b 3 ms"}, result)
+}
+
+func TestSqliteAutoTableExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "existing.db")
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	// the table that does not have the UNIQUE constraint of the keys
+	_, err = db.Exec(`CREATE TABLE metrics ("_ts" INTEGER, name TEXT, value REAL)`)
+	require.NoError(t, err)
+
+	dsl := fmt.Sprintf(`
+		[log]
+			level = "error"
+		[[inlets.file]]
+			format = "json"
+			data = [
+				'{"name":"a", "value":1}',
+				'{"name":"a", "value":2}',
+			]
+		[[outlets.sqlite]]
+			path = %q
+			auto_table = "metrics"
+			keys = ["name"]
+			timeformat = "ns"
+		`, path)
+	engine.Now = func() time.Time { return time.Unix(1721954790, 123456789) }
+	pipeline, err := engine.New(engine.WithConfig(dsl))
+	require.NoError(t, err)
+	require.NoError(t, pipeline.Run())
+
+	var index string
+	require.NoError(t, db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = 'metrics'").Scan(&index))
+	require.Equal(t, `CREATE UNIQUE INDEX "metrics_name" ON "metrics" ("name")`, index)
+
+	var ts int64
+	var value float64
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT "_ts", value, (SELECT count(*) FROM metrics) FROM metrics WHERE name = 'a'`).Scan(&ts, &value, &count))
+	require.Equal(t, int64(1721954790123456789), ts)
+	require.Equal(t, 2.0, value)
+	require.Equal(t, 1, count)
+}
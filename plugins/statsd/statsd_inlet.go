@@ -0,0 +1,436 @@
+package statsd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+)
+
+func init() {
+	engine.RegisterInlet(&engine.InletReg{
+		Name:    "statsd",
+		Factory: StatsdInlet,
+	})
+}
+
+func StatsdInlet(ctx *engine.Context) engine.Inlet {
+	return &statsdInlet{
+		ctx:     ctx,
+		metrics: map[string]*metric{},
+		gauges:  map[string]*gauge{},
+		conns:   map[net.Conn]struct{}{},
+		closeCh: make(chan struct{}),
+		flushCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+}
+
+type statsdInlet struct {
+	ctx           *engine.Context
+	flushInterval time.Duration
+	percentiles   []int
+	maxPacketSize int
+	gaugeTTL      time.Duration
+
+	// aggregated metrics of the current flush interval
+	metrics map[string]*metric
+	// last values of the gauges, for the relative changes and the re-emission
+	gauges map[string]*gauge
+	mutex  sync.Mutex
+
+	// tcp
+	lsnr  net.Listener
+	conns map[net.Conn]struct{}
+	// udp
+	pktConn net.PacketConn
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	closeWg   sync.WaitGroup
+	// flushCh makes Process emit the last interval and return, it closes doneCh
+	flushCh chan struct{}
+	doneCh  chan struct{}
+	running bool
+}
+
+var _ = engine.Inlet((*statsdInlet)(nil))
+
+// metric is the aggregated values of a metric with the same name, type and tags
+type metric struct {
+	name   string
+	typ    string
+	tags   [][2]string
+	value  float64
+	values []float64
+	set    map[string]struct{}
+}
+
+// gauge is the last value of a gauge
+type gauge struct {
+	metric
+	updated time.Time
+}
+
+func (si *statsdInlet) Open() error {
+	conf := si.ctx.Config()
+	address := conf.GetString("address", "udp://127.0.0.1:8125")
+	si.flushInterval = conf.GetDuration("flush_interval", 10*time.Second)
+	si.percentiles = conf.GetIntSlice("percentiles", []int{50, 90, 99})
+	si.maxPacketSize = conf.GetInt("max_packet_size", 64*1024)
+	si.gaugeTTL = conf.GetDuration("gauge_ttl", 10*time.Minute)
+	if si.flushInterval <= 0 {
+		return fmt.Errorf("inlet.statsd flush_interval should be greater than 0")
+	}
+	for _, p := range si.percentiles {
+		if p <= 0 || p > 100 {
+			return fmt.Errorf("inlet.statsd invalid percentile %d", p)
+		}
+	}
+
+	protoAddr := strings.SplitN(address, "://", 2)
+	if len(protoAddr) != 2 {
+		return fmt.Errorf("inlet.statsd invalid address %q", address)
+	}
+	si.ctx.LogDebug("inlet.statsd", "address", address, "flush_interval", si.flushInterval)
+
+	switch protoAddr[0] {
+	case "tcp", "tcp4", "tcp6", "unix":
+		if ln, err := net.Listen(protoAddr[0], protoAddr[1]); err != nil {
+			return err
+		} else {
+			si.lsnr = ln
+		}
+		si.closeWg.Add(1)
+		go si.handleStream()
+	case "udp", "udp4", "udp6", "unixgram":
+		if ln, err := net.ListenPacket(protoAddr[0], protoAddr[1]); err != nil {
+			return err
+		} else {
+			si.pktConn = ln
+		}
+		si.closeWg.Add(1)
+		go si.handleDatagram()
+	default:
+		return fmt.Errorf("unsupported protocol: %s in %s", protoAddr[0], address)
+	}
+	return nil
+}
+
+func (si *statsdInlet) Close() error {
+	si.closeOnce.Do(func() {
+		close(si.closeCh)
+		if si.lsnr != nil {
+			si.lsnr.Close()
+		}
+		if si.pktConn != nil {
+			si.pktConn.Close()
+		}
+		si.mutex.Lock()
+		for conn := range si.conns {
+			conn.Close()
+		}
+		running := si.running
+		si.mutex.Unlock()
+		si.closeWg.Wait()
+		// all received metrics are aggregated, emit the current interval
+		close(si.flushCh)
+		if running {
+			<-si.doneCh
+		}
+	})
+	return nil
+}
+
+// Process emits the aggregated metrics every flush interval,
+// and the metrics of the current interval when the inlet is closed.
+func (si *statsdInlet) Process(next engine.InletNextFunc) {
+	si.mutex.Lock()
+	select {
+	case <-si.closeCh:
+		si.mutex.Unlock()
+		return
+	default:
+	}
+	si.running = true
+	si.mutex.Unlock()
+	defer close(si.doneCh)
+
+	ticker := time.NewTicker(si.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-si.flushCh:
+			if recs := si.flush(time.Now()); len(recs) > 0 {
+				next(recs, nil)
+			}
+			return
+		case now := <-ticker.C:
+			if recs := si.flush(now); len(recs) > 0 {
+				next(recs, nil)
+			}
+		}
+	}
+}
+
+func (si *statsdInlet) handleDatagram() {
+	defer si.closeWg.Done()
+	buf := make([]byte, si.maxPacketSize)
+	for {
+		n, _, err := si.pktConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			si.ctx.LogWarn("inlet.statsd", "read error", err.Error())
+			continue
+		}
+		// a packet can have multiple metrics separated by newline
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			si.handleLine(line)
+		}
+	}
+}
+
+func (si *statsdInlet) handleStream() {
+	defer si.closeWg.Done()
+	for {
+		conn, err := si.lsnr.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			si.ctx.LogWarn("inlet.statsd", "accept error", err.Error())
+			continue
+		}
+		si.mutex.Lock()
+		select {
+		case <-si.closeCh:
+			si.mutex.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		si.conns[conn] = struct{}{}
+		si.mutex.Unlock()
+
+		si.closeWg.Add(1)
+		go func() {
+			defer func() {
+				si.mutex.Lock()
+				delete(si.conns, conn)
+				si.mutex.Unlock()
+				conn.Close()
+				si.closeWg.Done()
+			}()
+			sc := bufio.NewScanner(conn)
+			sc.Buffer(make([]byte, 0, 4096), si.maxPacketSize)
+			for sc.Scan() {
+				si.handleLine(sc.Text())
+			}
+		}()
+	}
+}
+
+func (si *statsdInlet) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	// DogStatsD events and service checks are not metrics
+	if strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+		return
+	}
+	m, err := parseLine(line)
+	if err != nil {
+		si.ctx.LogWarn("inlet.statsd", "line", line, "error", err.Error())
+		return
+	}
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	si.aggregate(m)
+}
+
+// sample is a parsed statsd line
+type sample struct {
+	name  string
+	typ   string
+	value string
+	rate  float64
+	tags  [][2]string
+}
+
+// parseLine parses `<name>:<value>|<type>[|@<rate>][|#<tag>:<value>,<tag>]`
+func parseLine(line string) (*sample, error) {
+	colon := strings.IndexByte(line, ':')
+	if colon <= 0 {
+		return nil, fmt.Errorf("invalid metric")
+	}
+	parts := strings.Split(line[colon+1:], "|")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid metric")
+	}
+	ret := &sample{name: line[:colon], value: parts[0], rate: 1}
+	switch parts[1] {
+	case "c":
+		ret.typ = "counter"
+	case "g":
+		ret.typ = "gauge"
+	case "ms":
+		ret.typ = "timer"
+	case "h", "d":
+		ret.typ = "histogram"
+	case "s":
+		ret.typ = "set"
+	default:
+		return nil, fmt.Errorf("unknown type %q", parts[1])
+	}
+	for _, p := range parts[2:] {
+		switch {
+		case strings.HasPrefix(p, "@"):
+			rate, err := strconv.ParseFloat(p[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("invalid sample rate %q", p)
+			}
+			ret.rate = rate
+		case strings.HasPrefix(p, "#"):
+			for _, tag := range strings.Split(p[1:], ",") {
+				if tag == "" {
+					continue
+				}
+				k, v, _ := strings.Cut(tag, ":")
+				ret.tags = append(ret.tags, [2]string{k, v})
+			}
+		}
+		// the other DogStatsD extensions, e.g. container id "c:" and timestamp "T", are ignored
+	}
+	slices.SortFunc(ret.tags, func(a, b [2]string) int { return strings.Compare(a[0], b[0]) })
+	if ret.typ != "set" {
+		if _, err := strconv.ParseFloat(ret.value, 64); err != nil {
+			return nil, fmt.Errorf("invalid value %q", ret.value)
+		}
+	}
+	return ret, nil
+}
+
+func (si *statsdInlet) aggregate(s *sample) {
+	key := s.name + "|" + s.typ
+	for _, t := range s.tags {
+		key += "|" + t[0] + ":" + t[1]
+	}
+	m, ok := si.metrics[key]
+	if !ok {
+		m = &metric{name: s.name, typ: s.typ, tags: s.tags}
+		si.metrics[key] = m
+	}
+	switch s.typ {
+	case "counter":
+		v, _ := strconv.ParseFloat(s.value, 64)
+		m.value += v / s.rate
+	case "gauge":
+		v, _ := strconv.ParseFloat(s.value, 64)
+		g, ok := si.gauges[key]
+		if !ok {
+			g = &gauge{metric: metric{name: s.name, typ: s.typ, tags: s.tags}}
+			si.gauges[key] = g
+		}
+		// a signed value changes the last value of the gauge
+		if strings.HasPrefix(s.value, "+") || strings.HasPrefix(s.value, "-") {
+			v += g.value
+		}
+		g.value = v
+		g.updated = time.Now()
+		m.value = v
+	case "timer", "histogram":
+		v, _ := strconv.ParseFloat(s.value, 64)
+		m.values = append(m.values, v)
+		// the sampled values are counted by the rate
+		m.value += 1 / s.rate
+	case "set":
+		if m.set == nil {
+			m.set = map[string]struct{}{}
+		}
+		m.set[s.value] = struct{}{}
+	}
+}
+
+// flush returns the records of the aggregated metrics and resets them.
+// The gauges those are not updated in the interval are emitted with the last value
+// until the gauge_ttl passes since the last update, then they are forgotten.
+func (si *statsdInlet) flush(now time.Time) []engine.Record {
+	si.mutex.Lock()
+	metrics := si.metrics
+	si.metrics = map[string]*metric{}
+	for k, g := range si.gauges {
+		if _, ok := metrics[k]; ok {
+			continue
+		}
+		if now.Sub(g.updated) >= si.gaugeTTL {
+			delete(si.gauges, k)
+			continue
+		}
+		m := g.metric
+		metrics[k] = &m
+	}
+	si.mutex.Unlock()
+
+	keys := make([]string, 0, len(metrics))
+	for k := range metrics {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	ret := make([]engine.Record, 0, len(metrics))
+	for _, k := range keys {
+		m := metrics[k]
+		rec := engine.NewRecord(
+			engine.NewField("name", m.name),
+			engine.NewField("type", m.typ),
+		)
+		switch m.typ {
+		case "counter", "gauge":
+			rec = rec.Append(engine.NewField("value", m.value))
+		case "set":
+			rec = rec.Append(engine.NewField("value", int64(len(m.set))))
+		case "timer", "histogram":
+			slices.Sort(m.values)
+			sum := 0.0
+			for _, v := range m.values {
+				sum += v
+			}
+			rec = rec.Append(
+				engine.NewField("count", m.value),
+				engine.NewField("sum", sum),
+				engine.NewField("mean", sum/float64(len(m.values))),
+				engine.NewField("min", m.values[0]),
+				engine.NewField("max", m.values[len(m.values)-1]),
+			)
+			for _, p := range si.percentiles {
+				rec = rec.Append(engine.NewField(fmt.Sprintf("p%d", p), percentile(m.values, p)))
+			}
+		}
+		tags := rec.Tags()
+		for _, t := range m.tags {
+			tags.Set(t[0], engine.NewValue(t[1]))
+		}
+		ret = append(ret, rec)
+	}
+	return ret
+}
+
+// percentile returns the nearest rank percentile of the sorted values
+func percentile(sorted []float64, p int) float64 {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,167 @@
+package statsd_test
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	_ "github.com/OutOfBedlam/tine/plugins/base"
+	_ "github.com/OutOfBedlam/tine/plugins/statsd"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestStatsdInlet(t *testing.T) {
+	lines := []string{
+		"page.views:1|c|#env:prod",
+		"page.views:2|c|@0.5|#env:prod",
+		"page.views:5|c|#env:dev",
+		"queue.size:10|g",
+		"queue.size:-3|g",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+		"_e{5,4}:title|text",
+		"api.latency:10|ms|#env:prod,region:kr",
+		"api.latency:30|ms|#region:kr,env:prod",
+		"api.latency:20|h|#env:prod,region:kr",
+		"api.latency:40|ms|#region:kr,env:prod",
+	}
+	expect := strings.Join([]string{
+		`{"count":1.00,"env":"prod","max":20.00,"mean":20.00,"min":20.00,"name":"api.latency","p50":20.00,"p90":20.00,"region":"kr","sum":20.00,"type":"histogram"}`,
+		`{"count":3.00,"env":"prod","max":40.00,"mean":26.67,"min":10.00,"name":"api.latency","p50":30.00,"p90":40.00,"region":"kr","sum":80.00,"type":"timer"}`,
+		`{"env":"dev","name":"page.views","region":null,"type":"counter","value":5.00}`,
+		`{"env":"prod","name":"page.views","region":null,"type":"counter","value":5.00}`,
+		`{"env":null,"name":"queue.size","region":null,"type":"gauge","value":7.00}`,
+		`{"env":null,"name":"users","region":null,"type":"set","value":2}`,
+	}, "\n") + "\n"
+
+	tests := []struct {
+		name    string
+		network string
+	}{
+		{name: "udp", network: "udp"},
+		{name: "tcp", network: "tcp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+			dsl := fmt.Sprintf(`
+			[[inlets.statsd]]
+				address = "%s://%s"
+				flush_interval = "300ms"
+				percentiles = [50, 90]
+				gauge_ttl = "0s"
+			[[flows.select]]
+				includes = ["#env", "#region", "*"]
+			[[outlets.file]]
+				format = "json"
+				decimal = 2
+			`, tt.network, addr)
+			out := &syncBuffer{}
+			pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+			require.NoError(t, err)
+			go pipeline.Run()
+			time.Sleep(100 * time.Millisecond)
+
+			conn, err := net.Dial(tt.network, addr)
+			require.NoError(t, err)
+			// a udp packet can have multiple lines
+			_, err = conn.Write([]byte(strings.Join(lines, "\n") + "\n"))
+			require.NoError(t, err)
+			conn.Close()
+
+			for i := 0; i < 100 && out.String() == ""; i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+			pipeline.Stop()
+			require.Equal(t, expect, out.String())
+		})
+	}
+}
+
+func TestStatsdInletGauge(t *testing.T) {
+	addr := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	dsl := fmt.Sprintf(`
+	[[inlets.statsd]]
+		address = "udp://%s"
+		flush_interval = "200ms"
+	[[outlets.file]]
+		format = "json"
+	`, addr)
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("udp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("queue.size:10|g"))
+	require.NoError(t, err)
+
+	// the gauge is emitted again in the interval that has no update
+	line := `{"name":"queue.size","type":"gauge","value":10}` + "\n"
+	for i := 0; i < 100 && strings.Count(out.String(), "\n") < 2; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Equal(t, line+line, out.String()[:2*len(line)])
+
+	pipeline.Stop()
+}
+
+func TestStatsdInletClose(t *testing.T) {
+	addr := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	dsl := fmt.Sprintf(`
+	[[inlets.statsd]]
+		address = "tcp://%s"
+		flush_interval = "1h"
+	[[outlets.file]]
+		format = "json"
+	`, addr)
+	out := &syncBuffer{}
+	pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+	require.NoError(t, err)
+	go pipeline.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("page.views:3|c\n"))
+	require.NoError(t, err)
+	conn.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	// the current interval is emitted when the inlet is closed
+	pipeline.Stop()
+	require.Equal(t, `{"name":"page.views","type":"counter","value":3}`+"\n", out.String())
+}
@@ -2,14 +2,16 @@ package syslog
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
 	"net"
 	"strings"
 	"sync"
 	"unicode"
 
 	"github.com/OutOfBedlam/tine/engine"
+	"github.com/OutOfBedlam/tine/util"
 	"github.com/leodido/go-syslog/v4"
 	"github.com/leodido/go-syslog/v4/nontransparent"
 	"github.com/leodido/go-syslog/v4/octetcounting"
@@ -26,8 +28,10 @@ func init() {
 
 func SyslogInlet(ctx *engine.Context) engine.Inlet {
 	return &syslogInlet{
-		ctx:    ctx,
-		pushCh: make(chan Data),
+		ctx:     ctx,
+		pushCh:  make(chan Data),
+		closeCh: make(chan struct{}),
+		conns:   map[net.Conn]struct{}{},
 	}
 }
 
@@ -37,15 +41,20 @@ type syslogInlet struct {
 	SdIdInfix string
 
 	pushCh    chan Data
+	closeCh   chan struct{}
 	closeOnce sync.Once
 	closed    bool
 	closeWg   sync.WaitGroup
 
 	// tcp
-	lsnr      net.Listener
-	ctxCancel context.CancelFunc
+	lsnr       net.Listener
+	framing    string
+	subjectTag string
+	conns      map[net.Conn]struct{}
+	connsLock  sync.Mutex
 	// udp
-	pktConn net.PacketConn
+	pktConn   net.PacketConn
+	ctxCancel context.CancelFunc
 }
 
 var _ = engine.Inlet((*syslogInlet)(nil))
@@ -62,13 +71,36 @@ func (si *syslogInlet) Open() error {
 
 	si.ctx.LogDebug("inlet-syslog", "address", address)
 
+	if len(protoAddr) != 2 {
+		return fmt.Errorf("invalid address %q", address)
+	}
 	switch protoAddr[0] {
-	case "tcp", "tcp4", "tcp6", "unix", "unixpacket":
-		if ln, err := net.Listen(protoAddr[0], protoAddr[1]); err != nil {
+	case "tcp", "tcp4", "tcp6", "unix", "unixpacket", "tls":
+		si.framing = si.ctx.Config().GetString("framing", "octetcounting")
+		switch si.framing {
+		case "octetcounting", "non-transparent", "non-transport":
+		default:
+			return fmt.Errorf("unsupported framing: %s", si.framing)
+		}
+		network := protoAddr[0]
+		if network == "tls" {
+			network = "tcp"
+		}
+		if ln, err := net.Listen(network, protoAddr[1]); err != nil {
 			return err
 		} else {
 			si.lsnr = ln
 		}
+		if protoAddr[0] == "tls" {
+			tlsConf := si.ctx.Config().GetConfig("tls", engine.Config{})
+			if conf, err := newTLSConfig(tlsConf); err != nil {
+				si.lsnr.Close()
+				return err
+			} else {
+				si.lsnr = tls.NewListener(si.lsnr, conf)
+			}
+			si.subjectTag = tlsConf.GetString("subject_tag", "peer_subject")
+		}
 		si.closeWg.Add(1)
 		go si.handleStream()
 	case "udp", "udp4", "udp6", "ip", "ip4", "ip6", "unixgram":
@@ -93,26 +125,51 @@ func (si *syslogInlet) Open() error {
 }
 
 func (si *syslogInlet) Close() error {
-	si.closed = true
 	si.closeOnce.Do(func() {
-		si.ctxCancel()
+		si.connsLock.Lock()
+		si.closed = true
+		for conn := range si.conns {
+			conn.Close()
+		}
+		si.connsLock.Unlock()
+		close(si.closeCh)
+		if si.ctxCancel != nil {
+			si.ctxCancel()
+		}
 		if si.lsnr != nil {
 			si.lsnr.Close()
 		}
 		if si.pktConn != nil {
 			si.pktConn.Close()
 		}
-		if si.pushCh != nil {
-			close(si.pushCh)
-		}
 		si.closeWg.Wait()
 	})
 	return nil
 }
 
 func (si *syslogInlet) Process(next engine.InletNextFunc) {
-	for d := range si.pushCh {
-		next(d.records, d.err)
+	for {
+		select {
+		case <-si.closeCh:
+			return
+		case d := <-si.pushCh:
+			select {
+			case <-si.closeCh:
+				return
+			default:
+				next(d.records, d.err)
+			}
+		}
+	}
+}
+
+// push sends the data to Process, it returns false if the inlet is closed
+func (si *syslogInlet) push(d Data) bool {
+	select {
+	case <-si.closeCh:
+		return false
+	case si.pushCh <- d:
+		return true
 	}
 }
 
@@ -137,7 +194,7 @@ func (si *syslogInlet) handleDatagram() {
 	}
 
 	sem := make(chan struct{}, parallelism)
-	for !si.closed {
+	for !si.isClosed() {
 		sem <- struct{}{}
 		si.closeWg.Add(1)
 		go func() {
@@ -148,9 +205,9 @@ func (si *syslogInlet) handleDatagram() {
 			buf := make([]byte, 64*1024)
 			n, addr, err := si.pktConn.ReadFrom(buf)
 			if err != nil {
-				if !si.closed {
+				if !si.isClosed() {
 					si.ctx.LogWarn("inlet-syslog", "read_error", err)
-					si.pushCh <- Data{err: err}
+					si.push(Data{err: err})
 				}
 				return
 			}
@@ -164,25 +221,78 @@ func (si *syslogInlet) handleDatagram() {
 			}
 			if r := si.records(message); r != nil {
 				r = r.Append(engine.NewField("remote_host", addr.(*net.UDPAddr).IP.String()))
-				si.pushCh <- Data{records: []engine.Record{r}}
+				si.push(Data{records: []engine.Record{r}})
 			}
 		}()
 	}
 }
 
+func (si *syslogInlet) isClosed() bool {
+	si.connsLock.Lock()
+	defer si.connsLock.Unlock()
+	return si.closed
+}
+
 func (si *syslogInlet) handleStream() {
 	defer si.closeWg.Done()
+	for {
+		conn, err := si.lsnr.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			si.ctx.LogWarn("inlet-syslog", "accept error", err)
+			continue
+		}
+		si.connsLock.Lock()
+		if si.closed {
+			si.connsLock.Unlock()
+			conn.Close()
+			return
+		}
+		si.conns[conn] = struct{}{}
+		si.connsLock.Unlock()
+
+		si.closeWg.Add(1)
+		go si.handleConn(conn)
+	}
+}
+
+func (si *syslogInlet) handleConn(conn net.Conn) {
+	defer func() {
+		si.connsLock.Lock()
+		delete(si.conns, conn)
+		si.connsLock.Unlock()
+		conn.Close()
+		si.closeWg.Done()
+	}()
+
+	remoteHost := ""
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		remoteHost = addr.IP.String()
+	}
+	// subject of the leaf client certificate, the rest of the chain is not used
+	peerSubject := ""
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			si.ctx.LogWarn("inlet-syslog", "remote_host", remoteHost, "handshake error", err)
+			return
+		}
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			peerSubject = certs[0].Subject.String()
+		}
+	}
+
 	var parser syslog.Parser
 	bestEffort := si.ctx.Config().GetBool("best_effort", false)
-	framing := si.ctx.Config().GetString("framing", "octetcounting")
 	opts := []syslog.ParserOption{}
 	if bestEffort {
 		opts = append(opts, syslog.WithBestEffort())
 	}
-	switch framing {
+	switch si.framing {
 	case "octetcounting":
 		parser = octetcounting.NewParser(opts...)
-	case "non-transport":
+	default:
 		parser = nontransparent.NewParser(opts...)
 	}
 	parser.WithListener(func(r *syslog.Result) {
@@ -194,10 +304,49 @@ func (si *syslogInlet) handleStream() {
 			return
 		}
 		if r := si.records(r.Message); r != nil {
-			si.pushCh <- Data{records: []engine.Record{r}}
+			if remoteHost != "" {
+				r = r.Append(engine.NewField("remote_host", remoteHost))
+			}
+			if peerSubject != "" && si.subjectTag != "" {
+				r.Tags().Set(si.subjectTag, engine.NewValue(peerSubject))
+			}
+			si.push(Data{records: []engine.Record{r}})
 		}
 	})
-	si.pushCh <- Data{err: io.EOF}
+	parser.Parse(conn)
+}
+
+// newTLSConfig returns the tls.Config of the listener from the "tls" config
+func newTLSConfig(conf engine.Config) (*tls.Config, error) {
+	ret, err := util.NewServerTLSConfig(util.TLSConfig{
+		CertFile: conf.GetString("cert", ""),
+		KeyFile:  conf.GetString("key", ""),
+		CAFile:   conf.GetString("client_ca", ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the client certificate is verified by client_ca, it is optional if client_auth is not required
+	requireClientAuth := conf.GetBool("require_client_auth", ret.ClientCAs != nil)
+	if requireClientAuth && ret.ClientCAs == nil {
+		return nil, errors.New("tls require_client_auth requires client_ca")
+	}
+	if ret.ClientCAs != nil && !requireClientAuth {
+		ret.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	switch minVersion := conf.GetString("min_version", "1.2"); minVersion {
+	case "1.0":
+		ret.MinVersion = tls.VersionTLS10
+	case "1.1":
+		ret.MinVersion = tls.VersionTLS11
+	case "1.2":
+		ret.MinVersion = tls.VersionTLS12
+	case "1.3":
+		ret.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported tls min_version %q", minVersion)
+	}
+	return ret, nil
 }
 
 func (si *syslogInlet) records(msg syslog.Message) engine.Record {
@@ -2,10 +2,20 @@ package syslog_test
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
 	"io"
 	"log/slog"
+	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -13,7 +23,7 @@ import (
 
 	"github.com/OutOfBedlam/tine/engine"
 	_ "github.com/OutOfBedlam/tine/plugins/base"
-	_ "github.com/OutOfBedlam/tine/plugins/syslog"
+	"github.com/OutOfBedlam/tine/plugins/syslog"
 	"github.com/stretchr/testify/require"
 )
 
@@ -145,3 +155,186 @@ func syslog_sender(inputFile string) {
 		writer.Write(line)
 	}
 }
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key,
+// the certificate is also used as the CA.
+func writeCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tine", Organization: []string{"tine.io"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+type syncBuffer struct {
+	buf   bytes.Buffer
+	mutex sync.Mutex
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.buf.String()
+}
+
+func TestSyslogInletStream(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+
+	tests := []struct {
+		name       string
+		scheme     string
+		inletConf  string
+		outletConf engine.Config
+		expect     string
+	}{
+		{
+			name:       "tcp_octetcounting",
+			scheme:     "tcp",
+			outletConf: engine.NewConfig(),
+			expect:     `{"appname":"su","message":"hello","remote_host":"127.0.0.1","peer_subject":null}`,
+		},
+		{
+			name:       "tcp_non-transparent",
+			scheme:     "tcp",
+			inletConf:  `framing = "non-transparent"`,
+			outletConf: engine.NewConfig().Set("framing", "non-transparent"),
+			expect:     `{"appname":"su","message":"hello","remote_host":"127.0.0.1","peer_subject":null}`,
+		},
+		{
+			name:   "mtls",
+			scheme: "tls",
+			inletConf: fmt.Sprintf(`
+				[inlets.syslog.tls]
+					cert = %q
+					key = %q
+					client_ca = %q
+					min_version = "1.3"
+			`, certFile, keyFile, certFile),
+			outletConf: engine.NewConfig().
+				Set("tls_ca", certFile).
+				Set("tls_cert", certFile).
+				Set("tls_key", keyFile),
+			expect: `{"appname":"su","message":"hello","remote_host":"127.0.0.1","peer_subject":"CN=tine,O=tine.io"}`,
+		},
+		{
+			name:   "tls_optional_client_auth",
+			scheme: "tls",
+			inletConf: fmt.Sprintf(`
+				[inlets.syslog.tls]
+					cert = %q
+					key = %q
+					client_ca = %q
+					require_client_auth = false
+			`, certFile, keyFile, certFile),
+			outletConf: engine.NewConfig().Set("tls_ca", certFile),
+			expect:     `{"appname":"su","message":"hello","remote_host":"127.0.0.1","peer_subject":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lsnr, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			addr := lsnr.Addr().String()
+			lsnr.Close()
+
+			dsl := fmt.Sprintf(`
+			[[inlets.syslog]]
+				address = "%s://%s"
+				%s
+			[[flows.select]]
+				includes = ["#peer_subject", "appname", "message", "remote_host"]
+			[[outlets.file]]
+				format = "json"
+			`, tt.scheme, addr, tt.inletConf)
+			out := &syncBuffer{}
+			pipeline, err := engine.New(engine.WithConfig(dsl), engine.WithWriter(out))
+			require.NoError(t, err)
+			go pipeline.Run()
+			// wait until the inlet listens
+			for i := 0; i < 100; i++ {
+				if conn, err := net.Dial("tcp", addr); err == nil {
+					conn.Close()
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			conf := tt.outletConf.
+				Set("address", tt.scheme+"://"+addr).
+				Set("syslog_standard", "rfc5424")
+			outlet := syslog.SyslogOutlet((&engine.Context{}).WithConfig(conf).WithLogger(slog.Default()))
+			require.NoError(t, outlet.Open())
+			require.NoError(t, outlet.Handle([]engine.Record{
+				engine.NewRecord(engine.NewField("appname", "su"), engine.NewField("message", "hello")),
+			}))
+			require.NoError(t, outlet.Close())
+
+			for i := 0; i < 100 && out.String() == ""; i++ {
+				time.Sleep(20 * time.Millisecond)
+			}
+			pipeline.Stop()
+			require.JSONEq(t, tt.expect, out.String())
+		})
+	}
+}
+
+func TestSyslogInletTLSClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+
+	newInlet := func(address string, tlsConf map[string]any) engine.Inlet {
+		conf := engine.NewConfig().Set("address", address).Set("tls", tlsConf)
+		return syslog.SyslogInlet((&engine.Context{}).WithConfig(conf).WithLogger(slog.Default()))
+	}
+	// invalid configs
+	require.Error(t, newInlet("tls://127.0.0.1:0", map[string]any{"cert": certFile}).Open())
+	require.Error(t, newInlet("tls://127.0.0.1:0", map[string]any{"cert": certFile, "key": keyFile, "require_client_auth": true}).Open())
+	require.Error(t, newInlet("tls://127.0.0.1:0", map[string]any{"cert": certFile, "key": keyFile, "min_version": "2.0"}).Open())
+
+	lsnr, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lsnr.Addr().String()
+	lsnr.Close()
+
+	inlet := newInlet("tls://"+addr, map[string]any{"cert": certFile, "key": keyFile, "client_ca": certFile})
+	require.NoError(t, inlet.Open())
+	defer inlet.Close()
+
+	// the client without the certificate is rejected
+	pool := x509.NewCertPool()
+	caPem, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	pool.AppendCertsFromPEM(caPem)
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool})
+	if err == nil {
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+	}
+	require.Error(t, err)
+	require.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,329 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/OutOfBedlam/tine/util"
+	"github.com/leodido/go-syslog/v4/rfc5424"
+)
+
+func init() {
+	engine.RegisterOutlet(&engine.OutletReg{
+		Name:    "syslog",
+		Factory: SyslogOutlet,
+	})
+}
+
+func SyslogOutlet(ctx *engine.Context) engine.Outlet {
+	return &syslogOutlet{ctx: ctx}
+}
+
+type syslogOutlet struct {
+	ctx *engine.Context
+
+	address  string
+	network  string
+	addr     string
+	standard string
+	framing  string
+
+	sdIdInfix string
+	sdIds     []string
+	facility  int
+	severity  int
+	hostname  string
+	appname   string
+
+	timeout           time.Duration
+	retries           int
+	reconnectInterval time.Duration
+	queueSize         int
+
+	dialer  *net.Dialer
+	tlsConf *tls.Config
+	conn    net.Conn
+	// messages those are not sent yet
+	queue [][]byte
+	// number of messages dropped
+	dropped uint64
+}
+
+var _ = engine.Outlet((*syslogOutlet)(nil))
+
+func (so *syslogOutlet) Open() error {
+	conf := so.ctx.Config()
+	address := conf.GetString("address", "udp://127.0.0.1:514")
+	so.address = address
+	protoAddr := strings.SplitN(address, "://", 2)
+	if len(protoAddr) != 2 || protoAddr[1] == "" {
+		return fmt.Errorf("outlet.syslog invalid address %q", address)
+	}
+	so.network, so.addr = protoAddr[0], protoAddr[1]
+	stream := true
+	switch so.network {
+	case "tls":
+		so.network = "tcp"
+		tlsConf, err := util.NewClientTLSConfig(util.TLSConfig{
+			CertFile:           conf.GetString("tls_cert", ""),
+			KeyFile:            conf.GetString("tls_key", ""),
+			CAFile:             conf.GetString("tls_ca", ""),
+			InsecureSkipVerify: conf.GetBool("tls_insecure_skip_verify", false),
+			ServerName:         conf.GetString("tls_server_name", ""),
+		})
+		if err != nil {
+			return fmt.Errorf("outlet.syslog %w", err)
+		}
+		so.tlsConf = tlsConf
+	case "tcp", "tcp4", "tcp6", "unix":
+	case "udp", "udp4", "udp6", "unixgram":
+		stream = false
+	default:
+		return fmt.Errorf("outlet.syslog unsupported protocol: %s in %s", so.network, address)
+	}
+
+	so.standard = strings.ToUpper(conf.GetString("syslog_standard", "rfc3164"))
+	if so.standard != "RFC3164" && so.standard != "RFC5424" {
+		return fmt.Errorf("outlet.syslog unsupported syslog_standard %q", so.standard)
+	}
+	if stream {
+		so.framing = conf.GetString("framing", "octetcounting")
+		switch so.framing {
+		case "octetcounting":
+		case "non-transparent", "non-transport":
+			so.framing = "non-transparent"
+		default:
+			return fmt.Errorf("outlet.syslog unsupported framing %q", so.framing)
+		}
+	}
+
+	so.sdIdInfix = conf.GetString("sd_id_infix", "_")
+	so.sdIds = conf.GetStringSlice("sd_ids", nil)
+	so.facility = conf.GetInt("facility", 1)
+	so.severity = conf.GetInt("severity", 6)
+	if so.facility < 0 || so.facility > 23 {
+		return fmt.Errorf("outlet.syslog invalid facility %d", so.facility)
+	}
+	if so.severity < 0 || so.severity > 7 {
+		return fmt.Errorf("outlet.syslog invalid severity %d", so.severity)
+	}
+	hostname, _ := os.Hostname()
+	so.hostname = conf.GetString("hostname", hostname)
+	so.appname = conf.GetString("appname", "tine")
+
+	so.timeout = conf.GetDuration("timeout", 3*time.Second)
+	so.retries = conf.GetInt("retries", 3)
+	so.reconnectInterval = conf.GetDuration("reconnect_interval", time.Second)
+	so.queueSize = conf.GetInt("queue_size", 1000)
+	so.dialer = &net.Dialer{
+		Timeout:   so.timeout,
+		KeepAlive: conf.GetDuration("keepalive", 15*time.Second),
+	}
+	// if the server is not available yet, it connects again when it sends
+	if err := so.connect(); err != nil {
+		so.ctx.LogWarn("outlet.syslog", "address", address, "connect error", err.Error())
+	}
+	so.ctx.LogDebug("outlet.syslog", "address", address, "syslog_standard", so.standard, "framing", so.framing)
+	return nil
+}
+
+func (so *syslogOutlet) Close() error {
+	if len(so.queue) > 0 && so.conn != nil {
+		so.queue, _ = so.write(so.queue)
+	}
+	if len(so.queue) > 0 {
+		so.ctx.LogWarn("outlet.syslog", "address", so.address, "drop messages", len(so.queue))
+		so.dropped += uint64(len(so.queue))
+		so.queue = nil
+	}
+	so.ctx.LogDebug("outlet.syslog", "address", so.address, "dropped", so.dropped)
+	if so.conn != nil {
+		so.conn.Close()
+		so.conn = nil
+	}
+	return nil
+}
+
+func (so *syslogOutlet) connect() error {
+	var conn net.Conn
+	var err error
+	if so.tlsConf != nil {
+		conn, err = tls.DialWithDialer(so.dialer, so.network, so.addr, so.tlsConf)
+	} else {
+		conn, err = so.dialer.Dial(so.network, so.addr)
+	}
+	if err != nil {
+		return err
+	}
+	so.conn = conn
+	return nil
+}
+
+// Handle sends a message for each record.
+// If it fails to send, it reconnects and retries, the messages those are not sent
+// are kept in the queue up to queue_size and sent with the next records.
+// The records those can not be formatted and the messages dropped
+// from the full queue are logged and counted as dropped.
+func (so *syslogOutlet) Handle(recs []engine.Record) error {
+	for i, r := range recs {
+		msg, err := so.message(r)
+		if err != nil {
+			so.ctx.LogWarn("outlet.syslog", "record", i, "format error", err.Error())
+			so.dropped++
+			continue
+		}
+		so.queue = append(so.queue, so.frame(msg))
+	}
+
+	var err error
+	for i := 0; i <= so.retries && len(so.queue) > 0; i++ {
+		if i > 0 {
+			time.Sleep(so.reconnectInterval)
+		}
+		if so.conn == nil {
+			if err = so.connect(); err != nil {
+				so.ctx.LogWarn("outlet.syslog", "address", so.address, "reconnect error", err.Error())
+				continue
+			}
+		}
+		// the messages those are sent already are not sent again
+		if so.queue, err = so.write(so.queue); err == nil {
+			return nil
+		}
+		so.ctx.LogWarn("outlet.syslog", "address", so.address, "write error", err.Error())
+		so.conn.Close()
+		so.conn = nil
+	}
+	if n := len(so.queue) - so.queueSize; n > 0 {
+		so.ctx.LogWarn("outlet.syslog", "address", so.address, "queue full, drop messages", n)
+		so.queue = append([][]byte(nil), so.queue[n:]...)
+		so.dropped += uint64(n)
+	}
+	return nil
+}
+
+// Dropped returns the number of messages dropped
+func (so *syslogOutlet) Dropped() uint64 {
+	return so.dropped
+}
+
+// write returns the messages those are not written yet
+func (so *syslogOutlet) write(msgs [][]byte) ([][]byte, error) {
+	for len(msgs) > 0 {
+		so.conn.SetWriteDeadline(time.Now().Add(so.timeout))
+		if _, err := so.conn.Write(msgs[0]); err != nil {
+			return msgs, err
+		}
+		msgs = msgs[1:]
+	}
+	return nil, nil
+}
+
+// frame returns the message with the framing of the stream,
+// the datagram is sent as it is.
+func (so *syslogOutlet) frame(msg string) []byte {
+	switch so.framing {
+	case "octetcounting":
+		return []byte(strconv.Itoa(len(msg)) + " " + msg)
+	case "non-transparent":
+		return []byte(msg + "\n")
+	default:
+		return []byte(msg)
+	}
+}
+
+// message formats the record as the syslog message,
+// the fields are mapped as inlets.syslog parses them.
+func (so *syslogOutlet) message(r engine.Record) (string, error) {
+	facility := so.facility
+	if v, ok := so.intField(r, "facility_code"); ok && v >= 0 && v <= 23 {
+		facility = v
+	}
+	severity := so.severity
+	if v, ok := so.intField(r, "severity_code"); ok && v >= 0 && v <= 7 {
+		severity = v
+	}
+	pri := facility*8 + severity
+
+	ts := engine.Now()
+	if f := r.Field("timestamp"); f != nil && !f.IsNull() {
+		if t, ok := f.Value.Time(); ok {
+			ts = t
+		}
+	}
+	hostname := so.stringField(r, "hostname", so.hostname)
+	appname := so.stringField(r, "appname", so.appname)
+	procid := so.stringField(r, "procid", "")
+	msgid := so.stringField(r, "msgid", "")
+	message := so.stringField(r, "message", "")
+
+	if so.standard == "RFC3164" {
+		// <PRI>TIMESTAMP HOSTNAME TAG: MESSAGE
+		sb := &strings.Builder{}
+		fmt.Fprintf(sb, "<%d>%s %s ", pri, ts.Format(time.Stamp), hostname)
+		if appname != "" {
+			sb.WriteString(appname)
+			if procid != "" {
+				sb.WriteString("[" + procid + "]")
+			}
+			sb.WriteString(": ")
+		}
+		sb.WriteString(message)
+		return sb.String(), nil
+	}
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID STRUCTURED-DATA] MESSAGE
+	msg := &rfc5424.SyslogMessage{}
+	msg.SetPriority(uint8(pri))
+	msg.SetVersion(1)
+	msg.SetTimestamp(ts.Format("2006-01-02T15:04:05.999999Z07:00"))
+	msg.SetHostname(hostname)
+	msg.SetAppname(appname)
+	msg.SetProcID(procid)
+	msg.SetMsgID(msgid)
+	for _, sdid := range so.sdIds {
+		for _, f := range r.Fields() {
+			if f.IsNull() {
+				continue
+			}
+			if f.Name == sdid {
+				// the SD-ID that does not have params is the bool field
+				if b, ok := f.Value.Bool(); ok && b {
+					msg.SetElementID(sdid)
+				}
+			} else if name, ok := strings.CutPrefix(f.Name, sdid+so.sdIdInfix); ok && name != "" {
+				if v, ok := f.Value.String(); ok {
+					msg.SetParameter(sdid, name, v)
+				}
+			}
+		}
+	}
+	if message != "" {
+		msg.SetMessage(message)
+	}
+	return msg.String()
+}
+
+func (so *syslogOutlet) stringField(r engine.Record, name string, defaultVal string) string {
+	if f := r.Field(name); f != nil && !f.IsNull() {
+		if v, ok := f.Value.String(); ok {
+			return v
+		}
+	}
+	return defaultVal
+}
+
+func (so *syslogOutlet) intField(r engine.Record, name string) (int, bool) {
+	if f := r.Field(name); f != nil && !f.IsNull() {
+		if v, ok := f.Value.Int64(); ok {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,194 @@
+package syslog_test
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OutOfBedlam/tine/engine"
+	"github.com/OutOfBedlam/tine/plugins/syslog"
+	"github.com/stretchr/testify/require"
+)
+
+func outletContext(conf engine.Config) *engine.Context {
+	return (&engine.Context{}).WithConfig(conf).WithLogger(slog.Default())
+}
+
+// recvServer receives the stream or the datagrams and returns the received data when it is closed
+type recvServer struct {
+	addr  string
+	close func()
+	wg    sync.WaitGroup
+	lock  sync.Mutex
+	data  []byte
+}
+
+func (rs *recvServer) received() string {
+	rs.close()
+	rs.wg.Wait()
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	return string(rs.data)
+}
+
+func (rs *recvServer) append(b []byte) {
+	rs.lock.Lock()
+	rs.data = append(rs.data, b...)
+	rs.lock.Unlock()
+}
+
+func newRecvServer(t *testing.T, network string, addr string) *recvServer {
+	rs := &recvServer{}
+	rs.wg.Add(1)
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", addr)
+		require.NoError(t, err)
+		rs.addr, rs.close = conn.LocalAddr().String(), func() { conn.Close() }
+		go func() {
+			defer rs.wg.Done()
+			buf := make([]byte, 64*1024)
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				rs.append(buf[:n])
+				rs.append([]byte("|"))
+			}
+		}()
+		return rs
+	}
+	lsnr, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	rs.addr, rs.close = lsnr.Addr().String(), func() { lsnr.Close() }
+	go func() {
+		defer rs.wg.Done()
+		conn, err := lsnr.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		buf := make([]byte, 4096)
+		for {
+			conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+			n, err := r.Read(buf)
+			rs.append(buf[:n])
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return rs
+}
+
+func TestSyslogOutlet(t *testing.T) {
+	ts := time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)
+	recs := []engine.Record{
+		engine.NewRecord(
+			engine.NewField("facility_code", int64(4)),
+			engine.NewField("severity_code", int64(2)),
+			engine.NewField("timestamp", ts),
+			engine.NewField("hostname", "mymachine.example.com"),
+			engine.NewField("appname", "su"),
+			engine.NewField("procid", "123"),
+			engine.NewField("msgid", "ID47"),
+			engine.NewField("message", "'su root' failed for lonvick on /dev/pts/8"),
+			engine.NewField("exampleSDID@32473_iut", "3"),
+			engine.NewField("exampleSDID@32473_eventID", int64(1011)),
+			engine.NewField("examplePriority@32473", true),
+		),
+		engine.NewRecord(
+			engine.NewField("timestamp", ts),
+			engine.NewField("message", "hello"),
+		),
+	}
+
+	tests := []struct {
+		name    string
+		network string
+		conf    engine.Config
+		expect  string
+	}{
+		{
+			name:    "rfc5424_udp",
+			network: "udp",
+			conf: engine.NewConfig().
+				Set("syslog_standard", "rfc5424").
+				Set("sd_ids", []string{"exampleSDID@32473", "examplePriority@32473"}),
+			expect: `<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su 123 ID47 [examplePriority@32473][exampleSDID@32473 eventID="1011" iut="3"] 'su root' failed for lonvick on /dev/pts/8|` +
+				`<14>1 2003-10-11T22:14:15.003Z myhost tine - - - hello|`,
+		},
+		{
+			name:    "rfc3164_tcp_octetcounting",
+			network: "tcp",
+			conf:    engine.NewConfig(),
+			expect: `93 <34>Oct 11 22:14:15 mymachine.example.com su[123]: 'su root' failed for lonvick on /dev/pts/8` +
+				`38 <14>Oct 11 22:14:15 myhost tine: hello`,
+		},
+		{
+			name:    "rfc5424_tcp_non-transparent",
+			network: "tcp",
+			conf: engine.NewConfig().
+				Set("syslog_standard", "RFC5424").
+				Set("framing", "non-transparent").
+				Set("facility", 16).
+				Set("severity", 5).
+				Set("appname", "app"),
+			expect: "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su 123 ID47 - 'su root' failed for lonvick on /dev/pts/8\n" +
+				"<133>1 2003-10-11T22:14:15.003Z myhost app - - - hello\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := newRecvServer(t, tt.network, "127.0.0.1:0")
+			conf := tt.conf.Set("address", tt.network+"://"+rs.addr).Set("hostname", "myhost")
+			outlet := syslog.SyslogOutlet(outletContext(conf))
+			require.NoError(t, outlet.Open())
+			require.NoError(t, outlet.Handle(recs))
+			require.NoError(t, outlet.Close())
+			if tt.network == "udp" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			require.Equal(t, tt.expect, rs.received())
+		})
+	}
+}
+
+func TestSyslogOutletQueue(t *testing.T) {
+	// reserve the address that is not listening yet
+	lsnr, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lsnr.Addr().String()
+	lsnr.Close()
+
+	conf := engine.NewConfig().
+		Set("address", "tcp://"+addr).
+		Set("framing", "non-transparent").
+		Set("hostname", "myhost").
+		Set("retries", 0).
+		Set("queue_size", 2)
+	outlet := syslog.SyslogOutlet(outletContext(conf))
+	require.NoError(t, outlet.Open())
+
+	ts := time.Date(2003, 10, 11, 22, 14, 15, 0, time.UTC)
+	rec := func(msg string) engine.Record {
+		return engine.NewRecord(engine.NewField("timestamp", ts), engine.NewField("message", msg))
+	}
+	// the server is not available, the messages are queued and the oldest one is dropped
+	require.NoError(t, outlet.Handle([]engine.Record{rec("one"), rec("two"), rec("three")}))
+	require.Equal(t, uint64(1), outlet.(interface{ Dropped() uint64 }).Dropped())
+
+	rs := newRecvServer(t, "tcp", addr)
+	require.NoError(t, outlet.Handle([]engine.Record{rec("four")}))
+	require.NoError(t, outlet.Close())
+	require.Equal(t, ""+
+		"<14>Oct 11 22:14:15 myhost tine: two\n"+
+		"<14>Oct 11 22:14:15 myhost tine: three\n"+
+		"<14>Oct 11 22:14:15 myhost tine: four\n",
+		rs.received())
+}